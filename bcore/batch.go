@@ -0,0 +1,221 @@
+package bcore
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/alkaid/behavior/logger"
+)
+
+// IBlackboardTx 批量读写黑板的事务,只在 IBlackboard.Batch 的回调内有效
+type IBlackboardTx interface {
+	// Get 获取Value,能读到本批次内的修改
+	//  @param key
+	//  @return any
+	//  @return bool
+	Get(key string) (any, bool)
+	// Set 设置KV,规则同 IBlackboard.Set
+	//  @param key
+	//  @param val
+	Set(key string, val any)
+	// Del 删除KV
+	//  @param key
+	Del(key string)
+}
+
+// blackboardChange 一批修改中某个键的变化
+type blackboardChange struct {
+	key     string
+	oldVal  any  // 批次开始前的值
+	existed bool // 批次开始前是否存在
+	op      OpType
+}
+
+var _ IBlackboardTx = (*blackboardTx)(nil)
+
+// blackboardTx 持有 Blackboard.memoryMutex 期间的读写
+type blackboardTx struct {
+	b          *Blackboard
+	changes    []blackboardChange // 按键首次修改的顺序,通知按此顺序执行
+	index      map[string]int     // 键->changes 的索引,修改的键较多时才建立,较少时线性查找更快
+	parentSets map[string]any     // 写到父黑板的KV,解锁后以 SetMany 提交
+}
+
+// txIndexThreshold 修改的键超过该数量时建立索引
+const txIndexThreshold = 16
+
+// Get
+//
+//	@implement IBlackboardTx.Get
+//	@receiver tx
+//	@param key
+//	@return any
+//	@return bool
+func (tx *blackboardTx) Get(key string) (any, bool) {
+	if val, ok := tx.b.userMemory[key]; ok {
+		return val, ok
+	}
+	if val, ok := tx.parentSets[key]; ok {
+		return val, ok
+	}
+	if tx.b.parent == nil {
+		return nil, false
+	}
+	return tx.b.parent.Get(key)
+}
+
+// Set
+//
+//	@implement IBlackboardTx.Set
+//	@receiver tx
+//	@param key
+//	@param val
+func (tx *blackboardTx) Set(key string, val any) {
+	if err := tx.b.checkTypeLocked(key, val); err != nil {
+		logger.Log.Error("[blackboard]set rejected by schema", zap.String("key", key), zap.Error(err))
+		return
+	}
+	// 优先设置父黑板
+	if tx.b.parent != nil {
+		_, pending := tx.parentSets[key]
+		if _, ok := tx.b.parent.Get(key); ok || pending {
+			if tx.parentSets == nil {
+				tx.parentSets = map[string]any{}
+			}
+			tx.parentSets[key] = val
+			return
+		}
+	}
+	tx.record(key)
+	tx.b.userMemory[key] = val
+}
+
+// Del
+//
+//	@implement IBlackboardTx.Del
+//	@receiver tx
+//	@param key
+func (tx *blackboardTx) Del(key string) {
+	if _, ok := tx.b.userMemory[key]; !ok {
+		return
+	}
+	tx.record(key)
+	delete(tx.b.userMemory, key)
+}
+
+// record 记录键在本批次修改前的值
+//
+//	@receiver tx
+//	@param key
+func (tx *blackboardTx) record(key string) {
+	if tx.index != nil {
+		if _, ok := tx.index[key]; ok {
+			return
+		}
+	} else {
+		for i := range tx.changes {
+			if tx.changes[i].key == key {
+				return
+			}
+		}
+	}
+	oldVal, existed := tx.b.userMemory[key]
+	tx.changes = append(tx.changes, blackboardChange{key: key, oldVal: oldVal, existed: existed})
+	switch {
+	case tx.index != nil:
+		tx.index[key] = len(tx.changes) - 1
+	case len(tx.changes) > txIndexThreshold:
+		tx.index = make(map[string]int, len(tx.changes)*2)
+		for i := range tx.changes {
+			tx.index[tx.changes[i].key] = i
+		}
+	}
+}
+
+// collect 合并出每个键的最终变化,批次前后都不存在的键被忽略
+//
+//	@receiver tx
+//	@return []blackboardChange
+func (tx *blackboardTx) collect() []blackboardChange {
+	result := tx.changes[:0]
+	for _, change := range tx.changes {
+		_, exists := tx.b.userMemory[change.key]
+		switch {
+		case change.existed && exists:
+			change.op = OpChange
+		case exists:
+			change.op = OpAdd
+		case change.existed:
+			change.op = OpDel
+		default:
+			continue
+		}
+		result = append(result, change)
+	}
+	return result
+}
+
+// Batch
+//
+//	@implement IBlackboard.Batch
+//	@receiver b
+//	@param fn
+func (b *Blackboard) Batch(fn func(tx IBlackboardTx)) {
+	b.batch(&blackboardTx{b: b}, fn)
+}
+
+// batch
+//
+//	@receiver b
+//	@param tx
+//	@param fn
+func (b *Blackboard) batch(tx *blackboardTx, fn func(tx IBlackboardTx)) {
+	changes := func() []blackboardChange {
+		b.memoryMutex.Lock()
+		defer b.memoryMutex.Unlock()
+		fn(tx)
+		return tx.collect()
+	}()
+	// 父黑板的键单独提交,不与本黑板同锁,参看 IBlackboard.Batch
+	if len(tx.parentSets) > 0 {
+		b.parent.SetMany(tx.parentSets)
+	}
+	// 要把notify排除在锁范围外,避免线程派发信道堵塞时长时间占用锁
+	b.notifyBatch(changes)
+}
+
+// SetMany
+//
+//	@implement IBlackboard.SetMany
+//	@receiver b
+//	@param kvs 按键排序后写入,使通知顺序确定
+func (b *Blackboard) SetMany(kvs map[string]any) {
+	if len(kvs) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b.batch(&blackboardTx{b: b, changes: make([]blackboardChange, 0, len(kvs))}, func(tx IBlackboardTx) {
+		for _, key := range keys {
+			tx.Set(key, kvs[key])
+		}
+	})
+}
+
+// notifyBatch 一批修改只派发一次任务,并通知子黑板,参看 Blackboard.fire
+//
+//	@receiver b
+//	@param changes
+func (b *Blackboard) notifyBatch(changes []blackboardChange) {
+	if len(changes) == 0 {
+		return
+	}
+	b.goTask(func() {
+		b.fire(b, changes)
+	})
+	b.notifyChildren(b, changes)
+}
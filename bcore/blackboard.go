@@ -7,6 +7,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/alkaid/behavior/config"
 	"github.com/alkaid/behavior/logger"
 
 	"github.com/alkaid/behavior/thread"
@@ -31,6 +32,13 @@ type Observer interface {
 	Fire(op OpType, key string, oldValue any, newValue any)
 }
 
+// Executor 任务执行器,负责把任务串行派发到AI线程.默认使用 thread.GoByID
+type Executor interface {
+	// Go 派发任务
+	//  @param task
+	Go(task func())
+}
+
 // Blackboard
 //
 //	@implement IBlackboard
@@ -39,22 +47,60 @@ type Observer interface {
 //	黑板的[生命周期调用,监听添加移除,监听函数的执行]必须派发到AI独立线程
 //	黑板的kv读写可以在任意线程
 //	黑板为树形结构,实例化时可指定父黑板,将继承父黑板的KV.父黑板,一般来说是AI集群的共享黑板。想实现AI间通信时这将很有用.
+//	父黑板的KV变化会通知到已启动的子黑板(递归),在子黑板各自的线程执行其监听函数,子黑板有同名键时不通知
 type Blackboard struct {
-	memoryMutex sync.RWMutex
-	threadID    int                    // 监听函数执行的线程ID
-	treesMemory map[string]Memory      // 索引为行为树ID(rootID),元素为对应行为树的数据<行为树域>.仅允许框架内部CRUD
-	nodesData   map[string]*NodeMemory // 索引为节点ID,元素为对应节点的数据<节点域>.仅允许节点内部CRUD
-	userMemory  Memory                 // 作用域为<用户域>的数据.仅允许业务方CRUD
-	enable      bool                   // 是否开启
-	observers   map[string][]Observer  // 监听列表
-	parent      *Blackboard            // 父黑板,一般来说是AI集群的共享黑板
-	children    []*Blackboard          // 子黑板
+	memoryMutex      sync.RWMutex
+	threadID         int                                 // 监听函数执行的线程ID
+	treesMemory      map[string]Memory                   // 索引为行为树ID(rootID),元素为对应行为树的数据<行为树域>.仅允许框架内部CRUD
+	nodesData        map[string]*NodeMemory              // 索引为节点ID,元素为对应节点的数据<节点域>.仅允许节点内部CRUD
+	userMemory       Memory                              // 作用域为<用户域>的数据.仅允许业务方CRUD
+	enable           bool                                // 是否开启
+	observers        map[string][]Observer               // 监听列表
+	patternObservers map[string][]Observer               // 模式监听列表,键为含通配符的模式,参看 PatternWildcard
+	patterns         patternTree                         // patternObservers 中模式的索引
+	parent           *Blackboard                         // 父黑板,一般来说是AI集群的共享黑板
+	childrenMutex    sync.Mutex                          // 子黑板在各自的线程启动和停止,须加锁
+	children         []*Blackboard                       // 子黑板,变化会通知到子黑板的监听函数
+	executor         Executor                            // 监听函数的执行器,为空则派发到 threadID 对应的线程
+	tracer           Tracer                              // 所属 IBrain 的追踪器,实现了 BlackboardTracer 时将收到数据变化
+	schema           map[string]*config.BlackboardKeyCfg // 黑板键声明,由 memoryMutex 保护
+	strict           bool                                // 严格模式,拒绝写入与 schema 类型不符的值
 }
 
 func (b *Blackboard) ThreadID() int {
 	return b.threadID
 }
 
+// SetExecutor
+//
+//	@implement IBlackboardInternal.SetExecutor
+//	@receiver b
+//	@param executor
+func (b *Blackboard) SetExecutor(executor Executor) {
+	b.executor = executor
+}
+
+// SetTracer
+//
+//	@implement IBlackboardInternal.SetTracer
+//	@receiver b
+//	@param tracer
+func (b *Blackboard) SetTracer(tracer Tracer) {
+	b.tracer = tracer
+}
+
+// goTask 派发任务到AI线程
+//
+//	@receiver b
+//	@param task
+func (b *Blackboard) goTask(task func()) {
+	if b.executor != nil {
+		b.executor.Go(task)
+		return
+	}
+	thread.GoByID(b.threadID, task)
+}
+
 // TreeMemory
 //
 //	@implement IBlackboardInternal.TreeMemory
@@ -95,6 +141,28 @@ func (b *Blackboard) NodeMemory(nodeID string) *NodeMemory {
 	return mem
 }
 
+// UserMemory
+//
+//	@implement IBlackboardInternal.UserMemory
+//	@receiver b
+//	@return Memory
+func (b *Blackboard) UserMemory() Memory {
+	b.memoryMutex.RLock()
+	defer b.memoryMutex.RUnlock()
+	return lo.Assign(b.userMemory)
+}
+
+// RestoreUserMemory
+//
+//	@implement IBlackboardInternal.RestoreUserMemory
+//	@receiver b
+//	@param mem
+func (b *Blackboard) RestoreUserMemory(mem Memory) {
+	b.memoryMutex.Lock()
+	b.userMemory = lo.Assign(mem)
+	b.memoryMutex.Unlock()
+}
+
 // NewBlackboard 实例化一个黑板
 //
 //	@param threadID AI工作线程ID
@@ -105,13 +173,14 @@ func NewBlackboard(threadID int, parent *Blackboard) *Blackboard {
 		logger.Log.Fatal("threadID cannot <=0")
 	}
 	b := &Blackboard{
-		threadID:    threadID,
-		treesMemory: make(map[string]Memory),
-		nodesData:   make(map[string]*NodeMemory),
-		userMemory:  make(Memory),
-		observers:   make(map[string][]Observer),
-		parent:      parent,
-		children:    make([]*Blackboard, 0),
+		threadID:         threadID,
+		treesMemory:      make(map[string]Memory),
+		nodesData:        make(map[string]*NodeMemory),
+		userMemory:       make(Memory),
+		observers:        make(map[string][]Observer),
+		patternObservers: make(map[string][]Observer),
+		parent:           parent,
+		children:         make([]*Blackboard, 0),
 	}
 	return b
 }
@@ -127,10 +196,68 @@ func (b *Blackboard) Start() {
 	}
 	b.enable = true
 	if b.parent != nil {
+		b.parent.childrenMutex.Lock()
 		b.parent.children = append(b.parent.children, b)
+		b.parent.childrenMutex.Unlock()
 	}
 }
 
+// SetSchema
+//
+//	@implement IBlackboardInternal.SetSchema
+//	@receiver b
+//	@param schema
+//	@param strict
+func (b *Blackboard) SetSchema(schema map[string]*config.BlackboardKeyCfg, strict bool) {
+	b.memoryMutex.Lock()
+	b.schema = schema
+	b.strict = strict
+	b.memoryMutex.Unlock()
+	for key, decl := range schema {
+		if decl == nil || decl.Default == nil {
+			continue
+		}
+		if _, ok := b.Get(key); !ok {
+			b.Set(key, decl.Default)
+		}
+	}
+}
+
+// checkType 严格模式下检查值是否符合声明的类型,未声明的键不检查
+//
+//	@receiver b
+//	@param key
+//	@param val
+//	@return error
+func (b *Blackboard) checkType(key string, val any) error {
+	b.memoryMutex.RLock()
+	defer b.memoryMutex.RUnlock()
+	return b.checkTypeLocked(key, val)
+}
+
+// checkTypeLocked 同 Blackboard.checkType ,调用方须持有 memoryMutex
+//
+//	@receiver b
+//	@param key
+//	@param val
+//	@return error
+func (b *Blackboard) checkTypeLocked(key string, val any) error {
+	decl, ok := b.schema[key]
+	if !b.strict || !ok || decl == nil {
+		return nil
+	}
+	return decl.Type.Check(val)
+}
+
+// Started
+//
+//	@implement IBlackboardInternal.Started
+//	@receiver b
+//	@return bool
+func (b *Blackboard) Started() bool {
+	return b.enable
+}
+
 // Stop
 //
 //	@implement IBlackboardInternal.Stop
@@ -143,6 +270,8 @@ func (b *Blackboard) Stop() {
 	b.enable = false
 	// 销毁所有数据,销毁所有监听
 	b.observers = map[string][]Observer{}
+	b.patternObservers = map[string][]Observer{}
+	b.patterns = patternTree{}
 	b.treesMemory = map[string]Memory{}
 	b.nodesData = map[string]*NodeMemory{}
 	b.memoryMutex.Lock()
@@ -150,7 +279,9 @@ func (b *Blackboard) Stop() {
 	b.memoryMutex.Unlock()
 	// 从父黑板中移除自己
 	if b.parent != nil {
+		b.parent.childrenMutex.Lock()
 		b.parent.children = lo.Reject(b.parent.children, func(v *Blackboard, _ int) bool { return v == b })
+		b.parent.childrenMutex.Unlock()
 	}
 }
 
@@ -174,6 +305,74 @@ func (b *Blackboard) RemoveObserver(key string, observer Observer) {
 	b.addOrRmObserver(false, key, observer)
 }
 
+// AddPatternObserver 非线程安全,请在树自己的线程内调用
+//
+//	@implement IBlackboardInternal.AddPatternObserver
+//	@receiver b
+//	@param pattern
+//	@param observer
+func (b *Blackboard) AddPatternObserver(pattern string, observer Observer) {
+	if !b.enable {
+		return
+	}
+	observers := b.patternObservers[pattern]
+	if lo.Contains(observers, observer) {
+		logger.Log.Debug("[blackboard]add pattern observer failed. observers already contained this observer", zap.String("pattern", pattern))
+		return
+	}
+	if len(observers) == 0 {
+		b.patterns.insert(pattern)
+	}
+	b.patternObservers[pattern] = append(observers, observer)
+}
+
+// RemovePatternObserver 非线程安全,请在树自己的线程内调用
+//
+//	@implement IBlackboardInternal.RemovePatternObserver
+//	@receiver b
+//	@param pattern
+//	@param observer
+func (b *Blackboard) RemovePatternObserver(pattern string, observer Observer) {
+	if !b.enable {
+		return
+	}
+	observers := b.patternObservers[pattern]
+	if !lo.Contains(observers, observer) {
+		logger.Log.Debug("[blackboard]remove pattern observer failed. observers not contained this observer", zap.String("pattern", pattern))
+		return
+	}
+	observers = lo.Reject(observers, func(v Observer, _ int) bool { return observer == v })
+	if len(observers) == 0 {
+		delete(b.patternObservers, pattern)
+		b.patterns.remove(pattern)
+		return
+	}
+	b.patternObservers[pattern] = observers
+}
+
+// matchObservers 监听 key 的所有监听函数,精确监听在前,模式监听在后,已去重
+//
+//	@receiver b
+//	@param key
+//	@return []Observer
+func (b *Blackboard) matchObservers(key string) []Observer {
+	observers := b.observers[key]
+	// 没有模式监听时与精确监听的开销相同
+	if len(b.patternObservers) == 0 {
+		return observers
+	}
+	// 限制容量,追加时复制一份,避免修改 observers 的底层数组
+	merged := observers[:len(observers):len(observers)]
+	b.patterns.match(key, func(pattern string) {
+		for _, ob := range b.patternObservers[pattern] {
+			if !lo.Contains(merged, ob) {
+				merged = append(merged, ob)
+			}
+		}
+	})
+	return merged
+}
+
 // addOrRmObserver 非线程安全,请在树自己的线程内调用
 //
 //	@receiver b
@@ -217,21 +416,119 @@ func (b *Blackboard) addOrRmObserver(add bool, key string, observer Observer) {
 //	@param oldVal
 //	@param newVal
 func (b *Blackboard) notify(op OpType, key string, oldVal any, newVal any) {
-	// TODO 可能会调用多次,尤其是条件节点首次set时其实不应该执行,这里后续需要优化合批
-	// 无论调用方是否在AI线程里,都兜底派发到AI线程,使监听函数在AI线程里串行
-	//id := util.NanoID()
-	//logger.Log.Debug("[blackboard]notify", zap.String("id", id), zap.String("key", key), zap.Any("oldVal", oldVal), zap.Any("newVal", newVal))
-	thread.GoByID(b.threadID, func() {
-		//logger.Log.Debug("[blackboard]notifyCall", zap.String("id", id))
-		if !b.enable {
-			return
+	// TODO 可能会调用多次,尤其是条件节点首次set时其实不应该执行.一次写入多个键时请使用 Blackboard.Batch 合批
+	// 无论调用方是否在AI线程里,都兜底派发到AI线程,使监听函数在AI线程里串行.新值在执行时重新读取
+	// 单个键的修改不经过合批,避免分配和去重的开销
+	b.goTask(func() {
+		b.fireOne(b, op, key, oldVal)
+	})
+	if b.hasChildren() {
+		b.notifyChildren(b, []blackboardChange{{key: key, oldVal: oldVal, op: op}})
+	}
+}
+
+// hasChildren 是否有子黑板
+//
+//	@receiver b
+//	@return bool
+func (b *Blackboard) hasChildren() bool {
+	b.childrenMutex.Lock()
+	defer b.childrenMutex.Unlock()
+	return len(b.children) > 0
+}
+
+// notifyChildren 把 origin 的变化通知到本黑板的所有子黑板(递归),在子黑板各自的线程执行其监听函数.
+//
+//	共享黑板一般不会被启动,故不依赖本黑板是否启动
+//	@receiver b
+//	@param origin 发生变化的黑板
+//	@param changes
+func (b *Blackboard) notifyChildren(origin *Blackboard, changes []blackboardChange) {
+	b.childrenMutex.Lock()
+	if len(b.children) == 0 {
+		b.childrenMutex.Unlock()
+		return
+	}
+	children := append([]*Blackboard(nil), b.children...)
+	b.childrenMutex.Unlock()
+	for _, child := range children {
+		child.goTask(func() {
+			child.fire(origin, changes)
+		})
+		child.notifyChildren(origin, changes)
+	}
+}
+
+// fire 在本黑板的线程执行监听函数,同一个监听函数只执行一次,收到的是它监听的键中最先修改的那个
+//
+//	@receiver b
+//	@param origin 发生变化的黑板,本黑板或祖先黑板.祖先黑板的键被本黑板或中间的黑板同名键遮盖时看不到变化,不通知
+//	@param changes
+func (b *Blackboard) fire(origin *Blackboard, changes []blackboardChange) {
+	if len(changes) == 1 {
+		b.fireOne(origin, changes[0].op, changes[0].key, changes[0].oldVal)
+		return
+	}
+	if !b.enable {
+		return
+	}
+	var fired []Observer
+	for _, change := range changes {
+		if b.shadowed(change.key, origin) {
+			continue
 		}
 		// 必须取最新值
-		newVal, _ = b.Get(key)
-		for _, ob := range b.observers[key] {
-			ob.Fire(op, key, oldVal, newVal)
+		newVal, _ := b.Get(change.key)
+		traceBlackboard(b.tracer, b.threadID, change.op, change.key, change.oldVal, newVal)
+		// 每次重新取监听列表,前面的监听函数可能移除了后面的
+		for _, ob := range b.matchObservers(change.key) {
+			if lo.Contains(fired, ob) {
+				continue
+			}
+			fired = append(fired, ob)
+			ob.Fire(change.op, change.key, change.oldVal, newVal)
 		}
-	})
+	}
+}
+
+// fireOne 只有一个键变化时的 Blackboard.fire ,无需去重
+//
+//	@receiver b
+//	@param origin
+//	@param op
+//	@param key
+//	@param oldVal
+func (b *Blackboard) fireOne(origin *Blackboard, op OpType, key string, oldVal any) {
+	if !b.enable {
+		return
+	}
+	if origin != b && b.shadowed(key, origin) {
+		return
+	}
+	// 必须取最新值
+	newVal, _ := b.Get(key)
+	traceBlackboard(b.tracer, b.threadID, op, key, oldVal, newVal)
+	for _, ob := range b.matchObservers(key) {
+		ob.Fire(op, key, oldVal, newVal)
+	}
+}
+
+// shadowed 祖先黑板 origin 的键是否被本黑板或中间的黑板的同名键遮盖
+//
+//	@receiver b
+//	@param key
+//	@param origin
+//	@return bool
+func (b *Blackboard) shadowed(key string, origin *Blackboard) bool {
+	for bb := b; bb != nil && bb != origin; bb = bb.parent {
+		bb.memoryMutex.RLock()
+		_, ok := bb.userMemory[key]
+		bb.memoryMutex.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Get
@@ -290,6 +587,10 @@ func (b *Blackboard) GetDuration(key string) (time.Duration, bool) {
 //	@param key
 //	@param val
 func (b *Blackboard) Set(key string, val any) {
+	if err := b.checkType(key, val); err != nil {
+		logger.Log.Error("[blackboard]set rejected by schema", zap.String("key", key), zap.Error(err))
+		return
+	}
 	// 优先设置父黑板
 	if b.parent != nil {
 		_, ok := b.parent.Get(key)
@@ -347,7 +648,7 @@ type IBlackboard interface {
 	//  @return bool
 	GetDuration(key string) (time.Duration, bool)
 	// Set 设置KV(用户域)
-	//  线程安全
+	//  线程安全.严格模式下值与声明的类型不符时拒绝写入并打印错误日志,参看 IBlackboardInternal.SetSchema
 	//  @receiver b
 	//  @param key
 	//  @param val
@@ -357,6 +658,17 @@ type IBlackboard interface {
 	//  @receiver b
 	//  @param key
 	Del(key string)
+	// Batch 批量读写,回调内对本黑板的修改在同一把锁内完成,其他线程不会看到中间状态.
+	//  只保证每个黑板各自原子:父黑板中已存在的键写到父黑板,在本黑板解锁后另以父黑板的 SetMany 提交和通知,
+	//  其间其他线程可能已看到本黑板的修改而看不到父黑板的;Batch 返回时两者均已写入.
+	//  每个键只通知一次(最早的旧值,最新的新值),同一个监听函数在一批修改中只执行一次.
+	//  线程安全.回调内只能通过 tx 读写本黑板,否则会死锁;回调panic时已做的修改不会回滚
+	//  @param fn
+	Batch(fn func(tx IBlackboardTx))
+	// SetMany 批量设置KV,同 Batch .按键的字典序写入和通知
+	//  线程安全
+	//  @param kvs
+	SetMany(kvs map[string]any)
 }
 
 // IBlackboardInternal 框架内或自定义节点时使用的黑板,从 IBlackboard 转化来
@@ -367,11 +679,28 @@ type IBlackboardInternal interface {
 	// ThreadID 获取线程ID
 	//  @return int
 	ThreadID() int
+	// SetExecutor 设置监听函数的执行器,为空则派发到 ThreadID 对应的线程
+	//  私有,框架内部使用
+	//  @param executor
+	SetExecutor(executor Executor)
+	// SetTracer 设置所属 IBrain 的追踪器
+	//  私有,框架内部使用
+	//  @param tracer
+	SetTracer(tracer Tracer)
 	// Start 启动,将会开始监听kv
 	//  私有,框架内部使用
 	//  非线程安全
 	//  @receiver b
 	Start()
+	// SetSchema 设置黑板键声明,并为黑板(包括父黑板)中没有的键写入默认值
+	//  私有,框架内部使用
+	//  线程安全
+	//  @param schema 可为空
+	//  @param strict 是否严格模式,是则 Set 拒绝与声明类型不符的值
+	SetSchema(schema map[string]*config.BlackboardKeyCfg, strict bool)
+	// Started 是否已启动
+	//  @return bool
+	Started() bool
 	// Stop 停止,将会停止监听kv
 	//  私有,框架内部使用
 	//  非线程安全
@@ -379,6 +708,17 @@ type IBlackboardInternal interface {
 	Stop()
 	AddObserver(key string, observer Observer)
 	RemoveObserver(key string, observer Observer)
+	// AddPatternObserver 监听匹配模式的所有键,如 "enemy.*" ,参看 PatternWildcard .监听函数收到的是实际变化的键
+	//  同一个监听函数同时监听了多个匹配的键或模式时,每次变化只执行一次
+	//  非线程安全
+	//  @param pattern
+	//  @param observer
+	AddPatternObserver(pattern string, observer Observer)
+	// RemovePatternObserver 移除模式监听
+	//  非线程安全
+	//  @param pattern
+	//  @param observer
+	RemovePatternObserver(pattern string, observer Observer)
 	// TreeMemory 树数据
 	//  @param rootID
 	//  @return Memory
@@ -391,4 +731,12 @@ type IBlackboardInternal interface {
 	//  @param nodeID
 	//  @return *NodeMemory
 	NodeMemory(nodeID string) *NodeMemory
+	// UserMemory 用户域数据的拷贝,不包括父黑板
+	//  线程安全
+	//  @return Memory
+	UserMemory() Memory
+	// RestoreUserMemory 整体替换用户域数据,不会通知监听函数.一般用于从快照恢复
+	//  线程安全
+	//  @param mem
+	RestoreUserMemory(mem Memory)
 }
@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"time"
 
-	"github.com/alkaid/timingwheel"
+	"github.com/alkaid/behavior/timer"
 )
 
 type DelegateMeta struct {
@@ -29,6 +29,10 @@ type IBrain interface {
 	//  @return <-chan
 	FinishChan() <-chan *FinishEvent
 	SetFinishChan(finishChan chan *FinishEvent)
+	// SetTracer 设置追踪器,与全局追踪器 SetTracer 同时生效,为空则取消
+	//  非线程安全,请在运行前或树自己的线程内调用
+	//  @param tracer
+	SetTracer(tracer Tracer)
 	// Blackboard 获取黑板
 	//  @return IBlackboard
 	Blackboard() IBlackboard
@@ -59,17 +63,57 @@ type IBrain interface {
 	// @param tag
 	// @param force 是否强制终止正在运行的树
 	Run(tag string, force bool) error
-	// DynamicDecorate 给正在运行的树动态挂载子树
+	// RunWithParams 以模板参数特化树并异步启动
 	//
-	//	非线程安全,调用方自己保证
+	// @param tag
+	// @param params 模板参数,为空时同 Run
+	// @param force 是否强制终止正在运行的树
+	RunWithParams(tag string, params map[string]any, force bool) error
+	// DynamicDecorate 给正在运行的树动态挂载子树,容器可以在主树或已挂载的任意层子树上
+	//
+	//	非线程安全,须在 brain 线程调用
 	//
 	// @receiver b
-	// @param containerTag 动态子树容器的tag
+	// @param containerPath 动态子树容器的路径,格式为 [子树tag/.../]容器tag
 	// @param subtreeTag 子树的tag
 	// @return error
-	DynamicDecorate(containerTag string, subtreeTag string) error
-	Cron(interval time.Duration, randomDeviation time.Duration, task func()) *timingwheel.Timer
-	After(interval time.Duration, randomDeviation time.Duration, task func()) *timingwheel.Timer
+	DynamicDecorate(containerPath string, subtreeTag string) error
+	// DynamicDecorateWithParams 同 DynamicDecorate,子树为模板时以 params 特化
+	//
+	//	非线程安全,须在 brain 线程调用
+	//
+	// @param containerPath
+	// @param subtreeTag
+	// @param params 模板参数,可为空
+	// @return error
+	DynamicDecorateWithParams(containerPath string, subtreeTag string, params map[string]any) error
+	// DynamicUnmount 卸载动态挂载的子树,容器恢复为配置的默认子树
+	//
+	//	非线程安全,须在 brain 线程调用
+	//
+	// @param containerPath 动态子树容器的路径
+	// @return error
+	DynamicUnmount(containerPath string) error
+	// SafeDynamicDecorate 同 DynamicDecorate
+	//
+	//	线程安全
+	//
+	// @param containerPath
+	// @param subtreeTag
+	// @param errChan 执行完成后写入结果,可为空
+	SafeDynamicDecorate(containerPath string, subtreeTag string, errChan chan error)
+	// SafeDynamicUnmount 同 DynamicUnmount
+	//
+	//	线程安全
+	//
+	// @param containerPath
+	// @param errChan 执行完成后写入结果,可为空
+	SafeDynamicUnmount(containerPath string, errChan chan error)
+	// Now 当前时间,由 IBrain 使用的 timer.Clock 提供
+	//  @return time.Time
+	Now() time.Time
+	Cron(interval time.Duration, randomDeviation time.Duration, task func()) timer.Timer
+	After(interval time.Duration, randomDeviation time.Duration, task func()) timer.Timer
 }
 
 // IBrainInternal 框架内部使用的 Brain
@@ -90,5 +134,13 @@ type IBrainInternal interface {
 	GetDelegates() map[string]any
 	RWFinishChan() chan *FinishEvent
 	SetRunningTree(root IRoot)
+	// MigrationTarget 热更迁移的目标树,无需迁移时返回nil.仅在 brain 线程调用
+	//  @param root 当前运行的主树
+	//  @param aborting 是否处于中断流程,为true时仅在 brain 主动请求立即迁移时返回目标
+	//  @return IRoot
+	MigrationTarget(root IRoot, aborting bool) IRoot
 	LogContext() map[string]any
+	// Tracer 获取 IBrain 的追踪器,未设置时返回nil
+	//  @return Tracer
+	Tracer() Tracer
 }
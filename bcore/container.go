@@ -1,6 +1,9 @@
 package bcore
 
-import "go.uber.org/zap"
+import (
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
 
 // IContainer 容器:可以挂载子节点的节点
 type IContainer interface {
@@ -68,5 +71,9 @@ func (c *Container) ChildFinished(brain IBrain, child INode, succeeded bool) {
 		c.Log(brain).Error("A ChildID of a Container was stopped while the container was inactive!")
 		return
 	}
+	if tracing(brain) {
+		state := c.State(brain)
+		trace(brain, &TraceEvent{Type: TraceChildFinished, Node: c.NodeWorkerAsNode(), PrevState: state, State: state, Result: lo.If(succeeded, ResultSucceeded).Else(ResultFailed), Child: child})
+	}
 	c.IContainerWorker.OnChildFinished(brain, child, succeeded)
 }
@@ -123,6 +123,11 @@ func (d *Decorator) Finish(brain IBrain, succeeded bool) {
 		d.Memory(brain).RequestDynamicChild = nil
 		d.Memory(brain).DynamicChild.DynamicMount(brain, d)
 	}
+	// 动态卸载子节点
+	if d.IDecoratorWorker.CanDynamicDecorate() && d.Memory(brain).RequestDynamicUnmount {
+		d.Memory(brain).DynamicChild = nil
+		d.Memory(brain).RequestDynamicUnmount = false
+	}
 	d.Container.Finish(brain, succeeded)
 }
 
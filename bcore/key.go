@@ -0,0 +1,104 @@
+package bcore
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alkaid/behavior/logger"
+)
+
+// Key [T any] 带类型的黑板键,避免到处手写键名和类型断言
+//
+//	hp := bcore.NewKey[int]("hp")
+//	hp.Set(bb, 10)
+//	v, ok := hp.Get(bb)
+type Key[T any] struct {
+	name string
+}
+
+// NewKey [T any] 声明带类型的黑板键
+//
+//	@param name 键名
+//	@return Key[T]
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name 键名
+//
+//	@receiver k
+//	@return string
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Get 读取并转换为 T.数字之间互相转换(如JSON解析出的 float64 读取为 int), time.Duration 同 IBlackboard.GetDuration
+//
+//	@receiver k
+//	@param bb
+//	@return T 不存在或类型不符时为零值
+//	@return bool 不存在或类型不符时为false,类型不符时打印错误日志
+func (k Key[T]) Get(bb IBlackboard) (T, bool) {
+	var zero T
+	val, ok := bb.Get(k.name)
+	if !ok || val == nil {
+		return zero, false
+	}
+	if result, ok := val.(T); ok {
+		return result, true
+	}
+	if _, ok := any(zero).(time.Duration); ok {
+		d, ok := bb.GetDuration(k.name)
+		return any(d).(T), ok
+	}
+	v := reflect.ValueOf(val)
+	typ := reflect.TypeOf(zero)
+	if typ != nil && isNumberKind(v.Kind()) && isNumberKind(typ.Kind()) {
+		return v.Convert(typ).Interface().(T), true
+	}
+	logger.Log.Error("", zap.Error(ErrConvertGenericType), zap.String("key", k.name), zap.String("type", fmt.Sprintf("%T", zero)), zap.Any("value", val))
+	return zero, false
+}
+
+// GetOr 读取并转换为 T,不存在或类型不符时返回 def
+//
+//	@receiver k
+//	@param bb
+//	@param def
+//	@return T
+func (k Key[T]) GetOr(bb IBlackboard, def T) T {
+	if v, ok := k.Get(bb); ok {
+		return v
+	}
+	return def
+}
+
+// Set 写入
+//
+//	@receiver k
+//	@param bb
+//	@param val
+func (k Key[T]) Set(bb IBlackboard, val T) {
+	bb.Set(k.name, val)
+}
+
+// Del 删除
+//
+//	@receiver k
+//	@param bb
+func (k Key[T]) Del(bb IBlackboard) {
+	bb.Del(k.name)
+}
+
+// Has 是否存在
+//
+//	@receiver k
+//	@param bb
+//	@return bool
+func (k Key[T]) Has(bb IBlackboard) bool {
+	_, ok := bb.Get(k.name)
+	return ok
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"time"
 
-	"github.com/alkaid/timingwheel"
+	"github.com/alkaid/behavior/timer"
 
 	"github.com/alkaid/behavior/logger"
 	"go.uber.org/zap"
@@ -47,28 +47,32 @@ func MapValue[T any](m Memory, key string) (T, bool) {
 
 // NodeMemory 节点数据
 type NodeMemory struct {
-	Ext                 Memory     // 扩展数据,给框架之外的自定义节点使用
-	State               NodeState  // 节点状态
-	MountParent         IContainer // 动态挂载的父节点,仅 Root 有效
-	DynamicChild        INode      // 动态挂载的子节点
-	RequestDynamicChild INode      // 请求挂载的子节点,延迟到旧的分支执行完成后才真正挂载到 DynamicChild
-	DynamicRoot         IRoot      // 动态子树的根节点
-	Observing           bool       // 是否监听中,仅 ObservingDecorator 及其派生类有效
+	Ext                   Memory     // 扩展数据,给框架之外的自定义节点使用
+	State                 NodeState  // 节点状态
+	MountParent           IContainer // 动态挂载的父节点,仅 Root 有效
+	DynamicChild          INode      // 动态挂载的子节点
+	RequestDynamicChild   INode      // 请求挂载的子节点,延迟到旧的分支执行完成后才真正挂载到 DynamicChild
+	RequestDynamicUnmount bool       // 请求卸载动态挂载的子节点,延迟到旧的分支执行完成后才真正卸载
+	DynamicRoot           IRoot      // 动态子树的根节点
+	Observing             bool       // 是否监听中,仅 ObservingDecorator 及其派生类有效
 	// 根据节点类型意义不同:
 	//  1.非随机组合节点:当前运行中的子节点索引;
 	//  2.随机组合节点:完成了几个子节点;
 	//  3.循环装饰器:当前为第几次循环
 	CurrIndex        int
-	ChildrenOrder    []int              // 孩子节点排序索引
-	Parallel         *ParallelMemory    // 并发节点的数据
-	CronTask         *timingwheel.Timer // 定时任务
-	DefaultObserver  Observer           // 默认监听函数
-	Cooling          bool               // 是否cd中
-	LimitReached     bool               // 是否达到限制
-	DecoratedDone    bool               // 被装饰节点是否完成
-	DecoratedSuccess bool               // 被装饰节点是否成功
-	Elapsed          time.Duration      // 启动后流逝的时间
-	Restarting       bool               // 是否正在重启,是 State 为 NodeStateAborting 时的一个细分状态
+	ChildrenOrder    []int           // 孩子节点排序索引
+	Parallel         *ParallelMemory // 并发节点的数据
+	CronTask         timer.Timer     // 定时任务
+	TimeoutTask      timer.Timer     // 超时定时任务,与 CronTask 同时存在时使用
+	TimeoutRemaining *time.Duration  // 快照时 TimeoutTask 的剩余时间,仅在恢复快照回调 INodeWorker.OnRestore 时有效,为空表示没有
+	DefaultObserver  Observer        // 默认监听函数
+	Cooling          bool            // 是否cd中
+	LimitReached     bool            // 是否达到限制
+	DecoratedDone    bool            // 被装饰节点是否完成
+	DecoratedSuccess bool            // 被装饰节点是否成功
+	Elapsed          time.Duration   // 启动后流逝的时间
+	Restarting       bool            // 是否正在重启,是 State 为 NodeStateAborting 时的一个细分状态
+	Properties       any             // 绑定黑板键后解析的属性,节点启动时生成,参看 Node.BoundProperties
 }
 
 func NewNodeMemory() *NodeMemory {
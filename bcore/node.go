@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/alkaid/behavior/internal"
+	"reflect"
 	"time"
 
 	"github.com/alkaid/behavior/util"
@@ -45,10 +46,18 @@ type INode interface {
 	//  @param brain
 	//  @return *NodeMemory
 	Memory(brain IBrain) *NodeMemory
+	// Blackboard 节点所见的黑板,位于配置了端口映射或隔离作用域的子树中时,键按子树容器的配置转换
+	//  @param brain
+	//  @return IBlackboardInternal
+	Blackboard(brain IBrain) IBlackboardInternal
 	ID() string
 	Title() string
 	Category() string
 	Properties() any
+	// BoundProperties 节点在 brain 中生效的属性.配置了黑板键绑定( BindingKey )的属性在节点启动时解析,否则同 Properties
+	//  @param brain
+	//  @return any
+	BoundProperties(brain IBrain) any
 	Name() string
 	RawCfg() *config.NodeCfg
 	Delegator() config.DelegatorCfg
@@ -131,11 +140,29 @@ type INodeWorker interface {
 	//  @param brain
 	//  @return string
 	OnString(brain IBrain) string
+	// OnRestore 从快照恢复节点数据后回调,用于重建定时任务和黑板监听等无法序列化的运行时资源
+	//  @param brain
+	//  @param timerRemaining 快照时定时任务 NodeMemory.CronTask 的剩余时间,<0表示没有定时任务
+	OnRestore(brain IBrain, timerRemaining time.Duration)
 }
 
 // BaseProperties 属性基类
 type BaseProperties struct{}
 
+// IPropertiesValidator 属性自校验,属性类可选实现,用于静态检查时发现取值越界等语义错误
+type IPropertiesValidator interface {
+	// Valid 校验属性取值
+	//  @return error
+	Valid() error
+}
+
+// IBlackboardKeysProvider 属性引用的黑板键,属性类可选实现,用于静态检查时与树的黑板键声明( config.TreeCfg .Blackboard )核对
+type IBlackboardKeysProvider interface {
+	// BlackboardKeys 引用的黑板键
+	//  @return map[string]config.KeyType 键->节点期望的值类型, config.KeyTypeAny 表示不限
+	BlackboardKeys() map[string]config.KeyType
+}
+
 var _ INode = (*Node)(nil)
 var _ INodeWorker = (*Node)(nil)
 
@@ -152,6 +179,7 @@ type Node struct {
 	title      string              // 描述
 	category   string              // 类型
 	properties any                 // 自定义属性
+	bindings   []propertyBinding   // 绑定了黑板键的属性
 	delegator  config.DelegatorCfg // 委托
 	cfg        *config.NodeCfg     // 原始配置
 }
@@ -171,7 +199,7 @@ func (n *Node) Init(cfg *config.NodeCfg) error {
 	n.title = cfg.Title
 	n.category = cfg.Category
 	n.properties = cfg.Properties
-	if cfg.Delegator.Method != "" {
+	if cfg.Delegator.Method != "" || cfg.Delegator.Script != "" {
 		n.delegator = config.DelegatorCfg{
 			Target: cfg.Delegator.Target,
 			Method: cfg.Delegator.Method,
@@ -179,7 +207,10 @@ func (n *Node) Init(cfg *config.NodeCfg) error {
 		}
 		// 预编译脚本
 		if n.delegator.Script != "" {
-			script.RegisterCode(n.id, n.delegator.Script)
+			err = script.RegisterCode(n.id, n.delegator.Script)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	n.cfg = cfg
@@ -197,10 +228,18 @@ func (n *Node) InitNodeWorker(worker INodeWorker) error {
 	if properties == nil {
 		n.properties = nil
 	}
-	err := json.Unmarshal(n.properties.(json.RawMessage), properties)
+	raw, bindings, err := SplitPropertyBindings(n.properties.(json.RawMessage))
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(raw, properties)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	n.bindings, err = newPropertyBindings(properties, bindings)
+	if err != nil {
+		return err
+	}
 	n.properties = properties
 	return nil
 }
@@ -215,7 +254,15 @@ func (n *Node) CopyTo(target *Node) {
 	target.delegator = n.delegator
 	target.name = n.name
 	target.properties = n.properties
+	target.bindings = n.bindings
 	target.id = util.NanoID()
+	// 脚本以节点ID索引,拷贝的节点需要重新注册.源节点已编译成功过,不会出错
+	if n.delegator.Script != "" {
+		err := script.RegisterCode(target.id, n.delegator.Script)
+		if err != nil {
+			n.Log(nil).Error("copy script error", zap.Error(err))
+		}
+	}
 }
 
 // NodeWorker
@@ -247,6 +294,19 @@ func (n *Node) Memory(brain IBrain) *NodeMemory {
 	return brain.Blackboard().(IBlackboardInternal).NodeMemory(n.id)
 }
 
+// Blackboard 节点所见的黑板
+//
+//	@implement INode.Blackboard
+//	@receiver n
+//	@param brain
+//	@return IBlackboardInternal
+func (n *Node) Blackboard(brain IBrain) IBlackboardInternal {
+	if n.root == nil {
+		return brain.Blackboard().(IBlackboardInternal)
+	}
+	return scopedBlackboard(brain, n.root)
+}
+
 func (n *Node) RawCfg() *config.NodeCfg {
 	return n.cfg
 }
@@ -277,6 +337,44 @@ func (n *Node) Category() string {
 func (n *Node) Properties() any {
 	return n.properties
 }
+
+// BoundProperties
+//
+//	@implement INode.BoundProperties
+//	@receiver n
+//	@param brain 为空时返回配置的属性
+//	@return any
+func (n *Node) BoundProperties(brain IBrain) any {
+	if len(n.bindings) == 0 || brain == nil {
+		return n.properties
+	}
+	mem := n.Memory(brain)
+	// 从快照恢复等未经启动的情况下按当前黑板解析
+	if mem.Properties == nil {
+		mem.Properties = n.bindProperties(brain)
+	}
+	return mem.Properties
+}
+
+// bindProperties 拷贝配置的属性并以黑板中的值替换绑定的字段
+//
+//	@receiver n
+//	@param brain
+//	@return any
+func (n *Node) bindProperties(brain IBrain) any {
+	src := reflect.ValueOf(n.properties).Elem()
+	dst := reflect.New(src.Type())
+	dst.Elem().Set(src)
+	bb := n.Blackboard(brain)
+	for _, binding := range n.bindings {
+		err := assignBinding(bb, binding.key, dst.Elem().FieldByIndex(binding.index))
+		if err != nil {
+			n.Log(brain).Error("bind property failed,use configured value", zap.String("property", binding.name), zap.String("key", binding.key), zap.Error(err))
+			dst.Elem().FieldByIndex(binding.index).Set(src.FieldByIndex(binding.index))
+		}
+	}
+	return dst.Interface()
+}
 func (n *Node) State(brain IBrain) NodeState {
 	return brain.Blackboard().(IBlackboardInternal).NodeMemory(n.id).State
 }
@@ -366,13 +464,18 @@ func (n *Node) SetUpstream(brain IBrain, upstream INode) {
 //	@receiver n
 //	@param brain
 func (n *Node) Start(brain IBrain) {
-	// TODO debug info
 	nodeData := brain.Blackboard().(IBlackboardInternal).NodeMemory(n.id)
 	if nodeData.State != NodeStateInactive {
 		n.Log(brain).Error("can only start inactive nodes")
 		return
 	}
 	nodeData.State = NodeStateActive
+	if len(n.bindings) > 0 {
+		nodeData.Properties = n.bindProperties(brain)
+	}
+	if tracing(brain) {
+		trace(brain, &TraceEvent{Type: TraceStart, Node: n.NodeWorkerAsNode(), PrevState: NodeStateInactive, State: NodeStateActive})
+	}
 	n.INodeWorker.OnStart(brain)
 }
 
@@ -388,6 +491,9 @@ func (n *Node) Abort(brain IBrain) {
 		return
 	}
 	nodeData.State = NodeStateAborting
+	if tracing(brain) {
+		trace(brain, &TraceEvent{Type: TraceAbort, Node: n.NodeWorkerAsNode(), PrevState: NodeStateActive, State: NodeStateAborting})
+	}
 	n.INodeWorker.OnAbort(brain)
 }
 
@@ -403,9 +509,12 @@ func (n *Node) Finish(brain IBrain, succeeded bool) {
 		n.Log(brain).Error("called 'Finish' while in state NodeStateInactive, something is wrong!")
 		return
 	}
+	prevState := nodeData.State
 	nodeData.State = NodeStateInactive
 	n.Log(brain).Debug("Finish", zap.Bool("succeeded", succeeded))
-	// TODO debug info
+	if tracing(brain) {
+		trace(brain, &TraceEvent{Type: TraceFinish, Node: n.NodeWorkerAsNode(), PrevState: prevState, State: NodeStateInactive, Result: lo.If(succeeded, ResultSucceeded).Else(ResultFailed)})
+	}
 	parent := n.Parent(brain)
 	if parent != nil {
 		parent.ChildFinished(brain, n, succeeded)
@@ -413,7 +522,12 @@ func (n *Node) Finish(brain IBrain, succeeded bool) {
 }
 
 func (n *Node) Update(brain IBrain, eventType EventType, delta time.Duration) Result {
-	return n.OnUpdate(brain, eventType, delta)
+	ret := n.OnUpdate(brain, eventType, delta)
+	if tracing(brain) {
+		state := n.State(brain)
+		trace(brain, &TraceEvent{Type: TraceUpdate, Node: n.NodeWorkerAsNode(), PrevState: state, State: state, Result: ret, EventType: eventType})
+	}
+	return ret
 }
 
 func (n *Node) OnUpdate(brain IBrain, eventType EventType, delta time.Duration) Result {
@@ -433,6 +547,8 @@ func (n *Node) OnUpdate(brain IBrain, eventType EventType, delta time.Duration)
 		switch v := out.(type) {
 		case bool:
 			return lo.If(v, ResultSucceeded).Else(ResultFailed)
+		case int:
+			return Result(v)
 		case int64:
 			return Result(v)
 		case Result:
@@ -462,7 +578,7 @@ func (n *Node) OnUpdate(brain IBrain, eventType EventType, delta time.Duration)
 
 func (n *Node) scriptEnv(brain IBrain, eventType EventType, delta time.Duration) map[string]any {
 	env := brain.(IBrainInternal).GetDelegates()
-	env["blackboard"] = brain.Blackboard()
+	env["blackboard"] = scriptBlackboard{IBlackboard: n.Blackboard(brain)}
 	env["eventType"] = eventType
 	env["delta"] = delta
 	return env
@@ -544,6 +660,15 @@ func (n *Node) OnString(brain IBrain) string {
 	return n.name + "(" + n.title + ")"
 }
 
+// OnRestore
+//
+//	@implement INodeWorker.OnRestore
+//	@receiver n
+//	@param brain
+//	@param timerRemaining
+func (n *Node) OnRestore(brain IBrain, timerRemaining time.Duration) {
+}
+
 type LogOption = func(brain IBrain, logg *zap.Logger) *zap.Logger
 
 func LogWithUpstream(brain IBrain, logg *zap.Logger) *zap.Logger {
@@ -1,21 +1,56 @@
 package bcore
 
-import "go.uber.org/zap"
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
 
 // IObservingProperties 观察者装饰器属性
 type IObservingProperties interface {
 	GetAbortMode() AbortMode
 }
 
+// IObservePatternsProperties 额外监听的黑板键模式,属性类可选实现,由 ObservingDecorator 负责监听
+type IObservePatternsProperties interface {
+	GetObservePatterns() []string
+}
+
 // ObservingProperties 观察者装饰器属性
 type ObservingProperties struct {
 	AbortMode AbortMode `json:"abortMode"`
+	// ObservePatterns 额外监听的黑板键模式,如 "enemy.*" ,匹配的键变化时重新评估条件,参看 PatternWildcard .可为空
+	ObservePatterns []string `json:"observePatterns"`
 }
 
 func (o *ObservingProperties) GetAbortMode() AbortMode {
 	return o.AbortMode
 }
 
+func (o *ObservingProperties) GetObservePatterns() []string {
+	return o.ObservePatterns
+}
+
+// Valid
+//
+//	@implement IPropertiesValidator.Valid
+//	@receiver o
+//	@return error
+func (o *ObservingProperties) Valid() error {
+	if o.AbortMode < AbortModeNone || o.AbortMode > AbortModeBoth {
+		return errors.New(fmt.Sprintf("abortMode out of range,abortMode=%d", o.AbortMode))
+	}
+	for _, pattern := range o.ObservePatterns {
+		if pattern == "" {
+			return errors.New("observe pattern cannot be empty")
+		}
+	}
+	return nil
+}
+
 // ObservingDecorator 观察者装饰器,实现了对条件的监听,和条件变化时的各种中断模式
 //
 //	 节点处于启用状态且条件不再被满足：
@@ -90,6 +125,7 @@ func (o *ObservingDecorator) OnStart(brain IBrain) {
 		if !o.Memory(brain).Observing {
 			o.Memory(brain).Observing = true
 			o.IObservingWorker.StartObserving(brain)
+			o.observePatterns(brain, true)
 		}
 	}
 	if !o.IObservingWorker.ConditionMet(brain) {
@@ -99,6 +135,20 @@ func (o *ObservingDecorator) OnStart(brain IBrain) {
 	}
 }
 
+// OnRestore 恢复监听
+//
+//	@override Node.OnRestore
+//	@receiver o
+//	@param brain
+//	@param timerRemaining
+func (o *ObservingDecorator) OnRestore(brain IBrain, timerRemaining time.Duration) {
+	o.Decorator.OnRestore(brain, timerRemaining)
+	if o.Memory(brain).Observing {
+		o.IObservingWorker.StartObserving(brain)
+		o.observePatterns(brain, true)
+	}
+}
+
 // OnAbort
 //
 //	@override Node.OnAbort
@@ -132,9 +182,36 @@ func (o *ObservingDecorator) stopObserving(brain IBrain) {
 		return
 	}
 	o.Memory(brain).Observing = false
+	// 先于子类移除,子类停止监听时会清空 DefaultObserver
+	o.observePatterns(brain, false)
 	o.IObservingWorker.StopObserving(brain)
 }
 
+// observePatterns 开始或停止监听属性中配置的键模式,与子类共用 NodeMemory.DefaultObserver ,同一次变化只评估一次
+//
+//	开始和停止监听须使用相同的模式,故不使用绑定黑板的属性
+//	@receiver o
+//	@param brain
+//	@param observe
+func (o *ObservingDecorator) observePatterns(brain IBrain, observe bool) {
+	props, ok := o.properties.(IObservePatternsProperties)
+	if !ok || len(props.GetObservePatterns()) == 0 {
+		return
+	}
+	memory := o.Memory(brain)
+	if memory.DefaultObserver == nil {
+		memory.DefaultObserver = o.NewBlackboardObserver(brain)
+	}
+	bb := o.Blackboard(brain)
+	for _, pattern := range props.GetObservePatterns() {
+		if observe {
+			bb.AddPatternObserver(pattern, memory.DefaultObserver)
+		} else {
+			bb.RemovePatternObserver(pattern, memory.DefaultObserver)
+		}
+	}
+}
+
 // OnCompositeAncestorFinished
 //
 //	@override Node.OnCompositeAncestorFinished
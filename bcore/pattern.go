@@ -0,0 +1,182 @@
+package bcore
+
+import (
+	"strings"
+)
+
+// PatternWildcard 黑板键模式的通配符,匹配任意长度(包括0)的任意字符,包括分隔符 "."
+//
+//	如 "enemy.*" 匹配 enemy. 开头的所有键, "enemy.*.hp" 匹配 enemy.1.hp 和 enemy.boss.2.hp
+const PatternWildcard = "*"
+
+// IsPatternKey 是否为含通配符的键模式
+//
+//	@param key
+//	@return bool
+func IsPatternKey(key string) bool {
+	return strings.Contains(key, PatternWildcard)
+}
+
+// MatchPattern 键是否匹配模式
+//
+//	@param pattern
+//	@param key
+//	@return bool
+func MatchPattern(pattern string, key string) bool {
+	// 按通配符切分后依次查找各段,首段须为前缀,末段须为后缀
+	parts := strings.Split(pattern, PatternWildcard)
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return len(key) >= len(last) && strings.HasSuffix(key, last)
+}
+
+// patternLiteral 模式中第一个通配符之前的字面前缀
+//
+//	@param pattern
+//	@return string
+func patternLiteral(pattern string) string {
+	if i := strings.Index(pattern, PatternWildcard); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// patternTree 以字面前缀为键的基数树(压缩前缀树),用于查找可能匹配某个键的模式而不必遍历所有模式
+//
+//	非线程安全
+type patternTree struct {
+	root patternNode
+}
+
+type patternNode struct {
+	label    string         // 从父节点到本节点的边
+	children []*patternNode // 子节点,边的首字节互不相同
+	patterns []string       // 字面前缀恰好为从根到本节点路径的模式
+}
+
+// insert 添加模式,已存在时忽略
+//
+//	@receiver t
+//	@param pattern
+func (t *patternTree) insert(pattern string) {
+	n := &t.root
+	rest := patternLiteral(pattern)
+	for rest != "" {
+		child := n.child(rest[0])
+		if child == nil {
+			n.children = append(n.children, &patternNode{label: rest, patterns: []string{pattern}})
+			return
+		}
+		common := commonPrefixLen(child.label, rest)
+		// 边只匹配了一部分,拆分为公共部分和剩余部分
+		if common < len(child.label) {
+			split := &patternNode{label: child.label[common:], children: child.children, patterns: child.patterns}
+			child.label = child.label[:common]
+			child.children = []*patternNode{split}
+			child.patterns = nil
+		}
+		n = child
+		rest = rest[common:]
+	}
+	for _, p := range n.patterns {
+		if p == pattern {
+			return
+		}
+	}
+	n.patterns = append(n.patterns, pattern)
+}
+
+// remove 移除模式,并裁剪不再有模式的叶子节点
+//
+//	@receiver t
+//	@param pattern
+func (t *patternTree) remove(pattern string) {
+	t.root.remove(patternLiteral(pattern), pattern)
+}
+
+// remove
+//
+//	@receiver n
+//	@param rest 剩余的字面前缀
+//	@param pattern
+//	@return bool 本节点是否已无模式且无子节点
+func (n *patternNode) remove(rest string, pattern string) bool {
+	if rest == "" {
+		for i, p := range n.patterns {
+			if p == pattern {
+				n.patterns = append(n.patterns[:i], n.patterns[i+1:]...)
+				break
+			}
+		}
+		return len(n.patterns) == 0 && len(n.children) == 0
+	}
+	child := n.child(rest[0])
+	if child == nil || !strings.HasPrefix(rest, child.label) {
+		return false
+	}
+	if child.remove(rest[len(child.label):], pattern) {
+		for i, c := range n.children {
+			if c == child {
+				n.children = append(n.children[:i], n.children[i+1:]...)
+				break
+			}
+		}
+	}
+	return len(n.patterns) == 0 && len(n.children) == 0
+}
+
+// match 查找匹配键的所有模式
+//
+//	@receiver t
+//	@param key
+//	@param fn 每个匹配的模式回调一次
+func (t *patternTree) match(key string, fn func(pattern string)) {
+	n := &t.root
+	rest := key
+	for {
+		for _, p := range n.patterns {
+			if MatchPattern(p, key) {
+				fn(p)
+			}
+		}
+		if rest == "" {
+			return
+		}
+		child := n.child(rest[0])
+		if child == nil || !strings.HasPrefix(rest, child.label) {
+			return
+		}
+		n = child
+		rest = rest[len(child.label):]
+	}
+}
+
+func (n *patternNode) child(c byte) *patternNode {
+	for _, child := range n.children {
+		if child.label[0] == c {
+			return child
+		}
+	}
+	return nil
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,206 @@
+package bcore
+
+import (
+	"time"
+)
+
+// ScopeKeyPrefix 隔离作用域内未映射的键在黑板中的前缀,完整格式为 $容器ID/键
+const ScopeKeyPrefix = "$"
+
+// IBlackboardPorts 提供黑板端口映射的子树容器,由 task.Subtree 实现
+type IBlackboardPorts interface {
+	// BlackboardPorts 端口映射
+	//  @return in 输入端口:子树内的键->父树的键,读取时生效
+	//  @return out 输出端口:子树内的键->父树的键,写入时生效
+	//  @return isolated 是否隔离作用域,是则未映射的键只在该容器内可见,否则与父树共用
+	BlackboardPorts() (in map[string]string, out map[string]string, isolated bool)
+}
+
+var _ IBlackboardInternal = (*portBlackboard)(nil)
+
+// portBlackboard 子树所见的黑板,按容器的端口映射转换键后读写外层黑板.
+//
+//	读取时依次查找 in,out 映射,写入时依次查找 out,in 映射,未映射的键隔离时加上作用域前缀,否则不变
+type portBlackboard struct {
+	IBlackboardInternal                   // 外层黑板,可能也是 portBlackboard
+	in                  map[string]string // 输入端口
+	out                 map[string]string // 输出端口
+	scope               string            // 隔离作用域前缀,为空表示不隔离
+}
+
+func (b *portBlackboard) readKey(key string) string {
+	if k, ok := b.in[key]; ok {
+		return k
+	}
+	if k, ok := b.out[key]; ok {
+		return k
+	}
+	return b.scope + key
+}
+
+func (b *portBlackboard) writeKey(key string) string {
+	if k, ok := b.out[key]; ok {
+		return k
+	}
+	if k, ok := b.in[key]; ok {
+		return k
+	}
+	return b.scope + key
+}
+
+// Get
+//
+//	@implement IBlackboard.Get
+//	@receiver b
+//	@param key
+//	@return any
+//	@return bool
+func (b *portBlackboard) Get(key string) (any, bool) {
+	return b.IBlackboardInternal.Get(b.readKey(key))
+}
+
+// GetDuration
+//
+//	@implement IBlackboard.GetDuration
+//	@receiver b
+//	@param key
+//	@return time.Duration
+//	@return bool
+func (b *portBlackboard) GetDuration(key string) (time.Duration, bool) {
+	return b.IBlackboardInternal.GetDuration(b.readKey(key))
+}
+
+// Set
+//
+//	@implement IBlackboard.Set
+//	@receiver b
+//	@param key
+//	@param val
+func (b *portBlackboard) Set(key string, val any) {
+	b.IBlackboardInternal.Set(b.writeKey(key), val)
+}
+
+// Del
+//
+//	@implement IBlackboard.Del
+//	@receiver b
+//	@param key
+func (b *portBlackboard) Del(key string) {
+	b.IBlackboardInternal.Del(b.writeKey(key))
+}
+
+// Batch 回调收到的 tx 同样按端口映射转换键
+//
+//	@implement IBlackboard.Batch
+//	@receiver b
+//	@param fn
+func (b *portBlackboard) Batch(fn func(tx IBlackboardTx)) {
+	b.IBlackboardInternal.Batch(func(tx IBlackboardTx) {
+		fn(&portBlackboardTx{tx: tx, b: b})
+	})
+}
+
+// SetMany
+//
+//	@implement IBlackboard.SetMany
+//	@receiver b
+//	@param kvs
+func (b *portBlackboard) SetMany(kvs map[string]any) {
+	mapped := make(map[string]any, len(kvs))
+	for key, val := range kvs {
+		mapped[b.writeKey(key)] = val
+	}
+	b.IBlackboardInternal.SetMany(mapped)
+}
+
+// portBlackboardTx 按端口映射转换键的 IBlackboardTx
+type portBlackboardTx struct {
+	tx IBlackboardTx
+	b  *portBlackboard
+}
+
+func (t *portBlackboardTx) Get(key string) (any, bool) {
+	return t.tx.Get(t.b.readKey(key))
+}
+
+func (t *portBlackboardTx) Set(key string, val any) {
+	t.tx.Set(t.b.writeKey(key), val)
+}
+
+func (t *portBlackboardTx) Del(key string) {
+	t.tx.Del(t.b.writeKey(key))
+}
+
+// AddObserver 监听映射后的键,监听函数收到的key为映射后的键
+//
+//	@implement IBlackboardInternal.AddObserver
+//	@receiver b
+//	@param key
+//	@param observer
+func (b *portBlackboard) AddObserver(key string, observer Observer) {
+	b.IBlackboardInternal.AddObserver(b.readKey(key), observer)
+}
+
+// RemoveObserver
+//
+//	@implement IBlackboardInternal.RemoveObserver
+//	@receiver b
+//	@param key
+//	@param observer
+func (b *portBlackboard) RemoveObserver(key string, observer Observer) {
+	b.IBlackboardInternal.RemoveObserver(b.readKey(key), observer)
+}
+
+// AddPatternObserver 模式不经过端口映射,隔离作用域时只加上作用域前缀,故只能匹配作用域内未映射的键
+//
+//	@implement IBlackboardInternal.AddPatternObserver
+//	@receiver b
+//	@param pattern
+//	@param observer
+func (b *portBlackboard) AddPatternObserver(pattern string, observer Observer) {
+	b.IBlackboardInternal.AddPatternObserver(b.scope+pattern, observer)
+}
+
+// RemovePatternObserver
+//
+//	@implement IBlackboardInternal.RemovePatternObserver
+//	@receiver b
+//	@param pattern
+//	@param observer
+func (b *portBlackboard) RemovePatternObserver(pattern string, observer Observer) {
+	b.IBlackboardInternal.RemovePatternObserver(b.scope+pattern, observer)
+}
+
+// scopedBlackboard 子树节点所见的黑板.从 root 往上回溯挂载的容器,由外到内逐层套上端口映射
+//
+//	@param brain
+//	@param root 节点所属树的根节点
+//	@return IBlackboardInternal 没有任何映射时返回 brain 的黑板
+func scopedBlackboard(brain IBrain, root IRoot) IBlackboardInternal {
+	var bb IBlackboardInternal = brain.Blackboard().(IBlackboardInternal)
+	var containers []IContainer
+	for r := root; r != nil; {
+		parent := r.Parent(brain)
+		if parent == nil {
+			break
+		}
+		containers = append(containers, parent)
+		r = parent.Root(brain)
+	}
+	for i := len(containers) - 1; i >= 0; i-- {
+		ports, ok := containers[i].(IBlackboardPorts)
+		if !ok {
+			continue
+		}
+		in, out, isolated := ports.BlackboardPorts()
+		if len(in) == 0 && len(out) == 0 && !isolated {
+			continue
+		}
+		view := &portBlackboard{IBlackboardInternal: bb, in: in, out: out}
+		if isolated {
+			view.scope = ScopeKeyPrefix + containers[i].ID() + "/"
+		}
+		bb = view
+	}
+	return bb
+}
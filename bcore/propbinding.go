@@ -0,0 +1,179 @@
+package bcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alkaid/behavior/util"
+)
+
+// BindingKey 属性绑定黑板键的标记.属性值写作 {"$bb":"键"} 时,节点启动时从节点所见的黑板( Node.Blackboard )读取该键作为属性值,
+// 可以带默认值 {"$bb":"键","default":值},黑板中没有该键时使用默认值,未配置默认值则为零值.
+//
+//	类型转换:可直接赋值的类型直接赋值;时长( time.Duration , util.Duration )支持 time.Duration,数字(纳秒)和 time.ParseDuration 格式的字符串;
+//	数字之间互相转换;其他情况按JSON转换.只支持顶层属性
+const BindingKey = "$bb"
+
+const bindingDefaultKey = "default"
+
+// propertyBinding 绑定了黑板键的属性
+type propertyBinding struct {
+	name  string // 属性的json名
+	key   string // 黑板键
+	index []int  // 属性在结构体中的字段索引
+}
+
+// SplitPropertyBindings 从属性JSON中分离黑板键绑定
+//
+//	@param raw 属性JSON
+//	@return json.RawMessage 移除绑定后的属性JSON,配置了默认值的替换为默认值,可直接解析为属性结构体
+//	@return map[string]string 属性json名->黑板键,没有绑定时为空
+//	@return error
+func SplitPropertyBindings(raw json.RawMessage) (json.RawMessage, map[string]string, error) {
+	if !bytes.Contains(raw, []byte(`"`+BindingKey+`"`)) {
+		return raw, nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	bindings := map[string]string{}
+	for name, value := range fields {
+		var binding map[string]json.RawMessage
+		if !bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) || json.Unmarshal(value, &binding) != nil {
+			continue
+		}
+		keyRaw, ok := binding[BindingKey]
+		if !ok {
+			continue
+		}
+		var key string
+		if err := json.Unmarshal(keyRaw, &key); err != nil || key == "" {
+			return nil, nil, errors.New(fmt.Sprintf("blackboard key of bound property must be a non-empty string,property=%s", name))
+		}
+		for k := range binding {
+			if k != BindingKey && k != bindingDefaultKey {
+				return nil, nil, errors.New(fmt.Sprintf("unknown field %s in bound property %s", k, name))
+			}
+		}
+		bindings[name] = key
+		if def, ok := binding[bindingDefaultKey]; ok {
+			fields[name] = def
+		} else {
+			delete(fields, name)
+		}
+	}
+	if len(bindings) == 0 {
+		return raw, nil, nil
+	}
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return stripped, bindings, nil
+}
+
+// newPropertyBindings 在属性结构体中查找绑定的字段
+//
+//	@param properties 属性结构体指针
+//	@param bindings 属性json名->黑板键
+//	@return []propertyBinding
+//	@return error 属性不是结构体指针或找不到字段
+func newPropertyBindings(properties any, bindings map[string]string) ([]propertyBinding, error) {
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+	typ := reflect.TypeOf(properties)
+	if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("properties must be a struct pointer to bind blackboard keys,type=%v", typ))
+	}
+	fields := map[string][]int{}
+	for _, f := range reflect.VisibleFields(typ.Elem()) {
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = f.Index
+		}
+	}
+	result := make([]propertyBinding, 0, len(bindings))
+	for name, key := range bindings {
+		index, ok := fields[name]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("bound property not found,property=%s,key=%s", name, key))
+		}
+		result = append(result, propertyBinding{name: name, key: key, index: index})
+	}
+	return result, nil
+}
+
+// assignBinding 读取黑板键的值并转换为字段的类型
+//
+//	@param bb
+//	@param key
+//	@param field
+//	@return error 类型无法转换
+func assignBinding(bb IBlackboard, key string, field reflect.Value) error {
+	val, ok := bb.Get(key)
+	// 黑板中没有该键时保留默认值
+	if !ok || val == nil {
+		return nil
+	}
+	v := reflect.ValueOf(val)
+	switch field.Addr().Interface().(type) {
+	case *time.Duration, *util.Duration:
+		d, err := toDuration(v)
+		if err != nil {
+			return err
+		}
+		if field.Type() == reflect.TypeOf(util.Duration{}) {
+			field.Set(reflect.ValueOf(util.Duration{Duration: d}))
+		} else {
+			field.SetInt(int64(d))
+		}
+		return nil
+	}
+	if v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+		return nil
+	}
+	if isNumberKind(v.Kind()) && isNumberKind(field.Kind()) {
+		field.Set(v.Convert(field.Type()))
+		return nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(data, field.Addr().Interface()))
+}
+
+func toDuration(v reflect.Value) (time.Duration, error) {
+	switch {
+	case v.Type() == reflect.TypeOf(util.Duration{}):
+		return v.Interface().(util.Duration).Duration, nil
+	case v.Kind() == reflect.String:
+		d, err := time.ParseDuration(v.String())
+		return d, errors.WithStack(err)
+	case isNumberKind(v.Kind()):
+		return time.Duration(v.Convert(reflect.TypeOf(int64(0))).Int()), nil
+	}
+	return 0, errors.New(fmt.Sprintf("cannot convert %v(%s) to duration", v.Interface(), v.Type()))
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/alkaid/behavior/util"
 
-	"github.com/alkaid/behavior/thread"
 	"github.com/samber/lo"
 
 	"go.uber.org/zap"
@@ -67,6 +66,11 @@ type IRoot interface {
 	// @param brain
 	// @param abortChan
 	SafeAbort(brain IBrain, abortChan chan *FinishEvent)
+	// Migrate 热更迁移:立即切换到 brain 提供的新版本树.子节点运行中则先中断,中断完成后再切换;等待下一轮时直接切换
+	//
+	//	非线程安全,须在 brain 线程调用
+	// @param brain
+	Migrate(brain IBrain)
 }
 
 var _ IRoot = (*Root)(nil)
@@ -147,7 +151,7 @@ func (r *Root) SafeStart(brain IBrain, force bool) {
 		logger.Log.Error("SafeStart unSupport subtree")
 		return
 	}
-	thread.GoByID(brain.Blackboard().(IBlackboardInternal).ThreadID(), func() {
+	brain.Go(func() {
 		if force && r.IsActive(brain) {
 			r.SetUpstream(brain, nil)
 			r.Abort(brain)
@@ -163,8 +167,8 @@ func (r *Root) SafeStart(brain IBrain, force bool) {
 //	@param brain
 func (r *Root) OnStart(brain IBrain) {
 	r.Decorator.OnStart(brain)
-	// 非子树要开启黑板监听
-	if !r.IsSubTree(brain) {
+	// 非子树要开启黑板监听,热更迁移时黑板已开启
+	if !r.IsSubTree(brain) && !brain.Blackboard().(IBlackboardInternal).Started() {
 		brain.Blackboard().(IBlackboardInternal).Start()
 	}
 	r.Decorated(brain).Start(brain)
@@ -181,7 +185,7 @@ func (r *Root) SafeAbort(brain IBrain, abortChan chan *FinishEvent) {
 		logger.Log.Error("SafeAbort unSupport subtree")
 		return
 	}
-	thread.GoByID(brain.Blackboard().(IBlackboardInternal).ThreadID(), func() {
+	brain.Go(func() {
 		event := &FinishEvent{
 			ID:        brain.ID(),
 			IsAbort:   true,
@@ -224,6 +228,10 @@ func (r *Root) OnChildFinished(brain IBrain, child INode, succeeded bool) {
 	r.stopTimer(brain)
 	// 如果是外部触发中断的,结束运行
 	if r.Memory(brain).State == NodeStateAborting {
+		// 热更迁移触发的中断,切换到新版本树
+		if !r.IsSubTree(brain) && r.migrate(brain, true) {
+			return
+		}
 		// 无论是否子树都要结束root,若是子树将回溯parent,否则整棵行为树终止运行。
 		r.Finish(brain, succeeded)
 		// 非子树要关闭黑板监听
@@ -235,7 +243,7 @@ func (r *Root) OnChildFinished(brain IBrain, child INode, succeeded bool) {
 		// 如果配置了一次性运行,同上
 		if r.IsSubTree(brain) {
 			r.Finish(brain, succeeded)
-		} else if r.properties.(iRootProperties).IsOnce() {
+		} else if r.BoundProperties(brain).(iRootProperties).IsOnce() {
 			// 若是主树且是一次性,结束root并关闭监听
 			r.Finish(brain, succeeded)
 			brain.Blackboard().(IBlackboardInternal).Stop()
@@ -272,14 +280,71 @@ func (r *Root) Finish(brain IBrain, succeeded bool) {
 	}
 }
 
+// OnRestore 恢复下一轮运行的定时任务
+//
+//	@override Node.OnRestore
+//	@receiver r
+//	@param brain
+//	@param timerRemaining
+func (r *Root) OnRestore(brain IBrain, timerRemaining time.Duration) {
+	r.Decorator.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && r.IsActive(brain) {
+		r.Memory(brain).CronTask = brain.After(timerRemaining, 0, r.getTaskFun(brain))
+	}
+}
+
+func (r *Root) getTaskFun(brain IBrain) func() {
+	return func() {
+		if !r.IsActive(brain) {
+			return
+		}
+		// 下一轮开始前是热更迁移的安全点
+		if !r.IsSubTree(brain) && r.migrate(brain, false) {
+			return
+		}
+		r.Decorated(brain).Start(brain)
+	}
+}
+
+// Migrate
+//
+//	@implement IRoot.Migrate
+//	@receiver r
+//	@param brain
+func (r *Root) Migrate(brain IBrain) {
+	if r.IsSubTree(brain) || !r.IsActive(brain) {
+		return
+	}
+	if r.Decorated(brain).IsInactive(brain) {
+		r.migrate(brain, false)
+		return
+	}
+	r.SetUpstream(brain, nil)
+	r.Abort(brain)
+}
+
+// migrate 若 brain 有热更迁移的目标树,静默结束当前树(不通知完成,不关闭黑板)并启动目标树
+//
+//	@receiver r
+//	@param brain
+//	@param aborting 是否处于中断流程
+//	@return bool 是否已迁移
+func (r *Root) migrate(brain IBrain, aborting bool) bool {
+	next := brain.(IBrainInternal).MigrationTarget(r, aborting)
+	if next == nil {
+		return false
+	}
+	r.stopTimer(brain)
+	r.Decorator.Finish(brain, true)
+	next.Start(brain)
+	return true
+}
+
 func (r *Root) startTimer(brain IBrain) {
-	r.Memory(brain).CronTask = brain.After(r.properties.(iRootProperties).GetLoopInterval(),
-		r.properties.(iRootProperties).GetRandomDeviation(),
-		func() {
-			if r.IsActive(brain) {
-				r.Decorated(brain).Start(brain)
-			}
-		})
+	props := r.BoundProperties(brain).(iRootProperties)
+	r.Memory(brain).CronTask = brain.After(props.GetLoopInterval(),
+		props.GetRandomDeviation(),
+		r.getTaskFun(brain))
 }
 func (r *Root) stopTimer(brain IBrain) {
 	if r.Memory(brain).CronTask != nil {
@@ -0,0 +1,51 @@
+package bcore
+
+// scriptBlackboard 脚本环境中的黑板
+//
+//	脚本引擎要求被调用的方法必须有且只有一个返回值,故对 IBlackboard 的多返回值和无返回值方法做了适配,
+//	脚本中以 blackboard.Get("hp")、blackboard.Has("hp")、blackboard.Set("hp", 1)、blackboard.Del("hp") 调用
+type scriptBlackboard struct {
+	IBlackboard
+}
+
+// Get 获取value,不存在时返回nil
+//
+//	@receiver b
+//	@param key
+//	@return any
+func (b scriptBlackboard) Get(key string) any {
+	v, _ := b.IBlackboard.Get(key)
+	return v
+}
+
+// Has 是否存在key
+//
+//	@receiver b
+//	@param key
+//	@return bool
+func (b scriptBlackboard) Has(key string) bool {
+	_, ok := b.IBlackboard.Get(key)
+	return ok
+}
+
+// Set 设置KV
+//
+//	@receiver b
+//	@param key
+//	@param val
+//	@return any 返回val,便于在表达式中链式使用
+func (b scriptBlackboard) Set(key string, val any) any {
+	b.IBlackboard.Set(key, val)
+	return val
+}
+
+// Del 删除KV
+//
+//	@receiver b
+//	@param key
+//	@return bool 删除前是否存在
+func (b scriptBlackboard) Del(key string) bool {
+	ok := b.Has(key)
+	b.IBlackboard.Del(key)
+	return ok
+}
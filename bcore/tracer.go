@@ -0,0 +1,136 @@
+package bcore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TraceEventType 追踪事件类型
+type TraceEventType int
+
+const (
+	TraceStart         TraceEventType = iota + 1 // 节点启动 Node.Start
+	TraceAbort                                   // 节点中断 Node.Abort
+	TraceFinish                                  // 节点完成 Node.Finish
+	TraceUpdate                                  // 节点执行委托或脚本 Node.Update
+	TraceChildFinished                           // 容器收到子节点完成 Container.ChildFinished
+)
+
+// traceEpoch 单调时间戳的起点
+var traceEpoch = time.Now()
+
+// TraceEvent 节点生命周期追踪事件
+type TraceEvent struct {
+	Type      TraceEventType
+	BrainID   int       // IBrain.ID
+	Node      INode     // 触发事件的节点
+	NodeID    string    // INode.ID
+	NodeName  string    // INode.Name
+	NodeTitle string    // INode.Title
+	PrevState NodeState // 事件前的状态
+	State     NodeState // 事件后的状态
+	// Result 结果. TraceFinish 和 TraceChildFinished 为 ResultSucceeded 或 ResultFailed, TraceUpdate 为委托或脚本的返回值,其他事件无效
+	Result    Result
+	EventType EventType     // 仅 TraceUpdate 有效,回调给委托的事件类型
+	Child     INode         // 仅 TraceChildFinished 有效,完成的子节点
+	Upstream  INode         // 引起本次事件的上游节点(如中断发起者),可能为空
+	Timestamp time.Duration // 单调时间戳,不受系统时间调整和 timer.Clock 影响,仅用于计算先后和耗时
+}
+
+// Tracer 节点生命周期追踪器,可全局注册( SetTracer )或按 IBrain 注册,用于调试器、统计和测试断言等
+//
+//	回调均在树自己的线程内同步执行,实现方不应阻塞
+type Tracer interface {
+	// OnTrace 节点事件
+	//  @param brain
+	//  @param event
+	OnTrace(brain IBrain, event *TraceEvent)
+}
+
+// BlackboardTracer 若 Tracer 同时实现了该接口,还将收到黑板数据(用户域)的变化
+type BlackboardTracer interface {
+	// OnBlackboardChanged 黑板数据(用户域)改变,在树自己的线程内回调
+	//  @param threadID IBlackboardInternal.ThreadID 即 IBrain.ID
+	//  @param op
+	//  @param key
+	//  @param oldValue
+	//  @param newValue
+	OnBlackboardChanged(threadID int, op OpType, key string, oldValue any, newValue any)
+}
+
+type tracerHolder struct {
+	Tracer
+}
+
+var globalTracer atomic.Pointer[tracerHolder]
+
+// SetTracer 设置全局追踪器,为空则取消.线程安全
+//
+//	需要多个追踪器时请自行组合
+//	@param t
+func SetTracer(t Tracer) {
+	if t == nil {
+		globalTracer.Store(nil)
+		return
+	}
+	globalTracer.Store(&tracerHolder{Tracer: t})
+}
+
+// GlobalTracer 获取全局追踪器
+//
+//	@return Tracer 未设置时返回nil
+func GlobalTracer() Tracer {
+	h := globalTracer.Load()
+	if h == nil {
+		return nil
+	}
+	return h.Tracer
+}
+
+// tracing 是否有追踪器,没有时调用方不应构造事件
+//
+//	@param brain
+//	@return bool
+func tracing(brain IBrain) bool {
+	return globalTracer.Load() != nil || brain.(IBrainInternal).Tracer() != nil
+}
+
+// trace 回调全局和 IBrain 的追踪器
+//
+//	@param brain
+//	@param event 将补全节点信息、上游节点和时间戳
+func trace(brain IBrain, event *TraceEvent) {
+	event.BrainID = brain.ID()
+	event.NodeID = event.Node.ID()
+	event.NodeName = event.Node.Name()
+	event.NodeTitle = event.Node.Title()
+	if upstream, ok := brain.(IBrainInternal).LogContext()[upstreamKey].(INode); ok {
+		event.Upstream = upstream
+	}
+	event.Timestamp = time.Since(traceEpoch)
+	if h := globalTracer.Load(); h != nil {
+		h.OnTrace(brain, event)
+	}
+	if t := brain.(IBrainInternal).Tracer(); t != nil {
+		t.OnTrace(brain, event)
+	}
+}
+
+// traceBlackboard 回调黑板变化
+//
+//	@param tracer 黑板所属 IBrain 的追踪器,可为空
+//	@param threadID
+//	@param op
+//	@param key
+//	@param oldValue
+//	@param newValue
+func traceBlackboard(tracer Tracer, threadID int, op OpType, key string, oldValue any, newValue any) {
+	if h := globalTracer.Load(); h != nil {
+		if bt, ok := h.Tracer.(BlackboardTracer); ok {
+			bt.OnBlackboardChanged(threadID, op, key, oldValue, newValue)
+		}
+	}
+	if bt, ok := tracer.(BlackboardTracer); ok {
+		bt.OnBlackboardChanged(threadID, op, key, oldValue, newValue)
+	}
+}
@@ -0,0 +1,495 @@
+package behavior
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/internal"
+	"github.com/samber/lo"
+)
+
+// inlineExecutor 在调用方线程直接执行任务并计数
+type inlineExecutor struct {
+	tasks int
+}
+
+func (e *inlineExecutor) Go(task func()) {
+	e.tasks++
+	task()
+}
+
+type countingObserver struct {
+	fires int
+	ops   map[string]bcore.OpType
+	olds  map[string]any
+	news  map[string]any
+}
+
+func (o *countingObserver) Fire(op bcore.OpType, key string, oldValue any, newValue any) {
+	o.fires++
+	if o.ops != nil {
+		o.ops[key] = op
+		o.olds[key] = oldValue
+		o.news[key] = newValue
+	}
+}
+
+func newTestBlackboard() (*bcore.Blackboard, *inlineExecutor) {
+	bb := bcore.NewBlackboard(1, nil)
+	executor := &inlineExecutor{}
+	bb.SetExecutor(executor)
+	bb.Start()
+	return bb, executor
+}
+
+func TestBlackboard_Batch(t *testing.T) {
+	bb, executor := newTestBlackboard()
+	bb.Set("hp", 100)
+	bb.Set("gone", 1)
+	executor.tasks = 0
+	// 每个键一个监听函数,另有一个同时监听所有键
+	all := &countingObserver{}
+	perKey := map[string]*countingObserver{}
+	for _, key := range []string{"hp", "mp", "gone", "tmp"} {
+		perKey[key] = &countingObserver{ops: map[string]bcore.OpType{}, olds: map[string]any{}, news: map[string]any{}}
+		bb.AddObserver(key, perKey[key])
+		bb.AddObserver(key, all)
+	}
+	bb.Batch(func(tx bcore.IBlackboardTx) {
+		tx.Set("hp", 90)
+		tx.Set("hp", 80)
+		if v, _ := tx.Get("hp"); v != 80 {
+			t.Errorf("tx.Get(hp) = %v", v)
+		}
+		tx.Set("mp", 10)
+		tx.Del("gone")
+		tx.Set("tmp", 1)
+		tx.Del("tmp")
+	})
+	if executor.tasks != 1 {
+		t.Fatalf("tasks = %d", executor.tasks)
+	}
+	if all.fires != 1 {
+		t.Fatalf("observer of all keys fired %d times", all.fires)
+	}
+	hp := perKey["hp"]
+	if hp.fires != 1 || hp.ops["hp"] != bcore.OpChange || hp.olds["hp"] != 100 || hp.news["hp"] != 80 {
+		t.Fatalf("hp fired %d times,op=%v,old=%v,new=%v", hp.fires, hp.ops["hp"], hp.olds["hp"], hp.news["hp"])
+	}
+	if perKey["mp"].ops["mp"] != bcore.OpAdd || perKey["gone"].ops["gone"] != bcore.OpDel {
+		t.Fatalf("mp op=%v,gone op=%v", perKey["mp"].ops["mp"], perKey["gone"].ops["gone"])
+	}
+	// 批次前后都不存在的键不通知
+	if perKey["tmp"].fires != 0 {
+		t.Fatal("tmp notified")
+	}
+	if _, ok := bb.Get("gone"); ok {
+		t.Fatal("gone not deleted")
+	}
+
+	// 已存在于父黑板的键写到父黑板
+	child := bcore.NewBlackboard(1, bb)
+	child.SetExecutor(executor)
+	child.Start()
+	child.SetMany(map[string]any{"hp": 70, "own": true})
+	if v, _ := bb.Get("hp"); v != 70 {
+		t.Fatalf("parent hp = %v", v)
+	}
+	if _, ok := bb.Get("own"); ok {
+		t.Fatal("own written to parent")
+	}
+}
+
+// discardExecutor 丢弃任务,可并发使用
+type discardExecutor struct{}
+
+func (discardExecutor) Go(task func()) {}
+
+// TestBlackboard_BatchAtomicPerBlackboard Batch 只保证每个黑板各自原子,返回时父黑板的键也已写入
+func TestBlackboard_BatchAtomicPerBlackboard(t *testing.T) {
+	parent := bcore.NewBlackboard(1, nil)
+	parent.SetExecutor(discardExecutor{})
+	parent.Set("p", 0)
+	child := bcore.NewBlackboard(2, parent)
+	child.SetExecutor(discardExecutor{})
+	const n = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= n; i++ {
+			child.Batch(func(tx bcore.IBlackboardTx) {
+				tx.Set("a", i)
+				tx.Set("b", i)
+				tx.Set("p", i)
+			})
+			if v, _ := parent.Get("p"); v != i {
+				t.Errorf("parent p = %v after Batch %d returned", v, i)
+				return
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		child.Batch(func(tx bcore.IBlackboardTx) {
+			a, _ := tx.Get("a")
+			b, _ := tx.Get("b")
+			if a != b {
+				t.Errorf("saw half of a batch: a=%v,b=%v", a, b)
+			}
+		})
+	}
+	if v, _ := parent.Get("p"); v != n {
+		t.Fatalf("parent p = %v", v)
+	}
+}
+
+// orderObserver 记录触发的键的顺序
+type orderObserver struct {
+	id   int // 区分监听函数,同一个监听函数在一批修改中只执行一次
+	keys *[]string
+}
+
+func (o orderObserver) Fire(op bcore.OpType, key string, oldValue any, newValue any) {
+	*o.keys = append(*o.keys, key)
+}
+
+func TestBlackboard_SetManyOrder(t *testing.T) {
+	bb, _ := newTestBlackboard()
+	var fired []string
+	kvs := map[string]any{}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%02d", 19-i)
+		kvs[key] = i
+		bb.AddObserver(key, orderObserver{id: i, keys: &fired})
+	}
+	want := lo.Keys(kvs)
+	sort.Strings(want)
+	// map 的遍历顺序每次不同,多执行几次
+	for i := 0; i < 5; i++ {
+		fired = fired[:0]
+		bb.SetMany(kvs)
+		if !slices.Equal(fired, want) {
+			t.Fatalf("SetMany fired %v, want %v", fired, want)
+		}
+	}
+}
+
+const benchKeys = 10
+
+// BenchmarkBlackboard_Set 逐个写入 benchKeys 个键,由同一个监听函数监听
+func BenchmarkBlackboard_Set(b *testing.B) {
+	bb, executor := newTestBlackboard()
+	ob := &countingObserver{}
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		bb.AddObserver(keys[i], ob)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			bb.Set(key, i)
+		}
+	}
+	b.ReportMetric(float64(executor.tasks)/float64(b.N), "tasks/op")
+	b.ReportMetric(float64(ob.fires)/float64(b.N), "fires/op")
+}
+
+// BenchmarkBlackboard_SetMany 以 SetMany 一次写入 benchKeys 个键
+func BenchmarkBlackboard_SetMany(b *testing.B) {
+	bb, executor := newTestBlackboard()
+	ob := &countingObserver{}
+	kvs := make(map[string]any, benchKeys)
+	for i := 0; i < benchKeys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		kvs[key] = 0
+		bb.AddObserver(key, ob)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for key := range kvs {
+			kvs[key] = i
+		}
+		bb.SetMany(kvs)
+	}
+	b.ReportMetric(float64(executor.tasks)/float64(b.N), "tasks/op")
+	b.ReportMetric(float64(ob.fires)/float64(b.N), "fires/op")
+}
+
+// BenchmarkBlackboard_SetOne 写入单个精确监听的键, Set 走单键路径, Batch 走合批路径
+func BenchmarkBlackboard_SetOne(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		set  func(bb *bcore.Blackboard, i int)
+	}{
+		{"Set", func(bb *bcore.Blackboard, i int) { bb.Set("hp", i) }},
+		{"Batch", func(bb *bcore.Blackboard, i int) {
+			bb.Batch(func(tx bcore.IBlackboardTx) { tx.Set("hp", i) })
+		}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			bb, _ := newTestBlackboard()
+			ob := &countingObserver{}
+			bb.AddObserver("hp", ob)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.set(bb, i)
+			}
+			b.ReportMetric(float64(ob.fires)/float64(b.N), "fires/op")
+		})
+	}
+}
+
+func TestBlackboard_PatternObserver(t *testing.T) {
+	for _, c := range []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"enemy.*", "enemy.1.hp", true},
+		{"enemy.*", "enemy.", true},
+		{"enemy.*", "enemy", false},
+		{"enemy.*.hp", "enemy.boss.2.hp", true},
+		{"enemy.*.hp", "enemy.1.mp", false},
+		{"*.hp", "hp", false},
+		{"a*b*b", "ab", false},
+		{"a*b*b", "abb", true},
+	} {
+		if got := bcore.MatchPattern(c.pattern, c.key); got != c.want {
+			t.Errorf("MatchPattern(%q, %q) = %v", c.pattern, c.key, got)
+		}
+	}
+	bb, _ := newTestBlackboard()
+	enemy := &countingObserver{}
+	hp := &countingObserver{}
+	all := &countingObserver{}
+	bb.AddPatternObserver("enemy.*", enemy)
+	bb.AddPatternObserver("enemy.*.hp", hp)
+	bb.AddPatternObserver("*", all)
+	// 同一个监听函数同时精确监听和模式监听只执行一次
+	bb.AddObserver("enemy.1.hp", enemy)
+	bb.Set("enemy.1.hp", 10)
+	bb.Set("enemy.1.mp", 10)
+	bb.Set("ally.1.hp", 10)
+	if enemy.fires != 2 || hp.fires != 1 || all.fires != 3 {
+		t.Fatalf("fires: enemy=%d,hp=%d,all=%d", enemy.fires, hp.fires, all.fires)
+	}
+	bb.RemovePatternObserver("enemy.*", enemy)
+	bb.RemoveObserver("enemy.1.hp", enemy)
+	bb.RemovePatternObserver("*", all)
+	bb.Set("enemy.2.hp", 10)
+	if enemy.fires != 2 || hp.fires != 2 || all.fires != 3 {
+		t.Fatalf("fires after remove: enemy=%d,hp=%d,all=%d", enemy.fires, hp.fires, all.fires)
+	}
+}
+
+// BenchmarkBlackboard_PatternObserver 存在大量模式监听时写入一个键,只检查字面前缀匹配的模式
+func BenchmarkBlackboard_PatternObserver(b *testing.B) {
+	for _, patterns := range []int{0, 1000} {
+		b.Run(fmt.Sprintf("patterns=%d", patterns), func(b *testing.B) {
+			bb, _ := newTestBlackboard()
+			ob := &countingObserver{}
+			bb.AddObserver("self.hp", ob)
+			for i := 0; i < patterns; i++ {
+				bb.AddPatternObserver(fmt.Sprintf("enemy.%d.*", i), ob)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bb.Set("self.hp", i)
+				bb.Set("enemy.7.hp", i)
+			}
+		})
+	}
+}
+
+func TestBlackboard_NotifyChildren(t *testing.T) {
+	newChild := func(parent *bcore.Blackboard) *bcore.Blackboard {
+		bb := bcore.NewBlackboard(1, parent)
+		bb.SetExecutor(&inlineExecutor{})
+		bb.Start()
+		return bb
+	}
+	// 共享黑板不会被启动
+	shared := bcore.NewBlackboard(1, nil)
+	shared.SetExecutor(&inlineExecutor{})
+	shared.Set("alarm", false)
+	child := newChild(shared)
+	grandchild := newChild(child)
+	// 子黑板自己有同名键时看不到共享黑板的变化
+	shadow := newChild(shared)
+	shadow.RestoreUserMemory(bcore.Memory{"alarm": false})
+	childOb := &countingObserver{ops: map[string]bcore.OpType{}, olds: map[string]any{}, news: map[string]any{}}
+	grandchildOb := &countingObserver{}
+	shadowOb := &countingObserver{}
+	child.AddObserver("alarm", childOb)
+	child.AddPatternObserver("team.*", childOb)
+	grandchild.AddObserver("alarm", grandchildOb)
+	shadow.AddObserver("alarm", shadowOb)
+
+	shared.Set("alarm", true)
+	if childOb.fires != 1 || grandchildOb.fires != 1 || shadowOb.fires != 0 {
+		t.Fatalf("fires: child=%d,grandchild=%d,shadow=%d", childOb.fires, grandchildOb.fires, shadowOb.fires)
+	}
+	if childOb.ops["alarm"] != bcore.OpChange || childOb.olds["alarm"] != false || childOb.news["alarm"] != true {
+		t.Fatalf("child got op=%v,old=%v,new=%v", childOb.ops["alarm"], childOb.olds["alarm"], childOb.news["alarm"])
+	}
+	// 批量修改同样只通知一次
+	shared.SetMany(map[string]any{"team.1": 1, "team.2": 2})
+	if childOb.fires != 2 {
+		t.Fatalf("child fires after batch = %d", childOb.fires)
+	}
+	// 停止后不再通知
+	child.Stop()
+	shared.Set("alarm", false)
+	if childOb.fires != 2 {
+		t.Fatalf("child fires after stop = %d", childOb.fires)
+	}
+}
+
+func TestBrain_BlackboardSchema(t *testing.T) {
+	help()
+	content := []byte(`{"root":"bs-root","tag":"test_blackboard_schema","blackboard":{
+"hp":{"type":"int","default":100,"description":"生命值"},
+"target":{"type":"string"},
+"rest":{"type":"duration","default":"1s"}
+},"nodes":{
+"bs-root":{"id":"bs-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["bs-seq"]},
+"bs-seq":{"id":"bs-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["bs-cond","bs-rest"]},
+"bs-cond":{"id":"bs-cond","name":"BBCondition","category":"decorator","title":"healthy?","properties":{"operator":4,"key":"hp","value":50},"children":["bs-act"]},
+"bs-act":{"id":"bs-act","name":"Action","category":"task","title":"act","properties":{},"delegator":{"script":"blackboard.Set(\"acted\", true); ResultSucceeded"}},
+"bs-rest":{"id":"bs-rest","name":"WaitBB","category":"task","title":"rest","properties":{"key":"rest"}}
+}}`)
+	var cfg config.TreeCfg
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if diagnostics := Validate(&cfg); HasError(diagnostics) {
+		t.Fatalf("diagnostics = %v", diagnostics)
+	}
+	if err := GlobalTreeRegistry().LoadFromJson(content); err != nil {
+		t.Fatal(err)
+	}
+	internal.GlobalConfig.StrictBlackboard = true
+	defer func() { internal.GlobalConfig.StrictBlackboard = false }()
+	bb := bcore.NewBlackboard(1017, nil)
+	runTickTree(t, bb, "test_blackboard_schema", nil, nil)
+	hp := bcore.NewKey[int]("hp")
+	// 默认值由JSON解析为float64,读取时转换
+	if v, ok := hp.Get(bb); !ok || v != 100 {
+		t.Fatalf("hp = %v,%v", v, ok)
+	}
+	if v, _ := bb.Get("acted"); v != true {
+		t.Fatal("condition on default hp not met")
+	}
+	if d := bcore.NewKey[time.Duration]("rest").GetOr(bb, 0); d != time.Second {
+		t.Fatalf("rest = %v", d)
+	}
+	// 严格模式拒绝类型不符的写入,未声明的键不检查
+	bb.Set("hp", "full")
+	hp.Set(bb, 20)
+	bb.Set("target", 1)
+	bb.Set("free", 1)
+	if v := hp.GetOr(bb, 0); v != 20 {
+		t.Fatalf("hp = %v", v)
+	}
+	if bcore.NewKey[string]("target").Has(bb) || !bcore.NewKey[int]("free").Has(bb) {
+		t.Fatal("strict mode checked wrong keys")
+	}
+
+	// 引用未声明的键,类型不符
+	cfg.Nodes["bs-cond"].Properties = json.RawMessage(`{"operator":2,"key":"hp","value":"full"}`)
+	cfg.Nodes["bs-rest"].Properties = json.RawMessage(`{"key":"target"}`)
+	cfg.Nodes["bs-act"] = &config.NodeCfg{ID: "bs-act", Name: "Wait", Category: "task", Title: "wait", Properties: json.RawMessage(`{"waitTime":{"$bb":"delay"}}`)}
+	diagnostics := Validate(&cfg)
+	for _, want := range []string{"hp is declared as int but used as string", "target is declared as string but used as duration", "delay not declared"} {
+		if !lo.ContainsBy(diagnostics, func(d Diagnostic) bool { return d.Severity == SeverityError && strings.Contains(d.Message, want) }) {
+			t.Errorf("missing %q in:\n%v", want, diagnostics)
+		}
+	}
+	cfg.Blackboard["hp"].Default = "full"
+	if diagnostics := Validate(&cfg); !lo.ContainsBy(diagnostics, func(d Diagnostic) bool { return strings.Contains(d.Message, "invalid default value") }) {
+		t.Fatalf("invalid default passed validation: %v", diagnostics)
+	}
+}
+
+func TestBrain_PatternObserver(t *testing.T) {
+	help()
+	content := `
+{"root":"po-root","tag":"test_pattern_observer","nodes":{
+"po-root":{"id":"po-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["po-sel"]},
+"po-sel":{"id":"po-sel","name":"Selector","category":"composite","title":"Selector","properties":{},"children":["po-cond","po-loot"]},
+"po-cond":{"id":"po-cond","name":"Condition","category":"decorator","title":"enemy?","properties":{"abortMode":2,"interval":"1h","observePatterns":["enemy.*.hp"]},"delegator":{"script":"(blackboard.Get(\"enemy.7.hp\") ?? 0) > 0"},"children":["po-attack"]},
+"po-attack":{"id":"po-attack","name":"Action","category":"task","title":"attack","properties":{},"delegator":{"script":"blackboard.Set(\"attacked\", true); ResultSucceeded"}},
+"po-loot":{"id":"po-loot","name":"WaitCondition","category":"decorator","title":"loot?","properties":{"interval":"1h","keys":["loot.*"]},"delegator":{"script":"blackboard.Get(\"loot.sword\") == true"},"children":["po-idle"]},
+"po-idle":{"id":"po-idle","name":"Wait","category":"task","title":"idle","properties":{"forever":true}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	bb := bcore.NewBlackboard(1018, nil)
+	brain := runTickTree(t, bb, "test_pattern_observer", nil, nil)
+	// 匹配 WaitCondition 的模式,条件满足后进入空闲
+	bb.Set("loot.sword", true)
+	brain.Tick(0)
+	wait := GlobalTreeRegistry().GetNotParentTreeWithoutClone("test_pattern_observer").Root.Decorated(nil).(bcore.IComposite).Children()[1]
+	if idle := wait.(bcore.IDecorator).Decorated(nil); !idle.IsActive(brain) {
+		t.Fatal("wait condition not met by pattern key")
+	}
+	// 不匹配 Condition 的模式
+	bb.Set("enemy.7.mp", 10)
+	brain.Tick(0)
+	if _, ok := bb.Get("attacked"); ok {
+		t.Fatal("condition evaluated on unmatched key")
+	}
+	// 匹配 Condition 的模式,中断低优先级分支
+	bb.Set("enemy.7.hp", 10)
+	brain.Tick(0)
+	if v, _ := bb.Get("attacked"); v != true {
+		t.Fatal("condition not evaluated on pattern key")
+	}
+	if idle := wait.(bcore.IDecorator).Decorated(nil); idle.IsActive(brain) {
+		t.Fatal("lower priority branch not aborted")
+	}
+}
+
+func TestBrain_SharedBlackboardNotify(t *testing.T) {
+	help()
+	content := `
+{"root":"sn-root","tag":"test_shared_notify","nodes":{
+"sn-root":{"id":"sn-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["sn-sel"]},
+"sn-sel":{"id":"sn-sel","name":"Selector","category":"composite","title":"Selector","properties":{},"children":["sn-cond","sn-idle"]},
+"sn-cond":{"id":"sn-cond","name":"BBCondition","category":"decorator","title":"alarm?","properties":{"operator":2,"key":"alarm","value":true,"abortMode":2},"children":["sn-react"]},
+"sn-react":{"id":"sn-react","name":"Action","category":"task","title":"react","properties":{},"delegator":{"script":"blackboard.Set(\"reacted\", true); ResultSucceeded"}},
+"sn-idle":{"id":"sn-idle","name":"Wait","category":"task","title":"idle","properties":{"forever":true}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	// 共享黑板不会被启动
+	shared := bcore.NewBlackboard(1019, nil)
+	shared.Set("alarm", false)
+	var brains []*Brain
+	for i := 0; i < 2; i++ {
+		brain := runTickTree(t, bcore.NewBlackboard(1020+i, shared), "test_shared_notify", nil, nil)
+		brains = append(brains, brain)
+	}
+	// 经由子黑板写入,因共享黑板已有该键而写到共享黑板
+	brains[0].Blackboard().Set("alarm", true)
+	for i, brain := range brains {
+		brain.Tick(0)
+		if v, _ := brain.Blackboard().Get("reacted"); v != true {
+			t.Fatalf("brain %d not reacted to shared blackboard change", i)
+		}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"github.com/alkaid/behavior/internal"
 	"reflect"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/alkaid/behavior/task"
+
 	"github.com/samber/lo"
 
 	"github.com/alkaid/behavior/timer"
@@ -13,8 +18,6 @@ import (
 
 	"github.com/pkg/errors"
 
-	"github.com/alkaid/behavior/thread"
-
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -37,6 +40,23 @@ type Brain struct {
 	finishChan    chan *bcore.FinishEvent // 供上层业务方使用的完成通知
 	root          bcore.IRoot
 	logCtx        map[string]any
+	clock         timer.Clock              // 时钟,为空则使用所属 Runtime 的时钟
+	tick          *tickExecutor            // 同步帧驱动模式的执行器,为空则为异步模式
+	tracer        bcore.Tracer             // 追踪器,为空则只使用全局追踪器
+	mounts        map[string]*dynamicMount // 动态挂载记录,key为动态子树容器的完整路径
+	migrating     bool                     // 是否正在中断当前树以立即热更迁移
+	runtime       *Runtime                 // 所属运行时,为空则使用 DefaultRuntime
+}
+
+// Runtime 所属运行时
+//
+//	@receiver b
+//	@return *Runtime
+func (b *Brain) Runtime() *Runtime {
+	if b.runtime != nil {
+		return b.runtime
+	}
+	return defaultRuntime
 }
 
 func (b *Brain) ID() int {
@@ -53,6 +73,19 @@ func (b *Brain) RWFinishChan() chan *bcore.FinishEvent {
 func (b *Brain) FinishChan() <-chan *bcore.FinishEvent {
 	return b.finishChan
 }
+
+// SetTracer
+//
+//	@implement bcore.IBrain .SetTracer
+//	@receiver b
+//	@param tracer
+func (b *Brain) SetTracer(tracer bcore.Tracer) {
+	b.tracer = tracer
+	b.blackboard.SetTracer(tracer)
+}
+func (b *Brain) Tracer() bcore.Tracer {
+	return b.tracer
+}
 func (b *Brain) RunningTree() bcore.IRoot {
 	return b.root
 }
@@ -60,6 +93,19 @@ func (b *Brain) Running() bool {
 	return b.root != nil
 }
 func (b *Brain) SetRunningTree(root bcore.IRoot) {
+	// 记录树的使用者,热更后旧版无人使用时才能移除
+	registry := b.Runtime().TreeRegistry()
+	if b.root != nil {
+		registry.release(b.root.ID(), b)
+	}
+	if root != nil {
+		registry.acquire(root.ID(), b)
+	} else {
+		for _, mount := range b.mounts {
+			registry.release(mount.rootID, b)
+		}
+		b.mounts = nil
+	}
 	b.root = root
 }
 func (b *Brain) LogContext() map[string]any {
@@ -131,7 +177,11 @@ func (b *Brain) GetDelegate(name string) (delegate any, ok bool) {
 }
 
 func (b *Brain) Go(task func()) {
-	thread.GoByID(b.blackboard.ThreadID(), task)
+	if b.tick != nil {
+		b.tick.Go(task)
+		return
+	}
+	b.Runtime().goByID(b.blackboard.ThreadID(), task)
 }
 
 // Abort @implement bcore.IBrain .Abort
@@ -163,7 +213,28 @@ func (b *Brain) Abort(abortChan chan *bcore.FinishEvent) {
 	})
 }
 func (b *Brain) Run(tag string, force bool) error {
-	tree := GlobalTreeRegistry().GetNotParentTreeWithoutClone(tag)
+	return b.RunWithParams(tag, nil, force)
+}
+
+// RunWithParams 以模板参数特化树并异步启动,同一参数集的特化树由注册器缓存
+//
+//	@receiver b
+//	@param tag
+//	@param params 模板参数,为空时同 Brain.Run
+//	@param force 是否强制终止正在运行的树
+//	@return error 找不到树,树不是模板或参数未声明
+func (b *Brain) RunWithParams(tag string, params map[string]any, force bool) error {
+	var tree *Tree
+	registry := b.Runtime().TreeRegistry()
+	if len(params) == 0 {
+		tree = registry.GetNotParentTreeWithoutClone(tag)
+	} else {
+		var err error
+		tree, _, err = registry.getNotParentTree(tag, params)
+		if err != nil {
+			return errors.WithMessagef(err, "can not run tree with params,tag=%s", tag)
+		}
+	}
 	if tree == nil || tree.Root == nil {
 		err := errors.New(fmt.Sprintf("can not find main tree for tag %s", tag))
 		return err
@@ -177,50 +248,203 @@ func (b *Brain) Run(tag string, force bool) error {
 				return
 			}
 		} else {
+			b.blackboard.SetSchema(tree.Blackboard, b.Runtime().StrictBlackboard())
 			tree.Root.Start(b)
 		}
 	})
 	return nil
 }
 
-// DynamicDecorate 给正在运行的树动态挂载子树.暂时只支持主树上的动态容器
+// DynamicDecorate 给正在运行的树动态挂载子树,容器可以在主树或已挂载的任意层子树上
 //
-//	非线程安全,调用方自己保证
+//	非线程安全,须在 brain 线程调用,其他线程请使用 SafeDynamicDecorate
 //
 // @receiver b
-// @param containerTag 动态子树容器的tag
+// @param containerPath 动态子树容器的路径,格式为 [子树tag/.../]容器tag.
+// 子树tag为从主树往下逐层挂载的子树,可只写最后几层作为范围;只写容器tag时在整个挂载层级中查找.匹配到多个容器时返回错误
 // @param subtreeTag 子树的tag
 // @return error
-func (b *Brain) DynamicDecorate(containerTag string, subtreeTag string) error {
+func (b *Brain) DynamicDecorate(containerPath string, subtreeTag string) error {
+	return b.DynamicDecorateWithParams(containerPath, subtreeTag, nil)
+}
+
+// DynamicDecorateWithParams 同 Brain.DynamicDecorate,子树为模板时以 params 特化
+//
+//	非线程安全,须在 brain 线程调用
+//	@receiver b
+//	@param containerPath
+//	@param subtreeTag
+//	@param params 模板参数,可为空
+//	@return error
+func (b *Brain) DynamicDecorateWithParams(containerPath string, subtreeTag string, params map[string]any) error {
 	if !b.Running() || !b.RunningTree().IsActive(b) {
-		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not running tree,containerTag=%s,subtreeTag=%s", containerTag, subtreeTag))
+		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not running tree,containerPath=%s,subtreeTag=%s", containerPath, subtreeTag))
 	}
-	registry := GlobalTreeRegistry()
-	maintree := registry.TreesByID[b.RunningTree().ID()]
+	registry := b.Runtime().TreeRegistry()
+	maintree := registry.TreeByID(b.RunningTree().ID())
 	if maintree == nil {
-		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not main tree,containerTag=%s,subtreeTag=%s", containerTag, subtreeTag))
+		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not main tree,containerPath=%s,subtreeTag=%s", containerPath, subtreeTag))
 	}
-	container := maintree.DynamicSubtrees[containerTag]
-	if container == nil {
-		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not dynamic container,containerTag=%s,subtreeTag=%s", containerTag, subtreeTag))
+	found, err := b.findDynamicContainer(maintree, containerPath)
+	if err != nil {
+		return errors.WithMessagef(err, "brain can not dynamic decorate,subtreeTag=%s", subtreeTag)
 	}
+	container := found.container
 	// 当前子树就是想要挂载的子树,不再执行动态替换
 	childRoot := container.Decorated(b)
-	if childRoot != nil && registry.TreesByID[childRoot.ID()].Tag == subtreeTag {
-		return nil
+	if childRoot != nil && !registry.isRetired(childRoot.ID()) {
+		key, err := registry.resolveKey(registry.published(), subtreeTag, params)
+		if tree := registry.TreeByID(childRoot.ID()); err == nil && tree != nil && tree.indexKey() == key {
+			return nil
+		}
 	}
-	subtree, _, err := registry.getNotDynamicParentTree(subtreeTag, container, b)
+	subtree, _, err := registry.getNotDynamicParentTree(subtreeTag, params, container, b)
 	if err != nil {
 		return err
 	}
 	if subtree == nil {
-		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not enough subtree,containerTag=%s,subtreeTag=%s", containerTag, subtreeTag))
+		return errors.New(fmt.Sprintf("brain can not dynamic decorate cause not enough subtree,containerPath=%s,subtreeTag=%s", containerPath, subtreeTag))
 	}
 
 	container.DynamicDecorate(b, subtree.Root)
+	b.recordMount(found, subtree)
+	return nil
+}
+
+// DynamicUnmount 卸载动态挂载的子树,容器恢复为配置的默认子树(未配置则为空).容器运行中时按其 runMode 处理
+//
+//	非线程安全,须在 brain 线程调用,其他线程请使用 SafeDynamicUnmount
+//	@receiver b
+//	@param containerPath 动态子树容器的路径,参看 Brain.DynamicDecorate
+//	@return error
+func (b *Brain) DynamicUnmount(containerPath string) error {
+	if !b.Running() || !b.RunningTree().IsActive(b) {
+		return errors.New(fmt.Sprintf("brain can not dynamic unmount cause not running tree,containerPath=%s", containerPath))
+	}
+	maintree := b.Runtime().TreeRegistry().TreeByID(b.RunningTree().ID())
+	if maintree == nil {
+		return errors.New(fmt.Sprintf("brain can not dynamic unmount cause not main tree,containerPath=%s", containerPath))
+	}
+	found, err := b.findDynamicContainer(maintree, containerPath)
+	if err != nil {
+		return errors.WithMessage(err, "brain can not dynamic unmount")
+	}
+	found.container.DynamicUnmount(b)
+	b.removeMount(found)
 	return nil
 }
 
+// SafeDynamicDecorate 同 Brain.DynamicDecorate
+//
+//	线程安全
+//	@receiver b
+//	@param containerPath
+//	@param subtreeTag
+//	@param errChan 执行完成后写入结果,可为空
+func (b *Brain) SafeDynamicDecorate(containerPath string, subtreeTag string, errChan chan error) {
+	b.Go(func() {
+		err := b.DynamicDecorate(containerPath, subtreeTag)
+		if errChan != nil {
+			errChan <- err
+		} else if err != nil {
+			logger.Log.Error("dynamic decorate failed", zap.Int("brain", b.ID()), zap.Error(err))
+		}
+	})
+}
+
+// SafeDynamicUnmount 同 Brain.DynamicUnmount
+//
+//	线程安全
+//	@receiver b
+//	@param containerPath
+//	@param errChan 执行完成后写入结果,可为空
+func (b *Brain) SafeDynamicUnmount(containerPath string, errChan chan error) {
+	b.Go(func() {
+		err := b.DynamicUnmount(containerPath)
+		if errChan != nil {
+			errChan <- err
+		} else if err != nil {
+			logger.Log.Error("dynamic unmount failed", zap.Int("brain", b.ID()), zap.Error(err))
+		}
+	})
+}
+
+// dynamicContainer 挂载层级中的一个动态子树容器
+type dynamicContainer struct {
+	scope     []string // 从主树往下逐层挂载的子树tag,不包括主树
+	path      string   // 完整路径
+	container task.IDynamicSubtree
+}
+
+// dynamicContainers 遍历 tree 及其当前挂载的所有子树(包括动态挂载),收集动态子树容器
+//
+//	@receiver b
+//	@param tree
+//	@return []*dynamicContainer 按路径排序
+func (b *Brain) dynamicContainers(tree *Tree) []*dynamicContainer {
+	registry := b.Runtime().TreeRegistry()
+	var containers []*dynamicContainer
+	visited := map[string]bool{}
+	var walk func(tree *Tree, scope []string)
+	walk = func(tree *Tree, scope []string) {
+		if visited[tree.Root.ID()] {
+			return
+		}
+		visited[tree.Root.ID()] = true
+		for tag, container := range tree.DynamicSubtrees {
+			containers = append(containers, &dynamicContainer{
+				scope:     scope,
+				path:      strings.Join(append(slices.Clone(scope), tag), "/"),
+				container: container,
+			})
+		}
+		each := func(container bcore.IDecorator) {
+			child := container.Decorated(b)
+			if child == nil {
+				return
+			}
+			if subtree := registry.TreeByID(child.ID()); subtree != nil {
+				walk(subtree, append(slices.Clone(scope), subtree.Tag))
+			}
+		}
+		for _, container := range tree.StaticSubtrees {
+			each(container)
+		}
+		for _, container := range tree.DynamicSubtrees {
+			each(container)
+		}
+	}
+	walk(tree, nil)
+	sort.Slice(containers, func(i, j int) bool { return containers[i].path < containers[j].path })
+	return containers
+}
+
+// findDynamicContainer 根据路径查找动态子树容器,路径的子树tag部分须是容器所在层级的后缀
+//
+//	@receiver b
+//	@param tree 主树
+//	@param containerPath
+//	@return *dynamicContainer
+//	@return error 找不到或匹配到多个时返回错误
+func (b *Brain) findDynamicContainer(tree *Tree, containerPath string) (*dynamicContainer, error) {
+	segs := strings.Split(containerPath, "/")
+	tag, scope := segs[len(segs)-1], segs[:len(segs)-1]
+	var found []*dynamicContainer
+	for _, c := range b.dynamicContainers(tree) {
+		if c.container.Tag() == tag && len(c.scope) >= len(scope) && slices.Equal(c.scope[len(c.scope)-len(scope):], scope) {
+			found = append(found, c)
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.New(fmt.Sprintf("dynamic container not found,containerPath=%s", containerPath))
+	}
+	if len(found) > 1 {
+		paths := lo.Map(found, func(c *dynamicContainer, _ int) string { return c.path })
+		return nil, errors.New(fmt.Sprintf("dynamic container is ambiguous,containerPath=%s,matches=%s", containerPath, strings.Join(paths, ",")))
+	}
+	return found[0], nil
+}
+
 // OnNodeUpdate 供节点回调执行委托 会在 Brain 的独立线程里运行
 //
 //	@receiver b
@@ -237,12 +461,13 @@ func (b *Brain) OnNodeUpdate(target string, method string, brain bcore.IBrain, e
 		log.Error("target is nil,please register delegate before run behavior tree")
 		return bcore.ResultFailed
 	}
-	handler := GlobalHandlerPool().GetHandle(target, method)
+	handlerPool := b.Runtime().HandlerPool()
+	handler := handlerPool.GetHandle(target, method)
 	if handler == nil || handler.MethodType == handle2.MtNone {
 		if internal.GlobalConfig.ActionSuccessIfNotDelegate {
 			return bcore.ResultSucceeded
 		}
-		log.Error("handler is nil,please register target to GlobalHandlerPool() before run behavior tree")
+		log.Error("handler is nil,please register target to HandlerPool before run behavior tree")
 		return bcore.ResultFailed
 	}
 	var rets []any
@@ -250,9 +475,9 @@ func (b *Brain) OnNodeUpdate(target string, method string, brain bcore.IBrain, e
 	log = log.With(zap.Int("methodType", int(handler.MethodType)))
 	switch handler.MethodType {
 	case handle.MtFullStyle:
-		_, rets, err = GlobalHandlerPool().ProcessHandler(handler, meta.ReflectValue, eventType, delta)
+		_, rets, err = handlerPool.ProcessHandler(handler, meta.ReflectValue, eventType, delta)
 	default:
-		_, rets, err = GlobalHandlerPool().ProcessHandler(handler, meta.ReflectValue)
+		_, rets, err = handlerPool.ProcessHandler(handler, meta.ReflectValue)
 	}
 	// 出错默认返回失败
 	if err != nil {
@@ -288,22 +513,72 @@ func (b *Brain) OnNodeUpdate(target string, method string, brain bcore.IBrain, e
 	return bcore.ResultFailed
 }
 
-// Cron wrap timingwheel.TimingWheel .Cron
+// SetClock 设置该 Brain 使用的时钟,须在运行树之前设置.同步帧驱动模式下无效
+//
+//	@receiver b
+//	@param clock 为空则使用所属 Runtime 的时钟
+func (b *Brain) SetClock(clock timer.Clock) {
+	b.clock = clock
+}
+
+// Clock 获取该 Brain 使用的时钟
+//
+//	@receiver b
+//	@return timer.Clock
+func (b *Brain) Clock() timer.Clock {
+	if b.tick != nil {
+		return b.tick.clock
+	}
+	if b.clock != nil {
+		return b.clock
+	}
+	return b.Runtime().Clock()
+}
+
+// Now @implement bcore.IBrain .Now
+//
+//	@receiver b
+//	@return time.Time
+func (b *Brain) Now() time.Time {
+	return b.Clock().Now()
+}
+
+// Cron wrap timer.Clock .Cron
 //
 //	@param interval 间隔
 //	@param randomDeviation 随机离差范围,interval=interval+randomDeviation*[-0.5,0.5)
 //	@param task
 //	@param opts
-func (b *Brain) Cron(interval time.Duration, randomDeviation time.Duration, task func()) *timingwheel.Timer {
-	return timer.Cron(interval, randomDeviation, task, timingwheel.WithGoID(b.blackboard.ThreadID()), timingwheel.WithPool(thread.PoolInstance()))
+func (b *Brain) Cron(interval time.Duration, randomDeviation time.Duration, task func()) timer.Timer {
+	interval = timer.Deviate(interval, randomDeviation)
+	// 每次执行时在 Brain 的线程更新下次到期时间,快照也在该线程读取
+	t := &timer.DeadlineTimer{Deadline: b.Now().Add(interval)}
+	run := func() {
+		t.Deadline = b.Now().Add(interval)
+		task()
+	}
+	if b.tick != nil {
+		t.Timer = b.tick.clock.Cron(interval, b.tick.wrap(run))
+	} else {
+		t.Timer = b.Clock().Cron(interval, run, timingwheel.WithGoID(b.blackboard.ThreadID()), timingwheel.WithPool(b.Runtime().ThreadPool()))
+	}
+	if t.Timer == nil {
+		return nil
+	}
+	return t
 }
 
-// After wrap timingwheel.TimingWheel .AfterFunc
+// After wrap timer.Clock .AfterFunc
 //
 //	@param interval 间隔
 //	@param randomDeviation 随机离差范围 interval = interval + randomDeviation*[-0.5,0.5)
 //	@param task
 //	@param opts
-func (b *Brain) After(interval time.Duration, randomDeviation time.Duration, task func()) *timingwheel.Timer {
-	return timer.After(interval, randomDeviation, task, timingwheel.WithGoID(b.blackboard.ThreadID()), timingwheel.WithPool(thread.PoolInstance()))
+func (b *Brain) After(interval time.Duration, randomDeviation time.Duration, task func()) timer.Timer {
+	interval = timer.Deviate(interval, randomDeviation)
+	deadline := b.Now().Add(interval)
+	if b.tick != nil {
+		return timer.WithDeadline(b.tick.clock.AfterFunc(interval, b.tick.wrap(task)), deadline)
+	}
+	return timer.WithDeadline(b.Clock().AfterFunc(interval, task, timingwheel.WithGoID(b.blackboard.ThreadID()), timingwheel.WithPool(b.Runtime().ThreadPool())), deadline)
 }
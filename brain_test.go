@@ -0,0 +1,470 @@
+package behavior
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/decorator"
+	"github.com/alkaid/behavior/thread"
+	"github.com/alkaid/behavior/timer"
+	"github.com/samber/lo"
+)
+
+func TestBrain_ManualClock(t *testing.T) {
+	help()
+	content := `
+{"root":"mc-root","tag":"test_manual_clock","nodes":{
+"mc-root":{"id":"mc-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["mc-seq"]},
+"mc-seq":{"id":"mc-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["mc-wait","mc-cd"]},
+"mc-wait":{"id":"mc-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"30s"}},
+"mc-cd":{"id":"mc-cd","name":"Cooldown","category":"decorator","title":"Cooldown","properties":{"cooldownTime":"10s"},"children":["mc-action"]},
+"mc-action":{"id":"mc-action","name":"Action","category":"task","title":"Action","properties":{}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	clock := timer.NewManualClock(time.Unix(0, 0))
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := NewBrain(bcore.NewBlackboard(1001, nil), nil, fch).(*Brain)
+	brain.SetClock(clock)
+	if err := brain.Run("test_manual_clock", false); err != nil {
+		t.Fatal(err)
+	}
+	// 等待树在 brain 线程里启动
+	thread.WaitByID(brain.ID(), func() {})
+	clock.Advance(29 * time.Second)
+	select {
+	case <-fch:
+		t.Fatal("tree finished before wait time elapsed")
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case event := <-fch:
+		if !event.Succeeded {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished after wait time elapsed")
+	}
+}
+
+type scriptTestNPC struct {
+	hits int
+}
+
+func (n *scriptTestNPC) Hit() int {
+	n.hits++
+	return n.hits
+}
+
+func TestBrain_Script(t *testing.T) {
+	help()
+	content := `
+{"root":"sc-root","tag":"test_script","nodes":{
+"sc-root":{"id":"sc-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["sc-cond"]},
+"sc-cond":{"id":"sc-cond","name":"Condition","category":"decorator","title":"hp low?","properties":{},"delegator":{"script":"blackboard.Get(\"hp\") < 30"},"children":["sc-action"]},
+"sc-action":{"id":"sc-action","name":"Action","category":"task","title":"Action","properties":{},"delegator":{"script":"blackboard.Set(\"hits\", npc.Hit()); eventType == EventTypeOnStart ? ResultSucceeded : ResultFailed"}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	npc := &scriptTestNPC{}
+	fch := make(chan *bcore.FinishEvent, 1)
+	bb := bcore.NewBlackboard(1007, nil)
+	bb.Set("hp", 10)
+	runTickTree(t, bb, "test_script", map[string]any{"npc": npc}, fch)
+	select {
+	case event := <-fch:
+		if !event.Succeeded {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished")
+	}
+	if npc.hits != 1 {
+		t.Fatalf("npc.hits = %d, want 1", npc.hits)
+	}
+}
+
+func TestBBEntries_Query(t *testing.T) {
+	help()
+	content := `
+{"root":"bq-root","tag":"test_bbentries_query","nodes":{
+"bq-root":{"id":"bq-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["bq-sel"]},
+"bq-sel":{"id":"bq-sel","name":"Selector","category":"composite","title":"Selector","properties":{},"children":["bq-entries","bq-wait"]},
+"bq-entries":{"id":"bq-entries","name":"BBEntries","category":"decorator","title":"flee?","properties":{"abortMode":2,"query":"hp < maxHp * 0.3 && target != \"\""},"children":["bq-action"]},
+"bq-action":{"id":"bq-action","name":"Action","category":"task","title":"Action","properties":{}},
+"bq-wait":{"id":"bq-wait","name":"Wait","category":"task","title":"idle","properties":{"forever":true}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	fch := make(chan *bcore.FinishEvent, 1)
+	bb := bcore.NewBlackboard(1008, nil)
+	bb.Set("hp", 100)
+	bb.Set("maxHp", 100)
+	bb.Set("target", "")
+	brain := runTickTree(t, bb, "test_bbentries_query", nil, fch)
+	brain.Blackboard().Set("hp", 20)
+	brain.Tick(0)
+	select {
+	case <-fch:
+		t.Fatal("query met without target")
+	default:
+	}
+	brain.Blackboard().Set("target", "enemy")
+	brain.Tick(0)
+	select {
+	case event := <-fch:
+		if !event.Succeeded {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished after query met")
+	}
+}
+
+func TestWaitCondition(t *testing.T) {
+	help()
+	content := `
+{"root":"wc-root","tag":"test_wait_condition","nodes":{
+"wc-root":{"id":"wc-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["wc-wait"]},
+"wc-wait":{"id":"wc-wait","name":"WaitCondition","category":"decorator","title":"ready?","properties":{"interval":"1s","keys":["ready"],"timeout":"5s"},"delegator":{"script":"blackboard.Get(\"ready\") == true"},"children":["wc-action"]},
+"wc-action":{"id":"wc-action","name":"Action","category":"task","title":"Action","properties":{}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	finished := func(fch chan *bcore.FinishEvent) *bcore.FinishEvent {
+		select {
+		case event := <-fch:
+			return event
+		default:
+			return nil
+		}
+	}
+	// 黑板键改变时立即检查
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := runTickTree(t, bcore.NewBlackboard(1009, nil), "test_wait_condition", nil, fch)
+	brain.Tick(3 * time.Second)
+	if finished(fch) != nil {
+		t.Fatal("finished before condition met")
+	}
+	brain.Blackboard().Set("ready", true)
+	brain.Tick(0)
+	if event := finished(fch); event == nil || !event.Succeeded {
+		t.Fatalf("finish event = %+v, want succeeded", event)
+	}
+	// 超时以失败结束
+	fch = make(chan *bcore.FinishEvent, 1)
+	brain = runTickTree(t, bcore.NewBlackboard(1010, nil), "test_wait_condition", nil, fch)
+	brain.Tick(4 * time.Second)
+	if finished(fch) != nil {
+		t.Fatal("finished before timeout")
+	}
+	brain.Tick(time.Second)
+	if event := finished(fch); event == nil || event.Succeeded {
+		t.Fatalf("finish event = %+v, want failed", event)
+	}
+	// 等待时被中断
+	fch = make(chan *bcore.FinishEvent, 1)
+	brain = runTickTree(t, bcore.NewBlackboard(1011, nil), "test_wait_condition", nil, fch)
+	brain.Tick(time.Second)
+	brain.Abort(nil)
+	brain.Tick(0)
+	if event := finished(fch); event == nil || event.Succeeded {
+		t.Fatalf("finish event = %+v, want failed", event)
+	}
+	if pending := brain.tick.clock.Pending(); pending != 0 {
+		t.Fatalf("pending timers = %d, want 0", pending)
+	}
+	// 超时短于检查间隔时按超时时间结束,快照恢复后继续计时
+	content = strings.NewReplacer("test_wait_condition", "test_wait_condition_timeout", `"timeout":"5s"`, `"timeout":"100ms"`).Replace(content)
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	fch = make(chan *bcore.FinishEvent, 1)
+	brain = runTickTree(t, bcore.NewBlackboard(1012, nil), "test_wait_condition_timeout", nil, fch)
+	brain.Tick(99 * time.Millisecond)
+	if finished(fch) != nil {
+		t.Fatal("finished before timeout")
+	}
+	brain.Tick(time.Millisecond)
+	if event := finished(fch); event == nil || event.Succeeded {
+		t.Fatalf("finish event = %+v, want failed", event)
+	}
+	brain = runTickTree(t, bcore.NewBlackboard(1013, nil), "test_wait_condition_timeout", nil, nil)
+	brain.Tick(60 * time.Millisecond)
+	data, err := brain.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fch = make(chan *bcore.FinishEvent, 1)
+	restored := NewTickBrain(bcore.NewBlackboard(1014, nil), nil, fch)
+	if err = restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	restored.Tick(39 * time.Millisecond)
+	if finished(fch) != nil {
+		t.Fatal("restored brain finished before timeout")
+	}
+	restored.Tick(time.Millisecond)
+	if event := finished(fch); event == nil || event.Succeeded {
+		t.Fatalf("restored finish event = %+v, want failed", event)
+	}
+}
+
+func TestBrain_DynamicDecorateNested(t *testing.T) {
+	help()
+	leaf := func(tag string) []byte {
+		return []byte(`{"root":"` + tag + `-root","tag":"` + tag + `","nodes":{
+"` + tag + `-root":{"id":"` + tag + `-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["` + tag + `-wait"]},
+"` + tag + `-wait":{"id":"` + tag + `-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"10ms"}}
+}}`)
+	}
+	// 带有 ability 动态容器的子树
+	holder := func(tag string) []byte {
+		return []byte(`{"root":"` + tag + `-root","tag":"` + tag + `","nodes":{
+"` + tag + `-root":{"id":"` + tag + `-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["` + tag + `-seq"]},
+"` + tag + `-seq":{"id":"` + tag + `-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["` + tag + `-ability","` + tag + `-wait"]},
+"` + tag + `-ability":{"id":"` + tag + `-ability","name":"DynamicSubtree","category":"decorator","title":"ability","properties":{"tag":"ability","runMode":1,"isSuccessWhenNotChild":true}},
+"` + tag + `-wait":{"id":"` + tag + `-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"50ms"}}
+}}`)
+	}
+	main := []byte(`{"root":"nd-root","tag":"nd_main","nodes":{
+"nd-root":{"id":"nd-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["nd-seq"]},
+"nd-seq":{"id":"nd-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["nd-weapon","nd-slot","nd-wait"]},
+"nd-weapon":{"id":"nd-weapon","name":"Subtree","category":"decorator","title":"weapon","properties":{"childTag":"nd_weapon"}},
+"nd-slot":{"id":"nd-slot","name":"DynamicSubtree","category":"decorator","title":"slot","properties":{"tag":"slot","runMode":1,"isSuccessWhenNotChild":true}},
+"nd-wait":{"id":"nd-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"100ms"}}
+}}`)
+	registry := GlobalTreeRegistry()
+	if err := registry.LoadFromJsons([][]byte{main, holder("nd_weapon"), holder("nd_gun"), leaf("nd_fire"), leaf("nd_ice")}); err != nil {
+		t.Fatal(err)
+	}
+	brain := runTickTree(t, bcore.NewBlackboard(1012, nil), "nd_main", nil, nil)
+	mounted := func(path string) string {
+		found, err := brain.findDynamicContainer(registry.TreeByID(brain.RunningTree().ID()), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		child := found.container.Decorated(brain)
+		if child == nil {
+			return ""
+		}
+		return registry.TreeByID(child.ID()).Tag
+	}
+	// 静态子树上的动态容器,完整路径和只写容器tag均可
+	if err := brain.DynamicDecorate("nd_weapon/ability", "nd_fire"); err != nil {
+		t.Fatal(err)
+	}
+	if tag := mounted("ability"); tag != "nd_fire" {
+		t.Fatalf("ability mounted %q", tag)
+	}
+	if err := brain.DynamicDecorate("ability", "nd_ice"); err != nil {
+		t.Fatal(err)
+	}
+	if tag := mounted("nd_weapon/ability"); tag != "nd_ice" {
+		t.Fatalf("ability mounted %q", tag)
+	}
+	// 动态子树上的动态容器,同名容器出现两次后须指定范围
+	if err := brain.DynamicDecorate("slot", "nd_gun"); err != nil {
+		t.Fatal(err)
+	}
+	if err := brain.DynamicDecorate("ability", "nd_fire"); err == nil {
+		t.Fatal("ambiguous container should fail")
+	}
+	if err := brain.DynamicDecorate("nd_gun/ability", "nd_fire"); err != nil {
+		t.Fatal(err)
+	}
+	if tag := mounted("nd_gun/ability"); tag != "nd_fire" || brain.mounts["slot"] == nil || brain.mounts["nd_gun/ability"] == nil {
+		t.Fatalf("gun ability mounted %q, mounts %v", tag, brain.mounts)
+	}
+	// 卸载
+	if err := brain.DynamicUnmount("nd_weapon/ability"); err != nil {
+		t.Fatal(err)
+	}
+	if tag := mounted("nd_weapon/ability"); tag != "" || brain.mounts["nd_weapon/ability"] != nil {
+		t.Fatalf("ability not unmounted, mounted %q", tag)
+	}
+	// 外层换成其他子树后,内层的挂载记录一并移除
+	if err := brain.DynamicDecorate("slot", "nd_ice"); err != nil {
+		t.Fatal(err)
+	}
+	if brain.mounts["nd_gun/ability"] != nil {
+		t.Fatalf("nested mount not removed, mounts %v", brain.mounts)
+	}
+	// 其他线程调用
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		brain.SafeDynamicDecorate("nd_weapon/ability", "nd_fire", errChan)
+		close(done)
+	}()
+	<-done
+	brain.Tick(0)
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	if tag := mounted("ability"); tag != "nd_fire" {
+		t.Fatalf("ability mounted %q", tag)
+	}
+	brain.SafeDynamicUnmount("missing", errChan)
+	brain.Tick(0)
+	if err := <-errChan; err == nil {
+		t.Fatal("unmount missing container should fail")
+	}
+}
+
+func TestBrain_SubtreePorts(t *testing.T) {
+	help()
+	sub := []byte(`{"root":"pt-sub-root","tag":"pt_attack","nodes":{
+"pt-sub-root":{"id":"pt-sub-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["pt-sub-action"]},
+"pt-sub-action":{"id":"pt-sub-action","name":"Action","category":"task","title":"attack","properties":{},"delegator":{"script":"blackboard.Set(\"result\", blackboard.Get(\"target\") + \"-hit\"); blackboard.Set(\"tmp\", 1); ResultSucceeded"}}
+}}`)
+	main := []byte(`{"root":"pt-root","tag":"pt_main","nodes":{
+"pt-root":{"id":"pt-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["pt-seq"]},
+"pt-seq":{"id":"pt-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["pt-enemy","pt-boss","pt-idle"]},
+"pt-idle":{"id":"pt-idle","name":"Wait","category":"task","title":"idle","properties":{"forever":true}},
+"pt-enemy":{"id":"pt-enemy","name":"Subtree","category":"decorator","title":"enemy","properties":{"childTag":"pt_attack","in":{"target":"enemy"},"out":{"result":"lastAttackResult"},"isolated":true}},
+"pt-boss":{"id":"pt-boss","name":"Subtree","category":"decorator","title":"boss","properties":{"childTag":"pt_attack"},"remappings":{"target":"boss","result":"bossResult"}}
+}}`)
+	if err := GlobalTreeRegistry().LoadFromJsons([][]byte{main, sub}); err != nil {
+		t.Fatal(err)
+	}
+	bb := bcore.NewBlackboard(1013, nil)
+	bb.Set("enemy", "orc")
+	bb.Set("boss", "dragon")
+	runTickTree(t, bb, "pt_main", nil, nil)
+	if v, _ := bb.Get("lastAttackResult"); v != "orc-hit" {
+		t.Fatalf("lastAttackResult = %v", v)
+	}
+	if v, _ := bb.Get("bossResult"); v != "dragon-hit" {
+		t.Fatalf("bossResult = %v", v)
+	}
+	if _, ok := bb.Get("result"); ok {
+		t.Fatal("mapped key leaked into parent scope")
+	}
+	// 不隔离的容器未映射的键与父树共用,隔离的容器存放在自己的作用域
+	if v, _ := bb.Get("tmp"); v != 1 {
+		t.Fatalf("tmp = %v", v)
+	}
+	if v, _ := bb.Get(bcore.ScopeKeyPrefix + "pt-enemy/tmp"); v != 1 {
+		t.Fatalf("isolated tmp = %v, memory %v", v, bb.UserMemory())
+	}
+}
+
+func TestBrain_TreeParams(t *testing.T) {
+	help()
+	tpl := []byte(`{"root":"tp-sub-root","tag":"tp_say","params":{"out":"said","word":"hello"},"nodes":{
+"tp-sub-root":{"id":"tp-sub-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["tp-sub-action"]},
+"tp-sub-action":{"id":"tp-sub-action","name":"Action","category":"task","title":"say ${word}","properties":{},"delegator":{"script":"blackboard.Set(\"${out}\", \"${word}\"); ResultSucceeded"}}
+}}`)
+	main := []byte(`{"root":"tp-root","tag":"tp_main","params":{"forever":true},"nodes":{
+"tp-root":{"id":"tp-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["tp-seq"]},
+"tp-seq":{"id":"tp-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["tp-a","tp-b","tp-default","tp-idle"]},
+"tp-idle":{"id":"tp-idle","name":"Wait","category":"task","title":"idle","properties":{"forever":"${forever}"}},
+"tp-a":{"id":"tp-a","name":"Subtree","category":"decorator","title":"a","properties":{"childTag":"tp_say","params":{"out":"a","word":"hi"}}},
+"tp-b":{"id":"tp-b","name":"Subtree","category":"decorator","title":"b","properties":{"childTag":"tp_say","params":{"out":"b"}}},
+"tp-default":{"id":"tp-default","name":"Subtree","category":"decorator","title":"default","properties":{"childTag":"tp_say"}}
+}}`)
+	if err := GlobalTreeRegistry().LoadFromJsons([][]byte{main, tpl}); err != nil {
+		t.Fatal(err)
+	}
+	bb := bcore.NewBlackboard(1014, nil)
+	runTickTree(t, bb, "tp_main", nil, nil)
+	for key, want := range map[string]string{"a": "hi", "b": "hello", "said": "hello"} {
+		if v, _ := bb.Get(key); v != want {
+			t.Fatalf("%s = %v, memory %v", key, v, bb.UserMemory())
+		}
+	}
+	// 同一参数集复用缓存的特化树
+	other := NewTickBrain(bcore.NewBlackboard(1015, nil), nil, nil)
+	if err := other.RunWithParams("tp_say", map[string]any{"out": "a", "word": "hi"}, false); err != nil {
+		t.Fatal(err)
+	}
+	other.Tick(0)
+	if v, _ := other.Blackboard().Get("a"); v != "hi" {
+		t.Fatalf("a = %v", v)
+	}
+	specialized := GlobalTreeRegistry().published().specialized("tp_say")
+	keys := lo.Uniq(lo.Map(specialized, func(tree *Tree, _ int) string { return tree.indexKey() }))
+	if len(keys) != 2 {
+		t.Fatalf("specialized keys = %v", keys)
+	}
+	// 无人使用的特化树在延迟清理时从索引中移除,在之后的修改中移除
+	idle := other.RunningTree().ID()
+	other.Abort(nil)
+	other.Tick(0)
+	GlobalTreeRegistry().sweep()
+	indexed := lo.ContainsBy(GlobalTreeRegistry().published().specialized("tp_say"), func(tree *Tree) bool { return tree.Root.ID() == idle })
+	if indexed || GlobalTreeRegistry().TreeByID(idle) == nil {
+		t.Fatalf("idle specialized tree indexed=%v or disposed early", indexed)
+	}
+	if err := other.RunWithParams("tp_say", map[string]any{"out": "c"}, false); err != nil {
+		t.Fatal(err)
+	}
+	other.Tick(0)
+	if GlobalTreeRegistry().TreeByID(idle) != nil {
+		t.Fatal("idle specialized tree not disposed")
+	}
+	if err := other.RunWithParams("tp_say", map[string]any{"unknown": 1}, false); err == nil {
+		t.Fatal("undeclared param accepted")
+	}
+	if err := other.RunWithParams("tp_main", map[string]any{"forever": "yes"}, false); err == nil {
+		t.Fatal("invalid param type accepted")
+	}
+}
+
+func TestBrain_PropertyBinding(t *testing.T) {
+	help()
+	content := []byte(`{"root":"pb-root","tag":"test_property_binding","nodes":{
+"pb-root":{"id":"pb-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["pb-seq"]},
+"pb-seq":{"id":"pb-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["pb-repeat","pb-wait","pb-done"]},
+"pb-repeat":{"id":"pb-repeat","name":"Repeater","category":"decorator","title":"Repeater","properties":{"times":{"$bb":"times","default":1}},"children":["pb-count"]},
+"pb-count":{"id":"pb-count","name":"Action","category":"task","title":"count","properties":{},"delegator":{"script":"blackboard.Set(\"count\", (blackboard.Get(\"count\") ?? 0) + 1); ResultSucceeded"}},
+"pb-wait":{"id":"pb-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":{"$bb":"wait"}}},
+"pb-done":{"id":"pb-done","name":"Action","category":"task","title":"done","properties":{},"delegator":{"script":"blackboard.Set(\"done\", true); ResultSucceeded"}}
+}}`)
+	var cfg config.TreeCfg
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if diagnostics := Validate(&cfg); HasError(diagnostics) {
+		t.Fatalf("diagnostics = %v", diagnostics)
+	}
+	if err := GlobalTreeRegistry().LoadFromJson(content); err != nil {
+		t.Fatal(err)
+	}
+	bb := bcore.NewBlackboard(1016, nil)
+	bb.Set("times", 3)
+	bb.Set("wait", "2s")
+	brain := runTickTree(t, bb, "test_property_binding", nil, nil)
+	if v, _ := bb.Get("count"); v != 3 {
+		t.Fatalf("count = %v", v)
+	}
+	brain.Tick(time.Second)
+	if _, ok := bb.Get("done"); ok {
+		t.Fatal("wait finished before bound wait time")
+	}
+	brain.Tick(time.Second)
+	if v, _ := bb.Get("done"); v != true {
+		t.Fatal("wait not finished after bound wait time")
+	}
+	// 配置的属性不受影响
+	tree := GlobalTreeRegistry().GetNotParentTreeWithoutClone("test_property_binding")
+	repeater := tree.Root.Decorated(nil).(bcore.IComposite).Children()[0]
+	if times := repeater.Properties().(*decorator.RepeaterProperties).Times; times != 1 {
+		t.Fatalf("configured times = %d", times)
+	}
+	// 绑定不存在的属性
+	cfg.Nodes["pb-wait"].Properties = json.RawMessage(`{"waitTimes":{"$bb":"wait"}}`)
+	if diagnostics := Validate(&cfg); !HasError(diagnostics) {
+		t.Fatal("binding unknown property passed validation")
+	}
+}
@@ -0,0 +1,455 @@
+// Package builder 在代码中以链式调用构建行为树,免去手写 config.TreeCfg 的节点ID、子节点ID列表和 json.RawMessage 属性.
+//
+//	tree, err := builder.Root().Sequence(
+//		builder.Wait(3*time.Second),
+//		builder.Action("npc", "Attack"),
+//	).Build("attack")
+//
+// 节点ID在 Build 时生成,同一个 Node 可以多次 Build 出互不冲突的树;子节点数量在 Build 时统一校验.
+package builder
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alkaid/behavior"
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/composite"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/decorator"
+	"github.com/alkaid/behavior/task"
+	"github.com/alkaid/behavior/util"
+)
+
+// RootProperties 根节点属性,同 bcore 中未导出的 rootProperties
+type RootProperties struct {
+	Once            bool          `json:"once"`            // 是否仅运行一次,反之永远循环
+	Interval        util.Duration `json:"interval"`        // 默认帧率(每帧更新间隔,默认50ms)
+	LoopInterval    util.Duration `json:"loopInterval"`    // 每次运行之间的间隔
+	RandomDeviation util.Duration `json:"randomDeviation"` // 每次运行随机离差: LoopInterval = LoopInterval + RandomDeviation*[-0.5,0.5)
+}
+
+// Node 构建中的节点
+type Node struct {
+	name       string
+	category   string
+	title      string
+	properties any
+	delegator  config.DelegatorCfg
+	children   []*Node
+	err        error // 构建过程中产生的错误,延迟到 Build 时返回
+}
+
+// Composite 构建组合节点
+//
+//	@param name 已注册的节点类名
+//	@param properties 属性,将被序列化为json,可以为nil
+//	@param children
+//	@return *Node
+func Composite(name string, properties any, children ...*Node) *Node {
+	return &Node{name: name, category: bcore.CategoryComposite, properties: properties, children: children}
+}
+
+// Decorator 构建装饰节点,子节点通过 Node.Decorate 设置
+//
+//	@param name 已注册的节点类名
+//	@param properties 属性,将被序列化为json,可以为nil
+//	@return *Node
+func Decorator(name string, properties any) *Node {
+	return &Node{name: name, category: bcore.CategoryDecorator, properties: properties}
+}
+
+// Task 构建任务节点
+//
+//	@param name 已注册的节点类名
+//	@param properties 属性,将被序列化为json,可以为nil
+//	@return *Node
+func Task(name string, properties any) *Node {
+	return &Node{name: name, category: bcore.CategoryTask, properties: properties}
+}
+
+// Root 构建根节点
+//
+//	@param properties 可选,默认永远循环
+//	@return *Node
+func Root(properties ...RootProperties) *Node {
+	return Decorator(bcore.NodeNameRoot, optional(properties))
+}
+
+func Sequence(children ...*Node) *Node {
+	return Composite("Sequence", nil, children...)
+}
+
+func Selector(children ...*Node) *Node {
+	return Composite("Selector", nil, children...)
+}
+
+// RandomSequence 随机顺序节点
+//
+//	@param weight 子节点权重,为空则等概率
+//	@param children
+//	@return *Node
+func RandomSequence(weight []int, children ...*Node) *Node {
+	return Composite("RandomSequence", composite.RandomCompositeProperties{Weight: weight}, children...)
+}
+
+// RandomSelector 随机选择节点
+//
+//	@param weight 子节点权重,为空则等概率
+//	@param children
+//	@return *Node
+func RandomSelector(weight []int, children ...*Node) *Node {
+	return Composite("RandomSelector", composite.RandomCompositeProperties{Weight: weight}, children...)
+}
+
+func Parallel(successPolicy bcore.FinishMode, failurePolicy bcore.FinishMode, children ...*Node) *Node {
+	return Composite("Parallel", composite.ParallelProperties{SuccessPolicy: successPolicy, FailurePolicy: failurePolicy}, children...)
+}
+
+func Inverter() *Node {
+	return Decorator("Inverter", nil)
+}
+
+func Succeeded() *Node {
+	return Decorator("Succeeded", nil)
+}
+
+func Failure() *Node {
+	return Decorator("Failure", nil)
+}
+
+func Repeater(properties decorator.RepeaterProperties) *Node {
+	return Decorator("Repeater", properties)
+}
+
+func Limiter(properties decorator.LimiterProperties) *Node {
+	return Decorator("Limiter", properties)
+}
+
+func Random(properties decorator.RandomProperties) *Node {
+	return Decorator("Random", properties)
+}
+
+func Service(properties decorator.ServiceProperties) *Node {
+	return Decorator("Service", properties)
+}
+
+func Cooldown(properties decorator.CooldownProperties) *Node {
+	return Decorator("Cooldown", properties)
+}
+
+func BBCooldown(properties decorator.BBCooldownProperties) *Node {
+	return Decorator("BBCooldown", properties)
+}
+
+func TimeMax(properties decorator.TimeMaxProperties) *Node {
+	return Decorator("TimeMax", properties)
+}
+
+func TimeMin(properties decorator.TimeMinProperties) *Node {
+	return Decorator("TimeMin", properties)
+}
+
+// Condition 条件装饰器,条件由 Node.WithDelegator 或 Node.WithScript 提供
+//
+//	@param properties
+//	@return *Node
+func Condition(properties decorator.ConditionProperties) *Node {
+	return Decorator("Condition", properties)
+}
+
+func BBCondition(properties decorator.BBConditionProperties) *Node {
+	return Decorator("BBCondition", properties)
+}
+
+func BBEntries(properties decorator.BBEntriesProperties) *Node {
+	return Decorator("BBEntries", properties)
+}
+
+func WaitCondition(properties decorator.WaitConditionProperties) *Node {
+	return Decorator("WaitCondition", properties)
+}
+
+// Wait 等待指定时间
+//
+//	@param waitTime
+//	@return *Node
+func Wait(waitTime time.Duration) *Node {
+	return Task("Wait", task.WaitProperties{WaitTime: util.Duration{Duration: waitTime}})
+}
+
+// WaitForever 永久等待直到被外界打断
+//
+//	@return *Node
+func WaitForever() *Node {
+	return Task("Wait", task.WaitProperties{Forever: true})
+}
+
+func WaitBB(properties task.WaitBBProperties) *Node {
+	return Task("WaitBB", properties)
+}
+
+// Action 委托给 target 的 method 执行的任务节点
+//
+//	@param target 委托对象,可以为空,为空则使用root的委托对象
+//	@param method 委托方法
+//	@return *Node
+func Action(target string, method string) *Node {
+	return Task("Action", nil).WithDelegator(target, method)
+}
+
+// Script 执行脚本的任务节点
+//
+//	@param code 脚本,语法参看 script 包
+//	@return *Node
+func Script(code string) *Node {
+	return Task("Action", nil).WithScript(code)
+}
+
+// Subtree 静态子树容器
+//
+//	@param tag 子树的tag
+//	@return *Node
+func Subtree(tag string) *Node {
+	return Decorator(bcore.NodeNameSubtree, task.SubtreeProperties{ChildTag: tag})
+}
+
+func DynamicSubtree(properties task.DynamicSubtreeProperties) *Node {
+	return Decorator(bcore.NodeNameDynamicSubtree, properties)
+}
+
+// Decorate 设置装饰节点的子节点
+//
+//	@receiver n
+//	@param child
+//	@return *Node 装饰节点自己
+func (n *Node) Decorate(child *Node) *Node {
+	switch {
+	case n.category != bcore.CategoryDecorator:
+		n.fail(errors.New(fmt.Sprintf("%s is not a decorator", n.name)))
+	case len(n.children) > 0:
+		n.fail(errors.New(fmt.Sprintf("decorator %s already has a child", n.name)))
+	default:
+		n.children = []*Node{child}
+	}
+	return n
+}
+
+// Sequence 以 Sequence 节点作为装饰节点的子节点
+//
+//	@receiver n
+//	@param children
+//	@return *Node 装饰节点自己
+func (n *Node) Sequence(children ...*Node) *Node {
+	return n.Decorate(Sequence(children...))
+}
+
+// Selector 以 Selector 节点作为装饰节点的子节点
+//
+//	@receiver n
+//	@param children
+//	@return *Node 装饰节点自己
+func (n *Node) Selector(children ...*Node) *Node {
+	return n.Decorate(Selector(children...))
+}
+
+// Add 为组合节点追加子节点
+//
+//	@receiver n
+//	@param children
+//	@return *Node 组合节点自己
+func (n *Node) Add(children ...*Node) *Node {
+	if n.category != bcore.CategoryComposite {
+		n.fail(errors.New(fmt.Sprintf("%s is not a composite", n.name)))
+		return n
+	}
+	n.children = append(n.children, children...)
+	return n
+}
+
+// Title 设置描述,默认为节点类名
+//
+//	@receiver n
+//	@param title
+//	@return *Node
+func (n *Node) Title(title string) *Node {
+	n.title = title
+	return n
+}
+
+// WithProperties 替换属性
+//
+//	@receiver n
+//	@param properties 将被序列化为json
+//	@return *Node
+func (n *Node) WithProperties(properties any) *Node {
+	n.properties = properties
+	return n
+}
+
+// WithDelegator 设置委托
+//
+//	@receiver n
+//	@param target 委托对象,可以为空,为空则使用root的委托对象
+//	@param method 委托方法
+//	@return *Node
+func (n *Node) WithDelegator(target string, method string) *Node {
+	n.delegator.Target = target
+	n.delegator.Method = method
+	return n
+}
+
+// WithScript 设置委托脚本
+//
+//	@receiver n
+//	@param code
+//	@return *Node
+func (n *Node) WithScript(code string) *Node {
+	n.delegator.Script = code
+	return n
+}
+
+// Build 生成树配置,n 必须是 Root 节点
+//
+//	@receiver n
+//	@param tag 行为树标志,必须全局唯一
+//	@return *config.TreeCfg
+//	@return error
+func (n *Node) Build(tag string) (*config.TreeCfg, error) {
+	if n.name != bcore.NodeNameRoot {
+		return nil, errors.New(fmt.Sprintf("tree must start with %s,got %s", bcore.NodeNameRoot, n.name))
+	}
+	cfg := &config.TreeCfg{Nodes: map[string]*config.NodeCfg{}, Tag: tag}
+	root, err := n.build(cfg, map[*Node]bool{}, "0")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Root = root
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cfg.Ver = fmt.Sprintf("%x", md5.Sum(data))
+	return cfg, nil
+}
+
+// Register 生成树配置并加载到注册器,然后挂载子树
+//
+//	@receiver n
+//	@param registry 为nil则使用 behavior.GlobalTreeRegistry
+//	@param tag 行为树标志,必须全局唯一
+//	@return error
+func (n *Node) Register(registry *behavior.TreeRegistry, tag string) error {
+	cfg, err := n.Build(tag)
+	if err != nil {
+		return err
+	}
+	if registry == nil {
+		registry = behavior.GlobalTreeRegistry()
+	}
+	err = registry.Load(cfg)
+	if err != nil {
+		return err
+	}
+	return registry.MountAll()
+}
+
+// build 递归生成节点配置
+//
+//	@receiver n
+//	@param cfg
+//	@param visited 防止同一个节点在树中出现多次
+//	@param path 节点在树中的位置,用于错误提示
+//	@return string 节点ID
+//	@return error
+func (n *Node) build(cfg *config.TreeCfg, visited map[*Node]bool, path string) (string, error) {
+	if n == nil {
+		return "", errors.New(fmt.Sprintf("nil node,path=%s", path))
+	}
+	if n.err != nil {
+		return "", errors.WithMessagef(n.err, "path=%s", path)
+	}
+	if visited[n] {
+		return "", errors.New(fmt.Sprintf("node %s used more than once,path=%s", n.name, path))
+	}
+	visited[n] = true
+	err := n.checkArity()
+	if err != nil {
+		return "", errors.WithMessagef(err, "path=%s", path)
+	}
+	props := []byte("{}")
+	if n.properties != nil {
+		props, err = json.Marshal(n.properties)
+		if err != nil {
+			return "", errors.WithMessagef(err, "marshal properties of %s failed,path=%s", n.name, path)
+		}
+	}
+	nodeCfg := &config.NodeCfg{
+		ID:         util.NanoID(),
+		Name:       n.name,
+		Category:   n.category,
+		Title:      n.title,
+		Properties: props,
+		Delegator:  n.delegator,
+	}
+	if nodeCfg.Title == "" {
+		nodeCfg.Title = n.name
+	}
+	for i, child := range n.children {
+		id, err := child.build(cfg, visited, fmt.Sprintf("%s/%d", path, i))
+		if err != nil {
+			return "", err
+		}
+		nodeCfg.Children = append(nodeCfg.Children, id)
+	}
+	cfg.Nodes[nodeCfg.ID] = nodeCfg
+	return nodeCfg.ID, nil
+}
+
+// checkArity 校验子节点数量
+//
+//	@receiver n
+//	@return error
+func (n *Node) checkArity() error {
+	switch n.category {
+	case bcore.CategoryComposite:
+		if len(n.children) == 0 {
+			return errors.New(fmt.Sprintf("composite %s must have one child at least", n.name))
+		}
+	case bcore.CategoryDecorator:
+		// 子树容器的子节点在挂载时才确定
+		if n.name == bcore.NodeNameSubtree || n.name == bcore.NodeNameDynamicSubtree {
+			if len(n.children) > 0 {
+				return errors.New(fmt.Sprintf("%s cannot have children", n.name))
+			}
+			return nil
+		}
+		if len(n.children) != 1 {
+			return errors.New(fmt.Sprintf("decorator %s must have exactly one child", n.name))
+		}
+	case bcore.CategoryTask:
+		if len(n.children) > 0 {
+			return errors.New(fmt.Sprintf("task %s cannot have children", n.name))
+		}
+	default:
+		return errors.New(fmt.Sprintf("unsupport this category:%s", n.category))
+	}
+	return nil
+}
+
+func (n *Node) fail(err error) {
+	if n.err == nil {
+		n.err = err
+	}
+}
+
+func optional[T any](properties []T) any {
+	if len(properties) == 0 {
+		return nil
+	}
+	return properties[0]
+}
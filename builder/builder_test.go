@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+
+	"github.com/alkaid/behavior"
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/decorator"
+)
+
+func TestBuild(t *testing.T) {
+	tree, err := Root(RootProperties{Once: true}).Sequence(
+		Wait(3*time.Second),
+		Repeater(decorator.RepeaterProperties{Times: 2}).Decorate(Action("npc", "Attack").Title("attack")),
+		Subtree("sub"),
+	).Build("attack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.Nodes[tree.Root]
+	if root.Name != bcore.NodeNameRoot || string(root.Properties) != `{"once":true,"interval":"0s","loopInterval":"0s","randomDeviation":"0s"}` {
+		t.Fatalf("root = %+v", root)
+	}
+	seq := tree.Nodes[root.Children[0]]
+	if seq.Name != "Sequence" || len(seq.Children) != 3 {
+		t.Fatalf("sequence = %+v", seq)
+	}
+	action := tree.Nodes[tree.Nodes[seq.Children[1]].Children[0]]
+	if action.Title != "attack" || action.Delegator.Target != "npc" || action.Delegator.Method != "Attack" {
+		t.Fatalf("action = %+v", action)
+	}
+	if len(tree.Nodes) != 6 || tree.Ver == "" {
+		t.Fatalf("tree = %+v", tree)
+	}
+
+	shared := Wait(time.Second)
+	tests := []struct {
+		name string
+		node *Node
+	}{
+		{"not root", Sequence(Wait(time.Second))},
+		{"empty composite", Root().Decorate(Sequence())},
+		{"decorator without child", Root().Decorate(Inverter())},
+		{"decorate twice", Root().Decorate(Wait(time.Second)).Decorate(Wait(time.Second))},
+		{"decorate task", Root().Decorate(Wait(time.Second).Decorate(Wait(time.Second)))},
+		{"shared node", Root().Sequence(shared, shared)},
+	}
+	for _, tt := range tests {
+		if _, err := tt.node.Build("bad"); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	p, err := ants.NewPoolWithID(ants.DefaultAntsPoolSize, ants.WithExpiryDuration(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	behavior.InitSystem(behavior.WithThreadPool(p))
+	err = Root().Decorate(Wait(time.Second)).Register(nil, "builder_sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Root(RootProperties{Once: true}).Sequence(
+		Script("blackboard.Set(\"hit\", true)"),
+		Subtree("builder_sub"),
+	).Register(nil, "builder_main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := behavior.NewTickBrain(bcore.NewBlackboard(3001, nil), nil, fch)
+	if err = brain.Run("builder_main", false); err != nil {
+		t.Fatal(err)
+	}
+	brain.Tick(0)
+	if hit, _ := brain.Blackboard().Get("hit"); hit != true {
+		t.Fatalf("hit = %v, want true", hit)
+	}
+	brain.Tick(time.Second)
+	select {
+	case event := <-fch:
+		if !event.Succeeded {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished")
+	}
+}
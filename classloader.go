@@ -62,6 +62,20 @@ func (l *ClassLoader) Clone(node bcore.INode) (bcore.INode, error) {
 	return l.New(node.Name(), &cfg)
 }
 
+// newInstance 实例化一个未初始化的节点类,仅用于读取类的元信息(如属性类)
+//
+//	@receiver l
+//	@param name
+//	@return bcore.INode
+//	@return bool 节点类是否已注册
+func (l *ClassLoader) newInstance(name string) (bcore.INode, bool) {
+	v, ok := l.registry[name]
+	if !ok {
+		return nil, false
+	}
+	return reflect.New(v).Interface().(bcore.INode), true
+}
+
 // Contains 检查注册器中是否包含指定节点类
 //
 //	@receiver l
@@ -12,7 +12,6 @@ import (
 type NonParallel struct {
 	bcore.Composite
 	INonParallelWorker
-	needOrder bool
 }
 
 // INonParallelWorker 继承 NonParallel 时必须实现的接口
@@ -49,8 +48,8 @@ func (n *NonParallel) SetCurrIdx(brain bcore.IBrain, currChild int) {
 //	@return int
 func (n *NonParallel) CurrChildIdx(brain bcore.IBrain) int {
 	nodeData := brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory(n.ID())
-	// 无需重新排序的节点,使用单例里的索引
-	if !n.needOrder {
+	// 无需重新排序的节点,直接使用索引.节点是多个brain共享的单例,是否排序须按brain记录在黑板
+	if len(nodeData.ChildrenOrder) == 0 {
 		return nodeData.CurrIndex
 	}
 	// 使用保存到黑板的排序
@@ -108,10 +107,11 @@ func (n *NonParallel) OnStart(brain bcore.IBrain) {
 		childrenOrder[i] = i
 	}
 	// 若子类返回需要重新排序,需要记录排序索引到黑板
-	childrenOrder, n.needOrder = n.INonParallelWorker.OnOrder(brain, childrenOrder)
-	if n.needOrder {
-		brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory(n.ID()).ChildrenOrder = childrenOrder
+	childrenOrder, needOrder := n.INonParallelWorker.OnOrder(brain, childrenOrder)
+	if !needOrder {
+		childrenOrder = nil
 	}
+	brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory(n.ID()).ChildrenOrder = childrenOrder
 	n.processChildren(brain)
 }
 
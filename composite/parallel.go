@@ -1,6 +1,10 @@
 package composite
 
 import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
 	"github.com/alkaid/behavior/bcore"
 	"go.uber.org/zap"
 )
@@ -16,6 +20,21 @@ type ParallelProperties struct {
 	FailurePolicy bcore.FinishMode `json:"failurePolicy"` // 失败策略
 }
 
+// Valid
+//
+//	@implement bcore.IPropertiesValidator .Valid
+//	@receiver p
+//	@return error
+func (p *ParallelProperties) Valid() error {
+	if p.SuccessPolicy < bcore.FinishModeOne || p.SuccessPolicy > bcore.FinishModeAll {
+		return errors.New(fmt.Sprintf("successPolicy out of range,successPolicy=%d", p.SuccessPolicy))
+	}
+	if p.FailurePolicy < bcore.FinishModeOne || p.FailurePolicy > bcore.FinishModeAll {
+		return errors.New(fmt.Sprintf("failurePolicy out of range,failurePolicy=%d", p.FailurePolicy))
+	}
+	return nil
+}
+
 // Parallel 并行组合基类,节点按从左到右的顺序根据结束模式决定完成时机
 //
 //	并行执行所有子节点，根据成功原则和失败原则，决定节点停用时机
@@ -120,11 +139,11 @@ func (p *Parallel) OnChildFinished(brain bcore.IBrain, child bcore.INode, succee
 	// 执行策略 逻辑见最前面 Parallel 的说明
 	if memory.RunningCount == 0 {
 		if !memory.ChildrenAborted {
-			if p.Properties().(IParallelProperties).GetFailurePolicy() == bcore.FinishModeOne && memory.FailedCount > 0 {
+			if p.BoundProperties(brain).(IParallelProperties).GetFailurePolicy() == bcore.FinishModeOne && memory.FailedCount > 0 {
 				memory.Succeeded = false
-			} else if p.Properties().(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeOne && memory.SucceededCount > 0 {
+			} else if p.BoundProperties(brain).(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeOne && memory.SucceededCount > 0 {
 				memory.Succeeded = true
-			} else if p.Properties().(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeAll && memory.SucceededCount == len(p.Children()) {
+			} else if p.BoundProperties(brain).(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeAll && memory.SucceededCount == len(p.Children()) {
 				memory.Succeeded = true
 			} else {
 				memory.Succeeded = false
@@ -138,10 +157,10 @@ func (p *Parallel) OnChildFinished(brain bcore.IBrain, child bcore.INode, succee
 		if memory.FailedCount == len(p.Children()) {
 			p.Log(brain).Error("failed count error")
 		}
-		if p.Properties().(IParallelProperties).GetFailurePolicy() == bcore.FinishModeOne && memory.FailedCount > 0 {
+		if p.BoundProperties(brain).(IParallelProperties).GetFailurePolicy() == bcore.FinishModeOne && memory.FailedCount > 0 {
 			memory.Succeeded = false
 			memory.ChildrenAborted = true
-		} else if p.Properties().(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeOne && memory.SucceededCount > 0 {
+		} else if p.BoundProperties(brain).(IParallelProperties).GetSuccessPolicy() == bcore.FinishModeOne && memory.SucceededCount > 0 {
 			memory.Succeeded = true
 			memory.ChildrenAborted = true
 		}
@@ -50,7 +50,7 @@ func (r *RandomWorker) PropertiesClassProvider() any {
 //	@return needOrder
 func (r *RandomWorker) OnOrder(brain bcore.IBrain, originChildrenOrder []int) (orders []int, needOrder bool) {
 	// 根据权重属性排序,若没有配置,则随机
-	weights := r.node.Properties().(IRandomCompositeProperties).GetWeight()
+	weights := r.node.BoundProperties(brain).(IRandomCompositeProperties).GetWeight()
 	if len(weights) == 0 {
 		return lo.Shuffle(originChildrenOrder), true
 	}
@@ -0,0 +1,365 @@
+package config
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alkaid/behavior/util"
+)
+
+// behavior3editor 工程(JSON)的导入
+//
+//	内置节点的映射关系:
+//	 Sequence/MemSequence      -> Sequence  事件驱动的组合节点总是记住运行中的子节点,故 Mem 与非 Mem 版本映射到同一节点
+//	 Priority/MemPriority      -> Selector
+//	 Inverter                  -> Inverter
+//	 Limiter                   -> Limiter   maxLoop
+//	 MaxTime                   -> TimeMax   maxTime(毫秒)->limit
+//	 Repeater/RepeatUntilFailure -> Repeater maxLoop->times,子节点失败时结束
+//	 RepeatUntilSuccess        -> Repeater  maxLoop->times,untilSuccess=true
+//	 Wait                      -> Wait      milliseconds->waitTime
+//	 Succeeder/Failer/Error/Runner -> Action 以脚本返回固定结果
+//	引用工程中其他树(节点名为树ID)的节点导入为 Subtree.其余节点视为自定义节点,规则同 ParseGrootXML:
+//	action/condition 导入为 Action 节点并委托给与节点名同名的方法,composite/decorator 的节点名即为节点类名,也可通过 WithB3Class 映射到已注册的节点类.
+//	树的 title 作为 TreeCfg.Tag,故必须非空且在工程内唯一.behavior3 的树每帧都会从根重新执行,故生成的 Root 节点永远循环.
+
+const (
+	b3CategoryComposite = "composite"
+	b3CategoryDecorator = "decorator"
+	b3CategoryAction    = "action"
+	b3CategoryCondition = "condition"
+	b3CategoryTree      = "tree"
+)
+
+// b3Builtin behavior3 内置节点的映射
+type b3Builtin struct {
+	name     string                                             // 节点类名
+	category string                                             // 节点类型
+	script   string                                             // 委托脚本
+	props    func(props map[string]any) (map[string]any, error) // 属性转换
+}
+
+var b3Builtins = map[string]*b3Builtin{
+	"Sequence":           {name: "Sequence", category: categoryComposite},
+	"MemSequence":        {name: "Sequence", category: categoryComposite},
+	"Priority":           {name: "Selector", category: categoryComposite},
+	"MemPriority":        {name: "Selector", category: categoryComposite},
+	"Inverter":           {name: "Inverter", category: categoryDecorator},
+	"Limiter":            {name: "Limiter", category: categoryDecorator, props: b3LoopProps("maxLoop", false)},
+	"MaxTime":            {name: "TimeMax", category: categoryDecorator, props: b3MsecProps("maxTime", "limit")},
+	"Repeater":           {name: nodeNameRepeater, category: categoryDecorator, props: b3LoopProps("times", false)},
+	"RepeatUntilFailure": {name: nodeNameRepeater, category: categoryDecorator, props: b3LoopProps("times", false)},
+	"RepeatUntilSuccess": {name: nodeNameRepeater, category: categoryDecorator, props: b3LoopProps("times", true)},
+	"Wait":               {name: "Wait", category: categoryTask, props: b3MsecProps("milliseconds", "waitTime")},
+	"Succeeder":          {name: nodeNameAction, category: categoryTask, script: "true"},
+	"Failer":             {name: nodeNameAction, category: categoryTask, script: "false"},
+	"Error":              {name: nodeNameAction, category: categoryTask, script: "false"},
+	"Runner":             {name: nodeNameAction, category: categoryTask, script: "ResultInProgress"},
+}
+
+// B3Option behavior3 工程导入选项
+type B3Option func(opts *importOptions)
+
+// WithB3Target 设置导入的自定义叶子节点的委托对象,默认为空(使用root的委托对象)
+//
+//	@param target
+//	@return B3Option
+func WithB3Target(target string) B3Option {
+	return func(opts *importOptions) {
+		opts.target = target
+	}
+}
+
+// WithB3Class 将自定义节点名映射到已注册的节点类,映射后的节点不再生成委托
+//
+//	@param name behavior3 中的节点名
+//	@param className 已注册的节点类名
+//	@return B3Option
+func WithB3Class(name string, className string) B3Option {
+	return func(opts *importOptions) {
+		opts.classes[name] = className
+	}
+}
+
+// b3Project behavior3editor 导出的工程,编辑器保存的工程文件将工程包在 data 中
+type b3Project struct {
+	Data        *b3Project     `json:"data"`
+	Scope       string         `json:"scope"`
+	Trees       []*b3Tree      `json:"trees"`
+	CustomNodes []b3CustomNode `json:"custom_nodes"`
+}
+
+// b3Tree behavior3 树,单独导出的树文件也使用该格式
+type b3Tree struct {
+	ID          string             `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Root        string             `json:"root"`
+	Nodes       map[string]*b3Node `json:"nodes"`
+}
+
+type b3Node struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Category   string          `json:"category"`
+	Title      string          `json:"title"`
+	Properties json.RawMessage `json:"properties"`
+	Child      string          `json:"child"`    // 装饰节点的子节点
+	Children   []string        `json:"children"` // 组合节点的子节点
+}
+
+type b3CustomNode struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// ParseB3Project 将 behavior3editor 导出的工程或树(JSON)转换为树配置,工程中的每棵树对应一个配置
+//
+//	@param data
+//	@param opts
+//	@return []*TreeCfg
+//	@return error
+func ParseB3Project(data []byte, opts ...B3Option) ([]*TreeCfg, error) {
+	var project b3Project
+	err := json.Unmarshal(data, &project)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if project.Data != nil {
+		project = *project.Data
+	}
+	// 单独导出的树
+	if project.Scope == b3CategoryTree {
+		var tree b3Tree
+		err = json.Unmarshal(data, &tree)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		project.Trees = []*b3Tree{&tree}
+	}
+	if len(project.Trees) == 0 {
+		return nil, errors.New("no tree found in behavior3 project")
+	}
+	p := &b3Parser{opts: newImportOptions(opts), categories: map[string]string{}, tags: map[string]string{}}
+	for _, node := range project.CustomNodes {
+		p.categories[node.Name] = node.Category
+	}
+	tags := map[string]bool{}
+	for _, tree := range project.Trees {
+		if tree.Title == "" {
+			return nil, errors.New(fmt.Sprintf("tree title cannot be nil,id=%s", tree.ID))
+		}
+		if tags[tree.Title] {
+			return nil, errors.New(fmt.Sprintf("duplicate tree title,title=%s", tree.Title))
+		}
+		tags[tree.Title] = true
+		p.tags[tree.ID] = tree.Title
+	}
+	ver := fmt.Sprintf("%x", md5.Sum(data))
+	cfgs := make([]*TreeCfg, 0, len(project.Trees))
+	for _, tree := range project.Trees {
+		cfg, err := p.parseTree(tree)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse behavior3 tree failed,title=%s", tree.Title)
+		}
+		cfg.Ver = ver
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, nil
+}
+
+type b3Parser struct {
+	opts       *importOptions
+	categories map[string]string // 自定义节点名->节点类型
+	tags       map[string]string // 树ID->树tag
+	tree       *b3Tree           // 当前树
+	nodes      map[string]*NodeCfg
+	visited    map[string]bool // 当前树已转换的behavior3节点ID
+}
+
+func (p *b3Parser) parseTree(tree *b3Tree) (*TreeCfg, error) {
+	if tree.Root == "" {
+		return nil, errors.New("tree root cannot be nil")
+	}
+	p.tree = tree
+	p.nodes = map[string]*NodeCfg{}
+	p.visited = map[string]bool{}
+	childID, err := p.parseNode(tree.Root)
+	if err != nil {
+		return nil, err
+	}
+	root := &NodeCfg{
+		ID:         util.NanoID(),
+		Name:       nodeNameRoot,
+		Category:   categoryDecorator,
+		Title:      nodeNameRoot,
+		Children:   []string{childID},
+		Properties: json.RawMessage("{}"),
+	}
+	p.nodes[root.ID] = root
+	return &TreeCfg{Nodes: p.nodes, Root: root.ID, Tag: tree.Title, Description: tree.Description}, nil
+}
+
+//nolint:gocyclo
+func (p *b3Parser) parseNode(id string) (string, error) {
+	node, ok := p.tree.Nodes[id]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("node not found,id=%s", id))
+	}
+	if p.visited[id] {
+		return "", errors.New(fmt.Sprintf("node referenced more than once,id=%s", id))
+	}
+	p.visited[id] = true
+	props := map[string]any{}
+	if len(node.Properties) > 0 && string(node.Properties) != "null" {
+		err := json.Unmarshal(node.Properties, &props)
+		if err != nil {
+			return "", errors.WithMessagef(err, "unmarshal properties failed,id=%s", id)
+		}
+	}
+	cfg := &NodeCfg{ID: util.NanoID(), Title: node.Title}
+	if cfg.Title == "" {
+		cfg.Title = node.Name
+	}
+	var children []string
+	if node.Child != "" {
+		children = append(children, node.Child)
+	}
+	children = append(children, node.Children...)
+	if tag, ok := p.tags[node.Name]; ok {
+		// 引用工程中的其他树
+		cfg.Name = nodeNameSubtree
+		cfg.Category = categoryDecorator
+		props = map[string]any{"childTag": tag}
+		children = nil
+	} else if builtin, ok := b3Builtins[node.Name]; ok {
+		cfg.Name = builtin.name
+		cfg.Category = builtin.category
+		cfg.Delegator.Script = builtin.script
+		if builtin.props != nil {
+			var err error
+			props, err = builtin.props(props)
+			if err != nil {
+				return "", errors.WithMessagef(err, "invalid properties of %s,id=%s", node.Name, id)
+			}
+		}
+	} else {
+		category := p.categories[node.Name]
+		if category == "" {
+			category = node.Category
+		}
+		switch category {
+		case b3CategoryAction, b3CategoryCondition:
+			cfg.Category = categoryTask
+		case b3CategoryDecorator:
+			cfg.Category = categoryDecorator
+		case b3CategoryComposite:
+			cfg.Category = categoryComposite
+		default:
+			cfg.Category = grootCategory("", len(children))
+			if node.Child != "" {
+				cfg.Category = categoryDecorator
+			}
+		}
+		if class, ok := p.opts.classes[node.Name]; ok {
+			cfg.Name = class
+		} else if cfg.Category == categoryTask {
+			cfg.Name = nodeNameAction
+			cfg.Delegator = DelegatorCfg{Target: p.opts.target, Method: node.Name}
+		} else {
+			cfg.Name = node.Name
+		}
+	}
+	switch cfg.Category {
+	case categoryTask:
+		if len(children) > 0 {
+			return "", errors.New(fmt.Sprintf("leaf node %s cannot have children,id=%s", node.Name, id))
+		}
+	case categoryDecorator:
+		if cfg.Name != nodeNameSubtree && len(children) != 1 {
+			return "", errors.New(fmt.Sprintf("decorator %s must have exactly one child,id=%s", node.Name, id))
+		}
+	case categoryComposite:
+		if len(children) == 0 {
+			return "", errors.New(fmt.Sprintf("composite %s must have one child at least,id=%s", node.Name, id))
+		}
+	}
+	var err error
+	cfg.Properties, err = json.Marshal(props)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	p.nodes[cfg.ID] = cfg
+	for _, childID := range children {
+		childID, err = p.parseNode(childID)
+		if err != nil {
+			return "", err
+		}
+		cfg.Children = append(cfg.Children, childID)
+	}
+	return cfg.ID, nil
+}
+
+// b3Number 读取数字属性
+//
+//	@param props
+//	@param key
+//	@return float64
+//	@return bool 属性是否存在
+//	@return error
+func b3Number(props map[string]any, key string) (float64, bool, error) {
+	v, ok := props[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, true, errors.New(fmt.Sprintf("property %s must be a number,got %v", key, v))
+	}
+	return n, true, nil
+}
+
+// b3LoopProps 循环次数属性 maxLoop 转换,behavior3 中 -1 表示永远循环
+//
+//	@param prop 目标属性名
+//	@param untilSuccess 是否为重试模式,仅 Repeater 有效
+//	@return func(props map[string]any) (map[string]any, error)
+func b3LoopProps(prop string, untilSuccess bool) func(props map[string]any) (map[string]any, error) {
+	return func(props map[string]any) (map[string]any, error) {
+		out := map[string]any{}
+		n, ok, err := b3Number(props, "maxLoop")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out[prop] = max(int(n), 0)
+		}
+		if prop == "times" {
+			out["untilSuccess"] = untilSuccess
+		}
+		return out, nil
+	}
+}
+
+// b3MsecProps 毫秒属性转换为 util.Duration 格式
+//
+//	@param src 源属性名
+//	@param dst 目标属性名
+//	@return func(props map[string]any) (map[string]any, error)
+func b3MsecProps(src string, dst string) func(props map[string]any) (map[string]any, error) {
+	return func(props map[string]any) (map[string]any, error) {
+		out := map[string]any{}
+		n, ok, err := b3Number(props, src)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out[dst] = (time.Duration(n) * time.Millisecond).String()
+		}
+		return out, nil
+	}
+}
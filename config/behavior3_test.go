@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseB3Project(t *testing.T) {
+	// 单独导出的树
+	content := `
+{"version":"0.3.0","scope":"tree","id":"t1","title":"patrol","description":"desc","root":"n1","nodes":{
+"n1":{"id":"n1","name":"MemPriority","title":"MemPriority","properties":{},"children":["n2","n4"]},
+"n2":{"id":"n2","name":"RepeatUntilSuccess","title":"retry","properties":{"maxLoop":-1},"child":"n3"},
+"n3":{"id":"n3","name":"IsEnemyNear","title":"IsEnemyNear","properties":{"range":5}},
+"n4":{"id":"n4","name":"MaxTime","title":"MaxTime","properties":{"maxTime":1500},"child":"n5"},
+"n5":{"id":"n5","name":"Runner","title":"Runner","properties":{}}
+},"custom_nodes":[{"name":"IsEnemyNear","category":"condition"}]}`
+	cfgs, err := ParseB3Project([]byte(content), WithB3Target("npc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Tag != "patrol" || cfgs[0].Description != "desc" {
+		t.Fatalf("unexpected trees %+v", cfgs)
+	}
+	got := dumpGrootTree(t, cfgs[0], cfgs[0].Root)
+	want := `Root{}[Selector:MemPriority{}[Repeater:retry{"times":0,"untilSuccess":true}[Action:IsEnemyNear{"range":5}(npc.IsEnemyNear)],TimeMax:MaxTime{"limit":"1.5s"}[Action:Runner{}]]]`
+	if got != want {
+		t.Fatalf("imported tree\n got %s\nwant %s", got, want)
+	}
+	if script := findNode(cfgs[0], "Runner").Delegator.Script; script != "ResultInProgress" {
+		t.Fatalf("runner script = %s", script)
+	}
+	// 装饰节点缺少子节点
+	_, err = ParseB3Project([]byte(`{"scope":"tree","id":"t1","title":"bad","root":"n1","nodes":{"n1":{"id":"n1","name":"Inverter","properties":{}}}}`))
+	if err == nil {
+		t.Fatal("want error for decorator without child")
+	}
+}
+
+func findNode(cfg *TreeCfg, title string) *NodeCfg {
+	for _, n := range cfg.Nodes {
+		if n.Title == title {
+			return n
+		}
+	}
+	return nil
+}
@@ -9,13 +9,14 @@ import (
 
 // NodeCfg 节点配置,也是默认解析器所能解析的格式
 type NodeCfg struct {
-	ID         string          `json:"id"`         // 唯一ID
-	Name       string          `json:"name"`       // 节点名
-	Category   string          `json:"category"`   // 类型
-	Title      string          `json:"title"`      // 描述
-	Children   []string        `json:"children"`   // 孩子节点
-	Properties json.RawMessage `json:"properties"` // 自定义属性,须由子类自行解析
-	Delegator  DelegatorCfg    `json:"delegator"`  // 委托配置
+	ID         string            `json:"id"`                   // 唯一ID
+	Name       string            `json:"name"`                 // 节点名
+	Category   string            `json:"category"`             // 类型
+	Title      string            `json:"title"`                // 描述
+	Children   []string          `json:"children"`             // 孩子节点
+	Properties json.RawMessage   `json:"properties"`           // 自定义属性,须由子类自行解析
+	Delegator  DelegatorCfg      `json:"delegator"`            // 委托配置
+	Remappings map[string]string `json:"remappings,omitempty"` // 黑板键重映射:端口名->黑板键
 }
 
 func (n *NodeCfg) Valid() error {
@@ -40,11 +41,14 @@ type DelegatorCfg struct {
 
 // TreeCfg 树配置
 type TreeCfg struct {
-	Nodes       map[string]*NodeCfg `json:"nodes"`       // 所有节点
-	Ver         string              `json:"ver"`         // 数据版本
-	Root        string              `json:"root"`        // 根节点nanoID
-	Tag         string              `json:"tag"`         // 行为树标志,必须能简要描述业务逻辑且全局唯一
-	Description string              `json:"description"` // 业务逻辑详细描述
+	Nodes       map[string]*NodeCfg `json:"nodes"`            // 所有节点
+	Ver         string              `json:"ver"`              // 数据版本
+	Root        string              `json:"root"`             // 根节点nanoID
+	Tag         string              `json:"tag"`              // 行为树标志,必须能简要描述业务逻辑且全局唯一
+	Description string              `json:"description"`      // 业务逻辑详细描述
+	Params      map[string]any      `json:"params,omitempty"` // 模板参数及其默认值,节点配置中以 ${参数名} 引用,参看 TreeCfg.Specialize
+	// 黑板键声明,可为空.声明后静态检查会核对节点引用的键,运行树时写入默认值,严格模式下拒绝类型不符的写入
+	Blackboard map[string]*BlackboardKeyCfg `json:"blackboard,omitempty"`
 }
 
 func (c *TreeCfg) Valid() error {
@@ -57,5 +61,13 @@ func (c *TreeCfg) Valid() error {
 	if len(c.Nodes) == 0 {
 		return errors.New("nodes length is zero")
 	}
+	for key, decl := range c.Blackboard {
+		if decl == nil {
+			continue
+		}
+		if err := decl.Valid(); err != nil {
+			return errors.WithMessagef(err, "invalid blackboard key,tag=%s,key=%s", c.Tag, key)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,742 @@
+package config
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alkaid/behavior/util"
+)
+
+// BehaviorTree.CPP v4 (Groot2) XML 的导入与导出
+//
+//	内置节点的映射关系:
+//	 Sequence             <-> Sequence
+//	 Fallback             <-> Selector
+//	 Parallel             <-> Parallel  success_count/failure_count 为1时对应 FinishModeOne,-1时对应 FinishModeAll,不支持其他数量.缺省值同 BehaviorTree.CPP,分别为-1和1
+//	 Inverter             <-> Inverter
+//	 ForceSuccess         <-> Succeeded
+//	 ForceFailure         <-> Failure
+//	 RetryUntilSuccessful <-> Repeater  num_attempts->times,untilSuccess=true
+//	 Repeat               <-> Repeater  num_cycles->times
+//	 Timeout              <-> TimeMax   msec->limit
+//	 Sleep                <-> Wait      msec->waitTime
+//	 SubTree              <-> Subtree   ID->childTag
+//	其余节点视为自定义节点:叶子节点(Action/Condition)导入为 Action 节点并委托给与节点ID同名的方法,
+//	装饰节点和控制节点的节点名即为节点ID,也可通过 WithGrootClass 映射到已注册的节点类.
+//	端口值为 {key} 形式时导入为黑板键重映射 NodeCfg.Remappings(SubTree 的重映射在运行时即子树的输入输出端口),否则导入为自定义属性.以下划线开头的端口(如 _autoremap)将被忽略.
+//	XML没有根节点的概念,导入时会为每棵树生成仅运行一次的 Root 节点,导出时 Root 节点的属性将被丢弃.
+
+const (
+	grootFormat     = "4"
+	grootKindAction = "Action"
+	grootKindCond   = "Condition"
+	grootKindDec    = "Decorator"
+	grootKindCtrl   = "Control"
+	grootKindSub    = "SubTree"
+	grootTagRoot    = "root"
+	grootTagTree    = "BehaviorTree"
+	grootTagModel   = "TreeNodesModel"
+	grootAttrID     = "ID"
+	grootAttrName   = "name"
+	grootAttrMain   = "main_tree_to_execute"
+	grootAttrFormat = "BTCPP_format"
+
+	nodeNameRoot     = "Root"
+	nodeNameAction   = "Action"
+	nodeNameSubtree  = "Subtree"
+	nodeNameRepeater = "Repeater"
+
+	categoryComposite = "composite"
+	categoryDecorator = "decorator"
+	categoryTask      = "task"
+
+	finishModeOne = 0 // 同 bcore.FinishModeOne
+	finishModeAll = 1 // 同 bcore.FinishModeAll
+)
+
+// grootPort 内置节点端口与节点属性的映射
+type grootPort struct {
+	attr string                        // XML端口名
+	prop string                        // 节点属性名
+	imp  func(val string) (any, error) // XML->属性
+	exp  func(val any) (string, error) // 属性->XML
+	def  string                        // XML中缺省该端口时的值,为空则不设置属性
+	zero any                           // 节点缺省该属性时的值,def 不为空时导出须显式写出,避免导入时取 def
+}
+
+// grootBuiltin 内置节点的映射
+type grootBuiltin struct {
+	tag      string         // XML标签
+	name     string         // 节点类名
+	category string         // 节点类型
+	ports    []grootPort    // 端口映射
+	fixed    map[string]any // 导入时附加的固定属性,导出时据此区分同名节点类
+}
+
+var grootBuiltins = []*grootBuiltin{
+	{tag: "Sequence", name: "Sequence", category: categoryComposite},
+	{tag: "Fallback", name: "Selector", category: categoryComposite},
+	{tag: "Parallel", name: "Parallel", category: categoryComposite, ports: []grootPort{
+		{attr: "success_count", prop: "successPolicy", imp: grootImpFinishMode, exp: grootExpFinishMode, def: "-1", zero: float64(finishModeOne)},
+		{attr: "failure_count", prop: "failurePolicy", imp: grootImpFinishMode, exp: grootExpFinishMode, def: "1", zero: float64(finishModeOne)},
+	}},
+	{tag: "Inverter", name: "Inverter", category: categoryDecorator},
+	{tag: "ForceSuccess", name: "Succeeded", category: categoryDecorator},
+	{tag: "ForceFailure", name: "Failure", category: categoryDecorator},
+	{tag: "RetryUntilSuccessful", name: nodeNameRepeater, category: categoryDecorator, ports: []grootPort{
+		{attr: "num_attempts", prop: "times", imp: grootImpTimes, exp: grootExpTimes},
+	}, fixed: map[string]any{"untilSuccess": true}},
+	{tag: "Repeat", name: nodeNameRepeater, category: categoryDecorator, ports: []grootPort{
+		{attr: "num_cycles", prop: "times", imp: grootImpTimes, exp: grootExpTimes},
+	}, fixed: map[string]any{"untilSuccess": false}},
+	{tag: "Timeout", name: "TimeMax", category: categoryDecorator, ports: []grootPort{
+		{attr: "msec", prop: "limit", imp: grootImpMsec, exp: grootExpMsec},
+	}},
+	{tag: "Sleep", name: "Wait", category: categoryTask, ports: []grootPort{
+		{attr: "msec", prop: "waitTime", imp: grootImpMsec, exp: grootExpMsec},
+	}},
+}
+
+// GrootOption Groot XML 导入导出选项
+type GrootOption func(opts *importOptions)
+
+type importOptions struct {
+	target  string            // 自定义叶子节点的委托对象
+	classes map[string]string // 自定义节点ID->节点类名
+}
+
+// WithGrootTarget 设置导入的自定义叶子节点的委托对象,默认为空(使用root的委托对象)
+//
+//	@param target
+//	@return GrootOption
+func WithGrootTarget(target string) GrootOption {
+	return func(opts *importOptions) {
+		opts.target = target
+	}
+}
+
+// WithGrootClass 将自定义节点ID映射到已注册的节点类,映射后的节点不再生成委托
+//
+//	@param id XML中的节点ID
+//	@param className 已注册的节点类名
+//	@return GrootOption
+func WithGrootClass(id string, className string) GrootOption {
+	return func(opts *importOptions) {
+		opts.classes[id] = className
+	}
+}
+
+func newImportOptions[T ~func(opts *importOptions)](opts []T) *importOptions {
+	o := &importOptions{classes: map[string]string{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// xmlNode 通用的XML元素
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func (x *xmlNode) attr(name string) (string, bool) {
+	return grootAttr(x.Attrs, name)
+}
+
+// ParseGrootXML 将 BehaviorTree.CPP v4 (Groot2) XML 转换为树配置,每个 BehaviorTree 元素对应一棵树,按文档顺序返回
+//
+//	@param data
+//	@param opts
+//	@return []*TreeCfg
+//	@return error
+func ParseGrootXML(data []byte, opts ...GrootOption) ([]*TreeCfg, error) {
+	var doc xmlNode
+	err := xml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if doc.XMLName.Local != grootTagRoot {
+		return nil, errors.New(fmt.Sprintf("groot xml root element must be <%s>,got <%s>", grootTagRoot, doc.XMLName.Local))
+	}
+	p := &grootParser{opts: newImportOptions(opts), kinds: map[string]string{}}
+	for _, x := range doc.Nodes {
+		if x.XMLName.Local != grootTagModel {
+			continue
+		}
+		for _, model := range x.Nodes {
+			if id, ok := model.attr(grootAttrID); ok {
+				p.kinds[id] = model.XMLName.Local
+			}
+		}
+	}
+	ver := fmt.Sprintf("%x", md5.Sum(data))
+	var cfgs []*TreeCfg
+	for _, x := range doc.Nodes {
+		if x.XMLName.Local != grootTagTree {
+			continue
+		}
+		tag, _ := x.attr(grootAttrID)
+		if tag == "" {
+			return nil, errors.New("BehaviorTree ID cannot be nil")
+		}
+		if len(x.Nodes) != 1 {
+			return nil, errors.New(fmt.Sprintf("BehaviorTree must have exactly one child,tag=%s", tag))
+		}
+		p.nodes = map[string]*NodeCfg{}
+		childID, err := p.parseNode(&x.Nodes[0])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse BehaviorTree failed,tag=%s", tag)
+		}
+		root := &NodeCfg{
+			ID:         util.NanoID(),
+			Name:       nodeNameRoot,
+			Category:   categoryDecorator,
+			Title:      nodeNameRoot,
+			Children:   []string{childID},
+			Properties: json.RawMessage(`{"once":true}`),
+		}
+		p.nodes[root.ID] = root
+		cfgs = append(cfgs, &TreeCfg{Nodes: p.nodes, Ver: ver, Root: root.ID, Tag: tag})
+	}
+	if len(cfgs) == 0 {
+		return nil, errors.New("no BehaviorTree found in groot xml")
+	}
+	return cfgs, nil
+}
+
+type grootParser struct {
+	opts  *importOptions
+	kinds map[string]string   // TreeNodesModel 中声明的节点ID->节点种类
+	nodes map[string]*NodeCfg // 当前树的节点
+}
+
+//nolint:gocyclo
+func (p *grootParser) parseNode(x *xmlNode) (string, error) {
+	tag := x.XMLName.Local
+	kind := p.kinds[tag]
+	attrs := x.Attrs
+	// 显式形式 <Action ID="xxx"/>
+	switch tag {
+	case grootKindAction, grootKindCond, grootKindDec, grootKindCtrl:
+		id, ok := x.attr(grootAttrID)
+		if !ok {
+			return "", errors.New(fmt.Sprintf("<%s> must have an ID", tag))
+		}
+		kind = tag
+		tag = id
+		attrs = grootWithoutAttr(attrs, grootAttrID)
+	}
+	cfg := &NodeCfg{ID: util.NanoID(), Title: tag}
+	if title, ok := x.attr(grootAttrName); ok && title != "" {
+		cfg.Title = title
+	}
+	attrs = grootWithoutAttr(attrs, grootAttrName)
+	props := map[string]any{}
+	builtin := grootBuiltinByTag(tag)
+	switch {
+	case kind == "" && builtin != nil:
+		cfg.Name = builtin.name
+		cfg.Category = builtin.category
+		for k, v := range builtin.fixed {
+			props[k] = v
+		}
+		for _, port := range builtin.ports {
+			val, ok := grootAttr(attrs, port.attr)
+			if !ok && port.def == "" {
+				continue
+			}
+			if !ok {
+				val = port.def
+			}
+			attrs = grootWithoutAttr(attrs, port.attr)
+			if key, ok := grootRemappedKey(val); ok {
+				cfg.remap(port.prop, key)
+				continue
+			}
+			v, err := port.imp(val)
+			if err != nil {
+				return "", errors.WithMessagef(err, "invalid port %s=%s of <%s>", port.attr, val, tag)
+			}
+			props[port.prop] = v
+		}
+	case tag == grootKindSub:
+		id, ok := x.attr(grootAttrID)
+		if !ok {
+			return "", errors.New("<SubTree> must have an ID")
+		}
+		cfg.Name = nodeNameSubtree
+		cfg.Category = categoryDecorator
+		if cfg.Title == tag {
+			cfg.Title = id
+		}
+		props["childTag"] = id
+		attrs = grootWithoutAttr(attrs, grootAttrID)
+	default:
+		cfg.Category = grootCategory(kind, len(x.Nodes))
+		if class, ok := p.opts.classes[tag]; ok {
+			cfg.Name = class
+		} else if cfg.Category == categoryTask {
+			cfg.Name = nodeNameAction
+			cfg.Delegator = DelegatorCfg{Target: p.opts.target, Method: tag}
+		} else {
+			cfg.Name = tag
+		}
+	}
+	// 剩余端口
+	for _, a := range attrs {
+		if strings.HasPrefix(a.Name.Local, "_") {
+			continue
+		}
+		if key, ok := grootRemappedKey(a.Value); ok {
+			cfg.remap(a.Name.Local, key)
+			continue
+		}
+		props[a.Name.Local] = grootLiteral(a.Value)
+	}
+	var err error
+	cfg.Properties, err = json.Marshal(props)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	switch cfg.Category {
+	case categoryTask:
+		if len(x.Nodes) > 0 {
+			return "", errors.New(fmt.Sprintf("leaf node <%s> cannot have children", tag))
+		}
+	case categoryDecorator:
+		if cfg.Name != nodeNameSubtree && len(x.Nodes) != 1 {
+			return "", errors.New(fmt.Sprintf("decorator <%s> must have exactly one child", tag))
+		}
+	case categoryComposite:
+		if len(x.Nodes) == 0 {
+			return "", errors.New(fmt.Sprintf("control <%s> must have one child at least", tag))
+		}
+	}
+	for i := range x.Nodes {
+		childID, err := p.parseNode(&x.Nodes[i])
+		if err != nil {
+			return "", err
+		}
+		cfg.Children = append(cfg.Children, childID)
+	}
+	p.nodes[cfg.ID] = cfg
+	return cfg.ID, nil
+}
+
+// ExportGrootXML 将树配置导出为 BehaviorTree.CPP v4 (Groot2) XML,第一棵树作为 main_tree_to_execute
+//
+//	@param cfgs
+//	@param opts 与导入时相同的选项,用于还原 WithGrootClass 映射
+//	@return []byte
+//	@return error
+func ExportGrootXML(cfgs []*TreeCfg, opts ...GrootOption) ([]byte, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("no tree to export")
+	}
+	e := &grootExporter{opts: newImportOptions(opts), ids: map[string]string{}, models: map[string]string{}}
+	for id, class := range e.opts.classes {
+		e.ids[class] = id
+	}
+	doc := xmlNode{XMLName: xml.Name{Local: grootTagRoot}, Attrs: []xml.Attr{
+		{Name: xml.Name{Local: grootAttrFormat}, Value: grootFormat},
+		{Name: xml.Name{Local: grootAttrMain}, Value: cfgs[0].Tag},
+	}}
+	for _, cfg := range cfgs {
+		err := cfg.Valid()
+		if err != nil {
+			return nil, err
+		}
+		e.tree = cfg
+		rootID := cfg.Root
+		if root := cfg.Nodes[rootID]; root != nil && root.Name == nodeNameRoot {
+			if len(root.Children) != 1 {
+				return nil, errors.New(fmt.Sprintf("root must have exactly one child,tag=%s", cfg.Tag))
+			}
+			rootID = root.Children[0]
+		}
+		x, err := e.exportNode(rootID)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "export tree failed,tag=%s", cfg.Tag)
+		}
+		doc.Nodes = append(doc.Nodes, xmlNode{
+			XMLName: xml.Name{Local: grootTagTree},
+			Attrs:   []xml.Attr{{Name: xml.Name{Local: grootAttrID}, Value: cfg.Tag}},
+			Nodes:   []xmlNode{*x},
+		})
+	}
+	if len(e.models) > 0 {
+		model := xmlNode{XMLName: xml.Name{Local: grootTagModel}}
+		for _, id := range sortedKeys(e.models) {
+			model.Nodes = append(model.Nodes, xmlNode{
+				XMLName: xml.Name{Local: e.models[id]},
+				Attrs:   []xml.Attr{{Name: xml.Name{Local: grootAttrID}, Value: id}},
+			})
+		}
+		doc.Nodes = append(doc.Nodes, model)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	err := enc.Encode(&doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+type grootExporter struct {
+	opts   *importOptions
+	ids    map[string]string // 节点类名->自定义节点ID
+	models map[string]string // 需要声明的自定义节点ID->节点种类
+	tree   *TreeCfg          // 当前树
+}
+
+//nolint:gocyclo
+func (e *grootExporter) exportNode(id string) (*xmlNode, error) {
+	cfg, ok := e.tree.Nodes[id]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("node not found,id=%s", id))
+	}
+	props := map[string]any{}
+	if len(cfg.Properties) > 0 {
+		err := json.Unmarshal(cfg.Properties, &props)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unmarshal properties failed,id=%s", id)
+		}
+	}
+	var tag string
+	var attrs []xml.Attr
+	builtin := grootBuiltinByName(cfg.Name, props)
+	switch {
+	case builtin != nil:
+		tag = builtin.tag
+		for k := range builtin.fixed {
+			delete(props, k)
+		}
+		for _, port := range builtin.ports {
+			if key, ok := cfg.Remappings[port.prop]; ok {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: port.attr}, Value: "{" + key + "}"})
+				continue
+			}
+			val, ok := props[port.prop]
+			if !ok && port.def == "" {
+				continue
+			}
+			if !ok {
+				val = port.zero
+			}
+			delete(props, port.prop)
+			str, err := port.exp(val)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "invalid property %s of %s,id=%s", port.prop, cfg.Name, id)
+			}
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: port.attr}, Value: str})
+		}
+		// 无法用XML表达的属性直接丢弃
+		props = map[string]any{}
+	case cfg.Name == nodeNameSubtree:
+		tag = grootKindSub
+		childTag, _ := props["childTag"].(string)
+		if childTag == "" {
+			return nil, errors.New(fmt.Sprintf("subtree childTag cannot be nil,id=%s", id))
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: grootAttrID}, Value: childTag})
+		props = map[string]any{}
+	case cfg.Name == nodeNameAction && cfg.Delegator.Method != "":
+		if cfg.Delegator.Script != "" {
+			return nil, errors.New(fmt.Sprintf("script delegator cannot be exported,id=%s", id))
+		}
+		tag = cfg.Delegator.Method
+		e.models[tag] = grootKindAction
+	default:
+		if cfg.Delegator.Script != "" || cfg.Delegator.Method != "" {
+			return nil, errors.New(fmt.Sprintf("delegator of %s cannot be exported,id=%s", cfg.Name, id))
+		}
+		tag = cfg.Name
+		if mapped, ok := e.ids[cfg.Name]; ok {
+			tag = mapped
+		}
+		switch cfg.Category {
+		case categoryComposite:
+			e.models[tag] = grootKindCtrl
+		case categoryDecorator:
+			e.models[tag] = grootKindDec
+		default:
+			e.models[tag] = grootKindAction
+		}
+	}
+	if cfg.Title != "" && cfg.Title != tag {
+		attrs = append([]xml.Attr{{Name: xml.Name{Local: grootAttrName}, Value: cfg.Title}}, attrs...)
+	}
+	for _, k := range sortedKeys(props) {
+		str, err := grootLiteralString(props[k])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid property %s,id=%s", k, id)
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: k}, Value: str})
+	}
+	for _, k := range sortedKeys(cfg.Remappings) {
+		if builtin != nil && builtin.hasProp(k) {
+			continue
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: k}, Value: "{" + cfg.Remappings[k] + "}"})
+	}
+	x := &xmlNode{XMLName: xml.Name{Local: tag}, Attrs: attrs}
+	for _, childID := range cfg.Children {
+		child, err := e.exportNode(childID)
+		if err != nil {
+			return nil, err
+		}
+		x.Nodes = append(x.Nodes, *child)
+	}
+	return x, nil
+}
+
+// remap 添加黑板键重映射
+//
+//	@receiver n
+//	@param port
+//	@param key
+func (n *NodeCfg) remap(port string, key string) {
+	if n.Remappings == nil {
+		n.Remappings = map[string]string{}
+	}
+	n.Remappings[port] = key
+}
+
+func (b *grootBuiltin) hasProp(prop string) bool {
+	for _, port := range b.ports {
+		if port.prop == prop {
+			return true
+		}
+	}
+	return false
+}
+
+func grootBuiltinByTag(tag string) *grootBuiltin {
+	for _, b := range grootBuiltins {
+		if b.tag == tag {
+			return b
+		}
+	}
+	return nil
+}
+
+func grootBuiltinByName(name string, props map[string]any) *grootBuiltin {
+	for _, b := range grootBuiltins {
+		if b.name != name {
+			continue
+		}
+		matched := true
+		for k, v := range b.fixed {
+			// 未配置的属性视为零值
+			pv, ok := props[k]
+			if !ok {
+				pv = false
+			}
+			if pv != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return b
+		}
+	}
+	return nil
+}
+
+// grootCategory 根据声明的节点种类推断节点类型,未声明时根据子节点数量推断
+//
+//	@param kind
+//	@param children
+//	@return string
+func grootCategory(kind string, children int) string {
+	switch kind {
+	case grootKindAction, grootKindCond:
+		return categoryTask
+	case grootKindDec:
+		return categoryDecorator
+	case grootKindCtrl:
+		return categoryComposite
+	}
+	switch children {
+	case 0:
+		return categoryTask
+	case 1:
+		return categoryDecorator
+	default:
+		return categoryComposite
+	}
+}
+
+func grootAttr(attrs []xml.Attr, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func grootWithoutAttr(attrs []xml.Attr, name string) []xml.Attr {
+	out := make([]xml.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Name.Local != name {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// grootRemappedKey 解析 {key} 形式的黑板键引用
+//
+//	@param val
+//	@return string
+//	@return bool
+func grootRemappedKey(val string) (string, bool) {
+	val = strings.TrimSpace(val)
+	if len(val) > 2 && strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") && !strings.ContainsAny(val[1:len(val)-1], "{}\":,") {
+		return val[1 : len(val)-1], true
+	}
+	return "", false
+}
+
+// grootLiteral 将XML端口字面值转换为JSON值:数字,布尔,JSON数组/对象,其余按字符串处理
+//
+//	@param val
+//	@return any
+func grootLiteral(val string) any {
+	if b, err := strconv.ParseBool(val); err == nil && (val == "true" || val == "false") {
+		return b
+	}
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return f
+	}
+	if strings.HasPrefix(val, "[") || strings.HasPrefix(val, "{") {
+		var v any
+		if json.Unmarshal([]byte(val), &v) == nil {
+			return v
+		}
+	}
+	return val
+}
+
+// grootLiteralString grootLiteral 的逆操作
+//
+//	@param val
+//	@return string
+//	@return error
+func grootLiteralString(val any) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(data), nil
+}
+
+// grootImpFinishMode 并行节点的成功/失败数量,只能表示1(任一)和-1(全部)
+func grootImpFinishMode(val string) (any, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch n {
+	case 1:
+		return finishModeOne, nil
+	case -1:
+		return finishModeAll, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported count %d,only 1 or -1(all) can be imported", n))
+}
+
+func grootExpFinishMode(val any) (string, error) {
+	mode, ok := val.(float64)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("invalid finish mode:%v", val))
+	}
+	if int(mode) == finishModeOne {
+		return "1", nil
+	}
+	return "-1", nil
+}
+
+// grootImpTimes 循环次数,BehaviorTree.CPP 中 -1 表示永远循环
+func grootImpTimes(val string) (any, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, nil
+}
+
+func grootExpTimes(val any) (string, error) {
+	n, ok := val.(float64)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("invalid times:%v", val))
+	}
+	if n <= 0 {
+		return "-1", nil
+	}
+	return strconv.Itoa(int(n)), nil
+}
+
+func grootImpMsec(val string) (any, error) {
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return (time.Duration(n) * time.Millisecond).String(), nil
+}
+
+// grootExpMsec 属性值的格式同 util.Duration
+func grootExpMsec(val any) (string, error) {
+	var d util.Duration
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	err = d.UnmarshalJSON(data)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGrootXML_RoundTrip(t *testing.T) {
+	content := `
+<root BTCPP_format="4" main_tree_to_execute="main">
+  <BehaviorTree ID="main">
+    <Sequence name="patrol">
+      <Parallel success_count="1" failure_count="-1">
+        <MoveTo target="{goal}" speed="2.5"/>
+        <Action ID="Scan" radius="10"/>
+      </Parallel>
+      <Fallback>
+        <Timeout msec="500">
+          <Condition ID="IsEnemyNear"/>
+        </Timeout>
+        <ForceSuccess>
+          <Sleep msec="{idle}"/>
+        </ForceSuccess>
+      </Fallback>
+      <RetryUntilSuccessful num_attempts="3">
+        <Attack/>
+      </RetryUntilSuccessful>
+      <Repeat num_cycles="-1">
+        <CheckKeys keys='["a","b"]'>
+          <SubTree ID="sub" _autoremap="true" enemy="{target}"/>
+        </CheckKeys>
+      </Repeat>
+    </Sequence>
+  </BehaviorTree>
+  <BehaviorTree ID="sub">
+    <Inverter>
+      <ForceFailure>
+        <Attack/>
+      </ForceFailure>
+    </Inverter>
+  </BehaviorTree>
+  <TreeNodesModel>
+    <Action ID="MoveTo"/>
+    <Condition ID="IsEnemyNear"/>
+    <Action ID="Attack"/>
+    <Decorator ID="CheckKeys"/>
+  </TreeNodesModel>
+</root>`
+	opts := []GrootOption{WithGrootTarget("npc"), WithGrootClass("CheckKeys", "BBEntries")}
+	cfgs, err := ParseGrootXML([]byte(content), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 2 || cfgs[0].Tag != "main" || cfgs[1].Tag != "sub" {
+		t.Fatalf("unexpected trees %+v", cfgs)
+	}
+	want := dumpGrootTree(t, cfgs[0], cfgs[0].Root)
+	expected := `Root{"once":true}[Sequence:patrol{}[Parallel{"failurePolicy":1,"successPolicy":0}[Action:MoveTo{"speed":2.5}(npc.MoveTo)<target=goal>,Action:Scan{"radius":10}(npc.Scan)],Selector:Fallback{}[TimeMax:Timeout{"limit":"500ms"}[Action:IsEnemyNear{}(npc.IsEnemyNear)],Succeeded:ForceSuccess{}[Wait:Sleep{}<waitTime=idle>]],Repeater:RetryUntilSuccessful{"times":3,"untilSuccess":true}[Action:Attack{}(npc.Attack)],Repeater:Repeat{"times":0,"untilSuccess":false}[BBEntries:CheckKeys{"keys":["a","b"]}[Subtree:sub{"childTag":"sub"}<enemy=target>]]]]`
+	if want != expected {
+		t.Fatalf("imported tree\n got %s\nwant %s", want, expected)
+	}
+	data, err := ExportGrootXML(cfgs, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseGrootXML(data, opts...)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	for i := range cfgs {
+		if got := dumpGrootTree(t, again[i], again[i].Root); got != dumpGrootTree(t, cfgs[i], cfgs[i].Root) {
+			t.Fatalf("round trip mismatch\n got %s\nwant %s\n%s", got, dumpGrootTree(t, cfgs[i], cfgs[i].Root), data)
+		}
+	}
+}
+
+func TestGrootXML_ParallelCounts(t *testing.T) {
+	parallel := func(attrs string) string {
+		return `<root BTCPP_format="4"><BehaviorTree ID="main"><Parallel` + attrs + `><Attack/><Attack/></Parallel></BehaviorTree></root>`
+	}
+	// 缺省时同 BehaviorTree.CPP:全部成功才成功,任一失败即失败
+	cfgs, err := ParseGrootXML([]byte(parallel("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := cfgs[0].Nodes[cfgs[0].Root]
+	if got := dumpGrootTree(t, cfgs[0], root.Children[0]); got != `Parallel{"failurePolicy":0,"successPolicy":1}[Action:Attack{}(.Attack),Action:Attack{}(.Attack)]` {
+		t.Fatalf("imported %s", got)
+	}
+	if _, err = ParseGrootXML([]byte(parallel(` success_count="2"`))); err == nil {
+		t.Fatal("unsupported success_count imported")
+	}
+	// 未配置策略的节点按零值导出
+	cfgs[0].Nodes[root.Children[0]].Properties = json.RawMessage(`{}`)
+	data, err := ExportGrootXML(cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseGrootXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = again[0].Nodes[again[0].Root]
+	if got := dumpGrootTree(t, again[0], root.Children[0]); got != `Parallel{"failurePolicy":0,"successPolicy":0}[Action:Attack{}(.Attack),Action:Attack{}(.Attack)]` {
+		t.Fatalf("exported %s\n%s", got, data)
+	}
+}
+
+// dumpGrootTree 将树转换为与节点ID无关的字符串
+func dumpGrootTree(t *testing.T, cfg *TreeCfg, id string) string {
+	n := cfg.Nodes[id]
+	s := n.Name
+	if n.Title != n.Name {
+		s += ":" + n.Title
+	}
+	var props map[string]any
+	if err := json.Unmarshal(n.Properties, &props); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(props)
+	s += string(data)
+	if n.Delegator.Method != "" {
+		s += "(" + n.Delegator.Target + "." + n.Delegator.Method + ")"
+	}
+	for _, k := range sortedKeys(n.Remappings) {
+		s += "<" + k + "=" + n.Remappings[k] + ">"
+	}
+	if len(n.Children) > 0 {
+		s += "["
+		for i, child := range n.Children {
+			if i > 0 {
+				s += ","
+			}
+			s += dumpGrootTree(t, cfg, child)
+		}
+		s += "]"
+	}
+	return s
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// 树模板参数
+//
+//	TreeCfg.Params 声明参数及其默认值,节点属性,标题,委托和黑板键重映射中以 ${参数名} 引用参数.
+//	属性中字符串值恰好为 "${参数名}" 时替换为参数的原始类型(数字,布尔,对象等),否则按字符串插值替换.
+//	替换发生在实例化节点之前,故属性类型以替换后的值为准,如 "forever":"${forever}" 中 forever 须为布尔值
+
+// paramPattern 参数占位符 ${name}
+var paramPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.]*)\}`)
+
+// IsTemplate 是否为声明了参数的模板
+//
+//	@receiver c
+//	@return bool
+func (c *TreeCfg) IsTemplate() bool {
+	return len(c.Params) > 0
+}
+
+// ResolveParams 以声明的默认值为基础合并 params
+//
+//	@receiver c
+//	@param params 可为空,须是已声明的参数
+//	@return map[string]any 完整的参数集
+//	@return error 引用了未声明的参数
+func (c *TreeCfg) ResolveParams(params map[string]any) (map[string]any, error) {
+	resolved := make(map[string]any, len(c.Params))
+	for name, val := range c.Params {
+		resolved[name] = val
+	}
+	for name, val := range params {
+		if _, ok := c.Params[name]; !ok {
+			return nil, errors.New(fmt.Sprintf("param not declared,tag=%s,param=%s", c.Tag, name))
+		}
+		resolved[name] = val
+	}
+	return resolved, nil
+}
+
+// ParamsKey 参数集的哈希,用于缓存特化的树.键的顺序不影响结果
+//
+//	@param params
+//	@return string 参数集为空时返回空字符串
+func ParamsKey(params map[string]any) string {
+	if len(params) == 0 {
+		return ""
+	}
+	// encoding/json 按键排序输出map,结果稳定
+	data, err := json.Marshal(params)
+	if err != nil {
+		data = []byte(fmt.Sprint(params))
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Specialize 以参数替换占位符,返回特化后的配置,节点ID不变,不修改原配置
+//
+//	@receiver c
+//	@param params 完整的参数集,参看 TreeCfg.ResolveParams
+//	@return *TreeCfg 不再是模板(Params 为空)
+//	@return error 引用了未提供的参数,或属性不是合法的JSON
+func (c *TreeCfg) Specialize(params map[string]any) (*TreeCfg, error) {
+	dst := *c
+	dst.Params = nil
+	dst.Nodes = make(map[string]*NodeCfg, len(c.Nodes))
+	for id, node := range c.Nodes {
+		n := *node
+		var err error
+		if n.Title, err = interpolate(n.Title, params); err == nil {
+			n.Delegator, err = specializeDelegator(n.Delegator, params)
+		}
+		if err == nil {
+			n.Properties, err = specializeProperties(n.Properties, params)
+		}
+		if err == nil && len(n.Remappings) > 0 {
+			n.Remappings = make(map[string]string, len(node.Remappings))
+			for port, key := range node.Remappings {
+				if n.Remappings[port], err = interpolate(key, params); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "specialize node failed,tag=%s,id=%s,name=%s,title=%s", c.Tag, id, node.Name, node.Title)
+		}
+		dst.Nodes[id] = &n
+	}
+	return &dst, nil
+}
+
+// TemplateParams 收集配置中引用的参数名,用于检查
+//
+//	@receiver c
+//	@return []string 已排序去重
+func (c *TreeCfg) TemplateParams() []string {
+	set := map[string]struct{}{}
+	collect := func(s []byte) {
+		for _, m := range paramPattern.FindAllSubmatch(s, -1) {
+			set[string(m[1])] = struct{}{}
+		}
+	}
+	for _, node := range c.Nodes {
+		collect([]byte(node.Title))
+		collect([]byte(node.Delegator.Target))
+		collect([]byte(node.Delegator.Method))
+		collect([]byte(node.Delegator.Script))
+		collect(node.Properties)
+		for _, key := range node.Remappings {
+			collect([]byte(key))
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func specializeDelegator(d DelegatorCfg, params map[string]any) (DelegatorCfg, error) {
+	var err error
+	if d.Target, err = interpolate(d.Target, params); err != nil {
+		return d, err
+	}
+	if d.Method, err = interpolate(d.Method, params); err != nil {
+		return d, err
+	}
+	d.Script, err = interpolate(d.Script, params)
+	return d, err
+}
+
+// specializeProperties 替换属性JSON中字符串值(不包括键)里的占位符
+//
+//	@param raw
+//	@param params
+//	@return json.RawMessage
+//	@return error
+func specializeProperties(raw json.RawMessage, params map[string]any) (json.RawMessage, error) {
+	if !paramPattern.Match(raw) {
+		return raw, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, errors.WithMessage(err, "invalid properties")
+	}
+	v, err := substitute(v, params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func substitute(v any, params map[string]any) (any, error) {
+	switch val := v.(type) {
+	case string:
+		// 整个字符串就是占位符时保留参数的类型
+		if m := paramPattern.FindStringSubmatch(val); m != nil && m[0] == val {
+			p, ok := params[m[1]]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("param not declared,param=%s", m[1]))
+			}
+			return p, nil
+		}
+		return interpolate(val, params)
+	case map[string]any:
+		for k, item := range val {
+			s, err := substitute(item, params)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = s
+		}
+	case []any:
+		for i, item := range val {
+			s, err := substitute(item, params)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = s
+		}
+	}
+	return v, nil
+}
+
+// interpolate 按字符串插值替换占位符
+//
+//	@param s
+//	@param params
+//	@return string
+//	@return error 引用了未提供的参数
+func interpolate(s string, params map[string]any) (string, error) {
+	var err error
+	out := paramPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		p, ok := params[name]
+		if !ok {
+			if err == nil {
+				err = errors.New(fmt.Sprintf("param not declared,param=%s", name))
+			}
+			return m
+		}
+		if str, ok := p.(string); ok {
+			return str
+		}
+		return fmt.Sprint(p)
+	})
+	return out, err
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTreeCfg_Specialize(t *testing.T) {
+	cfg := &TreeCfg{Tag: "patrol", Root: "r", Params: map[string]any{"wait": "3s", "times": 2, "target": "npc"}, Nodes: map[string]*NodeCfg{
+		"r": {ID: "r", Name: "Root", Title: "patrol ${target}", Properties: json.RawMessage(`{"waitTime":"${wait}","times":"${times}","msg":"wait ${wait}","list":["${target}"]}`),
+			Delegator: DelegatorCfg{Target: "${target}", Method: "Patrol"}},
+	}}
+	resolved, err := cfg.ResolveParams(map[string]any{"times": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := cfg.Specialize(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.IsTemplate() || !cfg.IsTemplate() {
+		t.Fatal("specialized cfg should not be a template and template should be untouched")
+	}
+	node := spec.Nodes["r"]
+	var props map[string]any
+	if err := json.Unmarshal(node.Properties, &props); err != nil {
+		t.Fatal(err)
+	}
+	if props["waitTime"] != "3s" || props["times"] != float64(5) || props["msg"] != "wait 3s" || props["list"].([]any)[0] != "npc" {
+		t.Fatalf("properties = %s", node.Properties)
+	}
+	if node.Title != "patrol npc" || node.Delegator.Target != "npc" {
+		t.Fatalf("title = %s, target = %s", node.Title, node.Delegator.Target)
+	}
+	if ParamsKey(resolved) == ParamsKey(cfg.Params) {
+		t.Fatal("params key should differ from defaults")
+	}
+	if _, err := cfg.ResolveParams(map[string]any{"unknown": 1}); err == nil {
+		t.Fatal("undeclared param accepted")
+	}
+	if _, err := cfg.Specialize(map[string]any{"wait": "1s"}); err == nil {
+		t.Fatal("missing param accepted")
+	}
+}
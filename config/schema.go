@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KeyType 黑板键的值类型
+type KeyType string
+
+const (
+	KeyTypeAny      KeyType = "any"      // 任意类型,不检查
+	KeyTypeBool     KeyType = "bool"     // 布尔
+	KeyTypeInt      KeyType = "int"      // 整数,也接受没有小数部分的浮点数(JSON数字)
+	KeyTypeFloat    KeyType = "float"    // 任意数字
+	KeyTypeString   KeyType = "string"   // 字符串
+	KeyTypeDuration KeyType = "duration" // 时长, time.Duration ,数字(纳秒)或 time.ParseDuration 格式的字符串
+	KeyTypeArray    KeyType = "array"    // 切片或数组
+	KeyTypeObject   KeyType = "object"   // map或结构体
+)
+
+// BlackboardKeyCfg 黑板键声明
+type BlackboardKeyCfg struct {
+	Type        KeyType `json:"type"`                  // 值类型,为空等同 KeyTypeAny
+	Default     any     `json:"default,omitempty"`     // 默认值,运行树时黑板中没有该键则写入
+	Description string  `json:"description,omitempty"` // 说明
+}
+
+// Valid 类型须合法,默认值须符合类型
+//
+//	@receiver k
+//	@return error
+func (k *BlackboardKeyCfg) Valid() error {
+	switch k.Type {
+	case "", KeyTypeAny, KeyTypeBool, KeyTypeInt, KeyTypeFloat, KeyTypeString, KeyTypeDuration, KeyTypeArray, KeyTypeObject:
+	default:
+		return errors.New(fmt.Sprintf("unknown key type,type=%s", k.Type))
+	}
+	if k.Default == nil {
+		return nil
+	}
+	return errors.WithMessage(k.Type.Check(k.Default), "invalid default value")
+}
+
+// Check 检查值是否符合类型,nil总是合法
+//
+//	@receiver t
+//	@param val
+//	@return error
+func (t KeyType) Check(val any) error {
+	if val == nil || t == "" || t == KeyTypeAny {
+		return nil
+	}
+	v := reflect.ValueOf(val)
+	ok := false
+	switch t {
+	case KeyTypeBool:
+		ok = v.Kind() == reflect.Bool
+	case KeyTypeInt:
+		ok = isIntKind(v.Kind()) || isFloatKind(v.Kind()) && v.Float() == math.Trunc(v.Float())
+		if n, isNum := val.(interface{ Int64() (int64, error) }); isNum {
+			_, err := n.Int64()
+			ok = err == nil
+		}
+	case KeyTypeFloat:
+		ok = isIntKind(v.Kind()) || isFloatKind(v.Kind())
+		if n, isNum := val.(interface{ Float64() (float64, error) }); isNum {
+			_, err := n.Float64()
+			ok = err == nil
+		}
+	case KeyTypeString:
+		ok = v.Kind() == reflect.String
+	case KeyTypeDuration:
+		switch d := val.(type) {
+		case time.Duration:
+			ok = true
+		case string:
+			_, err := time.ParseDuration(d)
+			ok = err == nil
+		default:
+			ok = isIntKind(v.Kind()) || isFloatKind(v.Kind())
+		}
+	case KeyTypeArray:
+		ok = v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+	case KeyTypeObject:
+		ok = v.Kind() == reflect.Map || v.Kind() == reflect.Struct ||
+			v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct
+	default:
+		return errors.New(fmt.Sprintf("unknown key type,type=%s", t))
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("value %v(%T) is not %s", val, val, t))
+	}
+	return nil
+}
+
+// Accepts 声明为该类型的键能否存放 other 类型的值,用于静态检查节点对键的类型要求.任一方为 KeyTypeAny 时总是兼容
+//
+//	@receiver t
+//	@param other
+//	@return bool
+func (t KeyType) Accepts(other KeyType) bool {
+	if t == "" || t == KeyTypeAny || other == "" || other == KeyTypeAny || t == other {
+		return true
+	}
+	// 数字之间可以比较和转换
+	isNumber := func(k KeyType) bool { return k == KeyTypeInt || k == KeyTypeFloat || k == KeyTypeDuration }
+	return isNumber(t) && isNumber(other)
+}
+
+// KeyTypeOf 推断值的类型,整数推断为 KeyTypeInt ,其他数字为 KeyTypeFloat ,nil或无法推断时为 KeyTypeAny
+//
+//	@param val
+//	@return KeyType
+func KeyTypeOf(val any) KeyType {
+	if val == nil {
+		return KeyTypeAny
+	}
+	if _, ok := val.(time.Duration); ok {
+		return KeyTypeDuration
+	}
+	for _, t := range []KeyType{KeyTypeBool, KeyTypeInt, KeyTypeFloat, KeyTypeString, KeyTypeArray, KeyTypeObject} {
+		if t.Check(val) == nil {
+			return t
+		}
+	}
+	return KeyTypeAny
+}
+
+// BlackboardKeys 已排序的声明的键名
+//
+//	@receiver c
+//	@return []string
+func (c *TreeCfg) BlackboardKeys() []string {
+	keys := make([]string, 0, len(c.Blackboard))
+	for key := range c.Blackboard {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Uintptr
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
@@ -0,0 +1,508 @@
+// Package debug 行为树实时调试服务
+//
+//	按需挂载到进程的 http 服务上,可以列出被调试的 IBrain,查看树的当前状态,
+//	并通过 WebSocket 实时推送节点的 Start/Abort/Finish/Update/ChildFinished 事件和黑板变化.
+//
+//	路由:
+//	 GET /brains                  被调试的 IBrain 列表
+//	 GET /brains/{id}/tree        正在运行的树及各节点状态
+//	 GET /ws[?brain={id}]         WebSocket 事件流,指定brain时只推送该 IBrain 的事件
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/alkaid/behavior"
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/logger"
+)
+
+// DefaultTimeout 派发到 IBrain 线程读取数据的默认超时时间
+const DefaultTimeout = time.Second
+
+// DefaultClientBuffer 每个 WebSocket 客户端的默认事件缓冲数量,缓冲满时丢弃新事件
+const DefaultClientBuffer = 256
+
+// 事件类型
+const (
+	EventStart         = "start"
+	EventAbort         = "abort"
+	EventFinish        = "finish"
+	EventUpdate        = "update"
+	EventChildFinished = "childFinished"
+	EventBlackboard    = "blackboard"
+)
+
+var eventNames = map[bcore.TraceEventType]string{
+	bcore.TraceStart:         EventStart,
+	bcore.TraceAbort:         EventAbort,
+	bcore.TraceFinish:        EventFinish,
+	bcore.TraceUpdate:        EventUpdate,
+	bcore.TraceChildFinished: EventChildFinished,
+}
+
+// BrainInfo IBrain 概要
+type BrainInfo struct {
+	ID      int    `json:"id"`
+	Running bool   `json:"running"`
+	Tag     string `json:"tag,omitempty"`   // 正在运行的主树tag
+	Error   string `json:"error,omitempty"` // 读取失败的原因,如超时
+}
+
+// NodeInfo 节点及其当前状态
+type NodeInfo struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Title    string          `json:"title"`
+	Category string          `json:"category"`
+	State    bcore.NodeState `json:"state"`
+	Children []*NodeInfo     `json:"children,omitempty"`
+}
+
+// TreeInfo 正在运行的树
+type TreeInfo struct {
+	BrainID int       `json:"brainID"`
+	Tag     string    `json:"tag"`
+	Root    *NodeInfo `json:"root"`
+}
+
+// Event 推送给 WebSocket 客户端的事件
+type Event struct {
+	Type       string          `json:"type"` // EventStart 等
+	BrainID    int             `json:"brainID"`
+	Time       time.Time       `json:"time"` // IBrain.Now
+	NodeID     string          `json:"nodeID,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Title      string          `json:"title,omitempty"`
+	PrevState  bcore.NodeState `json:"prevState"`
+	State      bcore.NodeState `json:"state"`
+	Result     bcore.Result    `json:"result"`               // 同 bcore.TraceEvent .Result
+	ChildID    string          `json:"childID,omitempty"`    // 仅 EventChildFinished 有效
+	UpstreamID string          `json:"upstreamID,omitempty"` // 引起本次事件的上游节点
+	Op         bcore.OpType    `json:"op,omitempty"`         // 以下仅 EventBlackboard 有效
+	Key        string          `json:"key,omitempty"`
+	OldValue   any             `json:"oldValue,omitempty"`
+	NewValue   any             `json:"newValue,omitempty"`
+}
+
+var _ bcore.Tracer = (*Server)(nil)
+var _ bcore.BlackboardTracer = (*Server)(nil)
+var _ http.Handler = (*Server)(nil)
+
+// Server 调试服务
+//
+//	@implement bcore.Tracer
+//	@implement bcore.BlackboardTracer
+//	@implement http.Handler
+//	只有 Attach 过的 IBrain 才会被列出和推送事件,须调用 Install 后才会收到事件
+type Server struct {
+	mu      sync.RWMutex
+	brains  map[int]bcore.IBrain
+	clients map[*client]struct{}
+	mux     *http.ServeMux
+	Timeout time.Duration // 派发到 IBrain 线程读取数据的超时时间
+	Buffer  int           // 每个 WebSocket 客户端的事件缓冲数量
+}
+
+// client WebSocket 客户端
+type client struct {
+	conn    *wsConn
+	brainID int // 为0表示订阅所有 IBrain
+	send    chan []byte
+	once    sync.Once
+}
+
+// NewServer 实例化调试服务
+//
+//	@return *Server
+func NewServer() *Server {
+	s := &Server{
+		brains:  map[int]bcore.IBrain{},
+		clients: map[*client]struct{}{},
+		mux:     http.NewServeMux(),
+		Timeout: DefaultTimeout,
+		Buffer:  DefaultClientBuffer,
+	}
+	s.mux.HandleFunc("GET /brains", s.handleBrains)
+	s.mux.HandleFunc("GET /brains/{id}/tree", s.handleTree)
+	s.mux.HandleFunc("GET /ws", s.handleWS)
+	return s
+}
+
+// Install 设置为全局追踪器,开始接收事件.若已有其他全局追踪器,可以不调用本方法而由其转发 OnTrace 和 OnBlackboardChanged
+//
+//	@receiver s
+func (s *Server) Install() {
+	bcore.SetTracer(s)
+}
+
+// Uninstall 取消全局追踪器并断开所有 WebSocket 客户端
+//
+//	@receiver s
+func (s *Server) Uninstall() {
+	if bcore.GlobalTracer() == s {
+		bcore.SetTracer(nil)
+	}
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = map[*client]struct{}{}
+	s.mu.Unlock()
+	for c := range clients {
+		c.close()
+	}
+}
+
+// Attach 添加要调试的 IBrain
+//
+//	@receiver s
+//	@param brain
+func (s *Server) Attach(brain bcore.IBrain) {
+	s.mu.Lock()
+	s.brains[brain.ID()] = brain
+	s.mu.Unlock()
+}
+
+// Detach 移除调试的 IBrain
+//
+//	@receiver s
+//	@param brain
+func (s *Server) Detach(brain bcore.IBrain) {
+	s.mu.Lock()
+	delete(s.brains, brain.ID())
+	s.mu.Unlock()
+}
+
+func (s *Server) brain(id int) bcore.IBrain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.brains[id]
+}
+
+// ServeHTTP
+//
+//	@implement http.Handler .ServeHTTP
+//	@receiver s
+//	@param w
+//	@param r
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// OnTrace
+//
+//	@implement bcore.Tracer .OnTrace
+//	@receiver s
+//	@param brain
+//	@param event
+func (s *Server) OnTrace(brain bcore.IBrain, event *bcore.TraceEvent) {
+	if s.brain(brain.ID()) == nil {
+		return
+	}
+	e := &Event{
+		Type:      eventNames[event.Type],
+		BrainID:   event.BrainID,
+		Time:      brain.Now(),
+		NodeID:    event.NodeID,
+		Name:      event.NodeName,
+		Title:     event.NodeTitle,
+		PrevState: event.PrevState,
+		State:     event.State,
+		Result:    event.Result,
+	}
+	if event.Child != nil {
+		e.ChildID = event.Child.ID()
+	}
+	if event.Upstream != nil {
+		e.UpstreamID = event.Upstream.ID()
+	}
+	s.broadcast(e)
+}
+
+// OnBlackboardChanged
+//
+//	@implement bcore.BlackboardTracer .OnBlackboardChanged
+//	@receiver s
+//	@param threadID
+//	@param op
+//	@param key
+//	@param oldValue
+//	@param newValue
+func (s *Server) OnBlackboardChanged(threadID int, op bcore.OpType, key string, oldValue any, newValue any) {
+	brain := s.brain(threadID)
+	if brain == nil {
+		return
+	}
+	s.broadcast(&Event{
+		Type:     EventBlackboard,
+		BrainID:  threadID,
+		Time:     brain.Now(),
+		Op:       op,
+		Key:      key,
+		OldValue: jsonValue(oldValue),
+		NewValue: jsonValue(newValue),
+	})
+}
+
+// broadcast 推送事件,非阻塞,客户端缓冲满时丢弃
+//
+//	@receiver s
+//	@param e
+func (s *Server) broadcast(e *Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.clients) == 0 {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		logger.Log.Error("marshal debug event error", zap.Error(err))
+		return
+	}
+	for c := range s.clients {
+		if c.brainID != 0 && c.brainID != e.BrainID {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			logger.Log.Warn("debug client buffer full,drop event", zap.String("type", e.Type), zap.Int("brain", e.BrainID))
+		}
+	}
+}
+
+// jsonValue 无法序列化为json的值转换为字符串
+//
+//	@param v
+//	@return any
+func jsonValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return v
+}
+
+// call 派发到 IBrain 的线程执行并等待完成
+//
+//	@receiver s
+//	@param ctx
+//	@param brain
+//	@param task
+//	@return error 超时或请求取消
+func (s *Server) call(ctx context.Context, brain bcore.IBrain, task func()) error {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	done := make(chan struct{})
+	brain.Go(func() {
+		// 已超时的不再执行,避免读取结果时的竞态
+		if ctx.Err() != nil {
+			return
+		}
+		task()
+		close(done)
+	})
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+func (s *Server) handleBrains(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	brains := make([]bcore.IBrain, 0, len(s.brains))
+	for _, brain := range s.brains {
+		brains = append(brains, brain)
+	}
+	s.mu.RUnlock()
+	sort.Slice(brains, func(i, j int) bool { return brains[i].ID() < brains[j].ID() })
+	infos := make([]*BrainInfo, 0, len(brains))
+	for _, brain := range brains {
+		info := &BrainInfo{ID: brain.ID()}
+		var running bool
+		var tag string
+		err := s.call(r.Context(), brain, func() {
+			running = brain.Running()
+			if running {
+				tag = treeTag(brain, brain.RunningTree())
+			}
+		})
+		if err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Running = running
+			info.Tag = tag
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, infos)
+}
+
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid brain id", http.StatusBadRequest)
+		return
+	}
+	brain := s.brain(id)
+	if brain == nil {
+		http.Error(w, "brain not attached", http.StatusNotFound)
+		return
+	}
+	var tree *TreeInfo
+	err = s.call(r.Context(), brain, func() {
+		if !brain.Running() {
+			return
+		}
+		root := brain.RunningTree()
+		tree = &TreeInfo{
+			BrainID: id,
+			Tag:     treeTag(brain, root),
+			Root:    nodeInfo(brain, root),
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	if tree == nil {
+		http.Error(w, "brain not running", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, tree)
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	brainID := 0
+	if v := r.URL.Query().Get("brain"); v != "" {
+		var err error
+		brainID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid brain id", http.StatusBadRequest)
+			return
+		}
+	}
+	conn, err := upgrade(w, r)
+	if err != nil {
+		logger.Log.Debug("debug websocket upgrade failed", zap.Error(err))
+		return
+	}
+	c := &client{conn: conn, brainID: brainID, send: make(chan []byte, s.Buffer)}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	go s.writeLoop(c)
+	s.readLoop(c)
+}
+
+// readLoop 处理客户端的控制帧,连接断开或收到关闭帧时移除客户端
+//
+//	@receiver s
+//	@param c
+func (s *Server) readLoop(c *client) {
+	defer s.removeClient(c)
+	for {
+		op, payload, err := c.conn.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case opClose:
+			_ = c.conn.writeFrame(opClose, payload)
+			return
+		case opPing:
+			if err = c.conn.writeFrame(opPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) writeLoop(c *client) {
+	defer s.removeClient(c)
+	for data := range c.send {
+		if err := c.conn.writeFrame(opText, data); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) removeClient(c *client) {
+	s.mu.Lock()
+	_, ok := s.clients[c]
+	delete(s.clients, c)
+	s.mu.Unlock()
+	if ok {
+		c.close()
+	}
+}
+
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.send)
+		_ = c.conn.Close()
+	})
+}
+
+// treeTag 根据root获取树的tag
+//
+//	@param brain
+//	@param root
+//	@return string
+func treeTag(brain bcore.IBrain, root bcore.IRoot) string {
+	registry := behavior.GlobalTreeRegistry()
+	if b, ok := brain.(*behavior.Brain); ok {
+		registry = b.Runtime().TreeRegistry()
+	}
+	tree := registry.TreeByID(root.ID())
+	if tree == nil {
+		return ""
+	}
+	return tree.Tag
+}
+
+// nodeInfo 递归收集节点状态,包括动态挂载的子树.须在 IBrain 的线程内调用
+//
+//	@param brain
+//	@param node
+//	@return *NodeInfo
+func nodeInfo(brain bcore.IBrain, node bcore.INode) *NodeInfo {
+	info := &NodeInfo{
+		ID:       node.ID(),
+		Name:     node.Name(),
+		Title:    node.Title(),
+		Category: node.Category(),
+		State:    node.State(brain),
+	}
+	switch v := node.(type) {
+	case bcore.IComposite:
+		for _, child := range v.Children() {
+			info.Children = append(info.Children, nodeInfo(brain, child))
+		}
+	case bcore.IDecorator:
+		if child := v.Decorated(brain); child != nil {
+			info.Children = append(info.Children, nodeInfo(brain, child))
+		}
+	}
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Error("write debug response error", zap.Error(err))
+	}
+}
@@ -0,0 +1,134 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+
+	"github.com/alkaid/behavior"
+	"github.com/alkaid/behavior/bcore"
+)
+
+func dialWS(t *testing.T, url string) *wsConn {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, url+"/ws?brain=2001", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	resp, err := http.ReadResponse(rw.Reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed: %v %v", resp.Status, resp.Header)
+	}
+	return &wsConn{conn: conn, rw: rw}
+}
+
+func readEvent(t *testing.T, c *wsConn) *Event {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	op, payload, err := c.readFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != opText {
+		t.Fatalf("op = %d, want text", op)
+	}
+	e := &Event{}
+	if err = json.Unmarshal(payload, e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestServer(t *testing.T) {
+	p, err := ants.NewPoolWithID(ants.DefaultAntsPoolSize, ants.WithExpiryDuration(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	behavior.InitSystem(behavior.WithThreadPool(p), behavior.WithActionSuccessIfNotDelegate())
+	content := `
+{"root":"dbg-root","tag":"test_debug","nodes":{
+"dbg-root":{"id":"dbg-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["dbg-seq"]},
+"dbg-seq":{"id":"dbg-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["dbg-wait","dbg-action"]},
+"dbg-wait":{"id":"dbg-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":"300ms"}},
+"dbg-action":{"id":"dbg-action","name":"Action","category":"task","title":"Action","properties":{}}
+}}`
+	if err = behavior.GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	s.Install()
+	defer s.Uninstall()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := behavior.NewBrain(bcore.NewBlackboard(2001, nil), nil, fch)
+	s.Attach(brain)
+
+	resp, err := http.Get(ts.URL + "/brains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var brains []*BrainInfo
+	_ = json.NewDecoder(resp.Body).Decode(&brains)
+	_ = resp.Body.Close()
+	if len(brains) != 1 || brains[0].ID != 2001 || brains[0].Running {
+		t.Fatalf("brains = %+v", brains)
+	}
+
+	ws := dialWS(t, ts.URL)
+	defer ws.Close()
+	if err = brain.Run("test_debug", false); err != nil {
+		t.Fatal(err)
+	}
+	e := readEvent(t, ws)
+	if e.Type != EventStart || e.NodeID != "dbg-root" || e.State != bcore.NodeStateActive {
+		t.Fatalf("first event = %+v, want root start", e)
+	}
+	brain.Blackboard().Set("hp", 1)
+
+	resp, err = http.Get(ts.URL + "/brains/2001/tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := &TreeInfo{}
+	_ = json.NewDecoder(resp.Body).Decode(tree)
+	_ = resp.Body.Close()
+	if tree.Tag != "test_debug" || tree.Root == nil || tree.Root.State != bcore.NodeStateActive ||
+		len(tree.Root.Children) != 1 || len(tree.Root.Children[0].Children) != 2 {
+		t.Fatalf("tree = %+v", tree)
+	}
+
+	seen := map[string]bool{}
+	for {
+		e = readEvent(t, ws)
+		seen[e.Type] = true
+		if e.Type == EventBlackboard && (e.Key != "hp" || e.Op != bcore.OpAdd || e.NewValue != float64(1)) {
+			t.Fatalf("blackboard event = %+v", e)
+		}
+		if e.Type == EventFinish && e.NodeID == "dbg-root" {
+			break
+		}
+	}
+	if !seen[EventBlackboard] || !seen[EventChildFinished] {
+		t.Fatalf("seen = %v", seen)
+	}
+	<-fch
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
 	"github.com/samber/lo"
 
 	"github.com/alkaid/behavior/util"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 
 	"go.uber.org/zap"
 )
@@ -27,6 +29,36 @@ type BBConditionProperties struct {
 	Value    any            `json:"value"`
 }
 
+// Valid
+//
+//	@implement bcore.IPropertiesValidator .Valid
+//	@receiver b
+//	@return error
+func (b *BBConditionProperties) Valid() error {
+	if b.Operator < bcore.OperatorIsSet || b.Operator > bcore.OperatorIsLte {
+		return errors.New(fmt.Sprintf("operator out of range,operator=%d", b.Operator))
+	}
+	if b.Key == "" {
+		return errors.New("key cannot be nil")
+	}
+	return b.ObservingProperties.Valid()
+}
+
+// BlackboardKeys 比较大小时要求数字,判等时要求与配置值同类型
+//
+//	@implement bcore.IBlackboardKeysProvider .BlackboardKeys
+//	@receiver b
+//	@return map[string]config.KeyType
+func (b *BBConditionProperties) BlackboardKeys() map[string]config.KeyType {
+	switch b.Operator {
+	case bcore.OperatorIsEqual, bcore.OperatorIsNotEqual:
+		return map[string]config.KeyType{b.Key: config.KeyTypeOf(b.Value)}
+	case bcore.OperatorIsGt, bcore.OperatorIsGte, bcore.OperatorIsLt, bcore.OperatorIsLte:
+		return map[string]config.KeyType{b.Key: config.KeyTypeFloat}
+	}
+	return map[string]config.KeyType{b.Key: config.KeyTypeAny}
+}
+
 func (b *BBConditionProperties) GetOperator() bcore.Operator {
 	return b.Operator
 }
@@ -59,6 +91,10 @@ func (c *BBCondition) BBConditionProperties() IBBConditionProperties {
 	return c.Properties().(IBBConditionProperties)
 }
 
+func (c *BBCondition) BoundBBConditionProperties(brain bcore.IBrain) IBBConditionProperties {
+	return c.BoundProperties(brain).(IBBConditionProperties)
+}
+
 // StartObserving
 //
 //	@override bcore.ObservingDecorator .StartObserving
@@ -66,7 +102,8 @@ func (c *BBCondition) BBConditionProperties() IBBConditionProperties {
 //	@param brain
 func (c *BBCondition) StartObserving(brain bcore.IBrain) {
 	c.ObservingDecorator.StartObserving(brain)
-	brain.Blackboard().(bcore.IBlackboardInternal).AddObserver(c.BBConditionProperties().GetKey(), c.getObserver(brain))
+	// 开始和停止监听须使用相同的键,故不使用绑定黑板的属性
+	c.Blackboard(brain).AddObserver(c.Properties().(IBBConditionProperties).GetKey(), c.getObserver(brain))
 }
 
 // StopObserving
@@ -76,7 +113,7 @@ func (c *BBCondition) StartObserving(brain bcore.IBrain) {
 //	@param brain
 func (c *BBCondition) StopObserving(brain bcore.IBrain) {
 	c.ObservingDecorator.StopObserving(brain)
-	brain.Blackboard().(bcore.IBlackboardInternal).RemoveObserver(c.BBConditionProperties().GetKey(), c.getObserver(brain))
+	c.Blackboard(brain).RemoveObserver(c.Properties().(IBBConditionProperties).GetKey(), c.getObserver(brain))
 	c.Memory(brain).DefaultObserver = nil
 }
 
@@ -95,13 +132,13 @@ func (c *BBCondition) ConditionMet(brain bcore.IBrain, args ...any) bool {
 		ret := c.Update(brain, bcore.EventTypeOnUpdate, 0)
 		return ret == bcore.ResultSucceeded
 	}
-	v, ok := brain.Blackboard().Get(c.BBConditionProperties().GetKey())
-	propValue := c.BBConditionProperties().GetValue()
+	v, ok := c.Blackboard(brain).Get(c.BoundBBConditionProperties(brain).GetKey())
+	propValue := c.BoundBBConditionProperties(brain).GetValue()
 	if propValue == nil {
 		propValue = ""
 	}
-	isEqualOp := c.BBConditionProperties().GetOperator() == bcore.OperatorIsEqual
-	switch c.BBConditionProperties().GetOperator() {
+	isEqualOp := c.BoundBBConditionProperties(brain).GetOperator() == bcore.OperatorIsEqual
+	switch c.BoundBBConditionProperties(brain).GetOperator() {
 	case bcore.OperatorIsSet:
 		return ok
 	case bcore.OperatorIsNotSet:
@@ -135,15 +172,15 @@ func (c *BBCondition) ConditionMet(brain bcore.IBrain, args ...any) bool {
 		propNumber, propOk = util.Float(propValue)
 	}
 	// 以下类型判断能否成功转成number,不能转则无法比较
-	switch c.BBConditionProperties().GetOperator() {
+	switch c.BoundBBConditionProperties(brain).GetOperator() {
 	case bcore.OperatorIsGt, bcore.OperatorIsGte, bcore.OperatorIsLt, bcore.OperatorIsLte:
 		if bbOk && propOk {
 			break
 		}
-		c.Log(brain).Error("value cannot compare", zap.Int("operate", int(c.BBConditionProperties().GetOperator())), zap.Any("blackboardValue", v), zap.Any("configValue", propValue))
+		c.Log(brain).Error("value cannot compare", zap.Int("operate", int(c.BoundBBConditionProperties(brain).GetOperator())), zap.Any("blackboardValue", v), zap.Any("configValue", propValue))
 		return false
 	}
-	switch c.BBConditionProperties().GetOperator() {
+	switch c.BoundBBConditionProperties(brain).GetOperator() {
 	case bcore.OperatorIsEqual:
 		if bbOk && propOk {
 			return bbNumber == propNumber
@@ -163,12 +200,12 @@ func (c *BBCondition) ConditionMet(brain bcore.IBrain, args ...any) bool {
 	case bcore.OperatorIsLt:
 		return bbNumber < propNumber
 	}
-	c.Log(brain).Error("not support operator", zap.Int("operator", int(c.BBConditionProperties().GetOperator())))
+	c.Log(brain).Error("not support operator", zap.Int("operator", int(c.BoundBBConditionProperties(brain).GetOperator())))
 	return false
 }
 
 func (c *BBCondition) OnString(brain bcore.IBrain) string {
-	return fmt.Sprintf("%s(%d)%s?%v", c.ObservingDecorator.OnString(brain), c.BBConditionProperties().GetOperator(), c.BBConditionProperties().GetKey(), c.BBConditionProperties().GetValue())
+	return fmt.Sprintf("%s(%d)%s?%v", c.ObservingDecorator.OnString(brain), c.BoundBBConditionProperties(brain).GetOperator(), c.BoundBBConditionProperties(brain).GetKey(), c.BoundBBConditionProperties(brain).GetValue())
 }
 
 func (c *BBCondition) getObserver(brain bcore.IBrain) bcore.Observer {
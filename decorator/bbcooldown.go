@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
 )
 
 type IBBCooldownProperties interface {
@@ -14,16 +15,27 @@ type IBBCooldownProperties interface {
 
 // BBCooldownProperties cd等待装饰器属性
 type BBCooldownProperties struct {
+	CooldownBaseProperties
 	Key string `json:"key"` // 读取冷取时间的黑板KEY
 }
 
+// BlackboardKeys
+//
+//	@implement bcore.IBlackboardKeysProvider .BlackboardKeys
+//	@receiver p
+//	@return map[string]config.KeyType
+func (p *BBCooldownProperties) BlackboardKeys() map[string]config.KeyType {
+	return map[string]config.KeyType{p.Key: config.KeyTypeDuration}
+}
+
 func (p *BBCooldownProperties) GetKey() string {
 	return p.Key
 }
 
 // BBCooldown cd等待装饰器,与 Cooldown 的区别是冷取时间从黑板读取
 //
-//	每次子节点完成后将等待一段时间才能再次执行
+//	每次子节点完成后将等待一段时间才能再次执行.
+//	也可以使用 Cooldown 并将 cooldownTime 绑定黑板键,区别是绑定的值在节点启动时读取,参看 bcore.BindingKey
 type BBCooldown struct {
 	CooldownBase
 }
@@ -45,7 +57,7 @@ func (b *BBCooldown) PropertiesClassProvider() any {
 //	@return time.Duration
 func (b *BBCooldown) CooldownTime(brain bcore.IBrain) time.Duration {
 	key := b.Properties().(IBBCooldownProperties).GetKey()
-	val, ok := brain.Blackboard().GetDuration(key)
+	val, ok := b.Blackboard(brain).GetDuration(key)
 	if !ok {
 		b.Log(brain).Error("not found cooldown blackboard key", zap.String("key", key))
 		return 0
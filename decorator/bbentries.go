@@ -1,11 +1,16 @@
 package decorator
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.uber.org/zap"
 
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/script"
 )
 
 // BBEntriesOp 比较黑板条目操作符
@@ -20,6 +25,9 @@ type IBBEntriesProperties interface {
 	GetOperator() BBEntriesOp
 	GetKeys() []string
 	GetQuery() string
+	// GetQueryExpression 编译后的 GetQuery,为空表示没有查询语句
+	//  @return *script.Expression
+	GetQueryExpression() *script.Expression
 }
 
 // BBEntriesProperties 黑板条件节点属性
@@ -27,7 +35,61 @@ type BBEntriesProperties struct {
 	bcore.ObservingProperties
 	Operator BBEntriesOp `json:"operator"` // 运算符
 	Keys     []string    `json:"keys"`     // 黑板键
-	Query    string      `json:"query"`    // 自定义查询语句
+	// Query 自定义查询语句,以黑板键为变量的布尔表达式,如 `hp < maxHp * 0.3 && target != ""`,语法参看 script 包.
+	// 未设置的键值为nil(可用 key == nil 判断).不为空时将忽略 Operator,引用的黑板键会和 Keys 一起被监听
+	Query           string             `json:"query"`
+	queryExpression *script.Expression // 加载时编译的 Query
+}
+
+// UnmarshalJSON 解析属性并编译 Query
+//
+//	@receiver b
+//	@param data
+//	@return error
+func (b *BBEntriesProperties) UnmarshalJSON(data []byte) error {
+	type raw BBEntriesProperties
+	err := json.Unmarshal(data, (*raw)(b))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	b.queryExpression = nil
+	if b.Query != "" {
+		b.queryExpression, err = script.CompileExpression(b.Query)
+		if err != nil {
+			return errors.WithMessagef(err, "compile BBEntries query failed,query=%s", b.Query)
+		}
+	}
+	return nil
+}
+
+// Valid
+//
+//	@implement bcore.IPropertiesValidator .Valid
+//	@receiver b
+//	@return error
+func (b *BBEntriesProperties) Valid() error {
+	if b.Query == "" && (b.Operator < BBEntriesOpEqual || b.Operator > BBEntriesOpNotEqual) {
+		return errors.New(fmt.Sprintf("operator out of range,operator=%d", b.Operator))
+	}
+	return b.ObservingProperties.Valid()
+}
+
+// BlackboardKeys Keys 和查询语句引用的键
+//
+//	@implement bcore.IBlackboardKeysProvider .BlackboardKeys
+//	@receiver b
+//	@return map[string]config.KeyType
+func (b *BBEntriesProperties) BlackboardKeys() map[string]config.KeyType {
+	keys := map[string]config.KeyType{}
+	for _, key := range b.Keys {
+		keys[key] = config.KeyTypeAny
+	}
+	if b.queryExpression != nil {
+		for _, key := range b.queryExpression.Vars() {
+			keys[key] = config.KeyTypeAny
+		}
+	}
+	return keys
 }
 
 func (b *BBEntriesProperties) GetOperator() BBEntriesOp {
@@ -40,10 +102,14 @@ func (b *BBEntriesProperties) GetKeys() []string {
 func (b *BBEntriesProperties) GetQuery() string {
 	return b.Query
 }
+func (b *BBEntriesProperties) GetQueryExpression() *script.Expression {
+	return b.queryExpression
+}
 
 // BBEntries 比较黑板条目（Compare BBEntries）
 //
 //	节点将比较多个 黑板键 的值，并根据结果（等于或不等）阻止或允许节点的执行。
+//	配置了查询语句 BBEntriesProperties.Query 时，改为根据查询语句的结果阻止或允许节点的执行。
 type BBEntries struct {
 	bcore.ObservingDecorator
 }
@@ -61,6 +127,10 @@ func (e *BBEntries) BBEntriesProperties() IBBEntriesProperties {
 	return e.Properties().(IBBEntriesProperties)
 }
 
+func (e *BBEntries) BoundBBEntriesProperties(brain bcore.IBrain) IBBEntriesProperties {
+	return e.BoundProperties(brain).(IBBEntriesProperties)
+}
+
 // StartObserving
 //
 //	@override bcore.ObservingDecorator .StartObserving
@@ -68,8 +138,8 @@ func (e *BBEntries) BBEntriesProperties() IBBEntriesProperties {
 //	@param brain
 func (e *BBEntries) StartObserving(brain bcore.IBrain) {
 	e.ObservingDecorator.StartObserving(brain)
-	for _, key := range e.BBEntriesProperties().GetKeys() {
-		brain.Blackboard().(bcore.IBlackboardInternal).AddObserver(key, e.getObserver(brain))
+	for _, key := range e.observingKeys() {
+		e.Blackboard(brain).AddObserver(key, e.getObserver(brain))
 	}
 }
 
@@ -80,8 +150,8 @@ func (e *BBEntries) StartObserving(brain bcore.IBrain) {
 //	@param brain
 func (e *BBEntries) StopObserving(brain bcore.IBrain) {
 	e.ObservingDecorator.StopObserving(brain)
-	for _, key := range e.BBEntriesProperties().GetKeys() {
-		brain.Blackboard().(bcore.IBlackboardInternal).RemoveObserver(key, e.getObserver(brain))
+	for _, key := range e.observingKeys() {
+		e.Blackboard(brain).RemoveObserver(key, e.getObserver(brain))
 	}
 	e.Memory(brain).DefaultObserver = nil
 }
@@ -99,31 +169,55 @@ func (e *BBEntries) ConditionMet(brain bcore.IBrain, args ...any) bool {
 		ret := e.Update(brain, bcore.EventTypeOnUpdate, 0)
 		return ret == bcore.ResultSucceeded
 	}
+	if query := e.BoundBBEntriesProperties(brain).GetQueryExpression(); query != nil {
+		ret, err := query.EvalBool(e.Blackboard(brain).Get)
+		if err != nil {
+			e.Log(brain).Error("eval query error", zap.Error(err), zap.String("query", query.Code()))
+			return false
+		}
+		return ret
+	}
 	var strValues []string
 	allEqual := true
-	for i, key := range e.BBEntriesProperties().GetKeys() {
-		v, _ := brain.Blackboard().Get(key)
+	for i, key := range e.BoundBBEntriesProperties(brain).GetKeys() {
+		v, _ := e.Blackboard(brain).Get(key)
 		str := ""
 		if v != nil {
 			str = fmt.Sprintf("%v", v)
 		}
-		if str != strValues[i-1] {
+		if i > 0 && str != strValues[0] {
 			allEqual = false
 		}
 		strValues = append(strValues, str)
 	}
-	switch e.BBEntriesProperties().GetOperator() {
+	switch e.BoundBBEntriesProperties(brain).GetOperator() {
 	case BBEntriesOpEqual:
 		return allEqual
 	case BBEntriesOpNotEqual:
 		return !allEqual
 	}
-	e.Log(brain).Error("not support operator", zap.Int("operator", int(e.BBEntriesProperties().GetOperator())))
+	e.Log(brain).Error("not support operator", zap.Int("operator", int(e.BoundBBEntriesProperties(brain).GetOperator())))
 	return false
 }
 
 func (e *BBEntries) OnString(brain bcore.IBrain) string {
-	return fmt.Sprintf("%s(%d)%v", e.ObservingDecorator.OnString(brain), e.BBEntriesProperties().GetOperator(), e.BBEntriesProperties().GetKeys())
+	if query := e.BoundBBEntriesProperties(brain).GetQuery(); query != "" {
+		return fmt.Sprintf("%s?%s", e.ObservingDecorator.OnString(brain), query)
+	}
+	return fmt.Sprintf("%s(%d)%v", e.ObservingDecorator.OnString(brain), e.BoundBBEntriesProperties(brain).GetOperator(), e.BoundBBEntriesProperties(brain).GetKeys())
+}
+
+// observingKeys 需要监听的黑板键: Keys 和查询语句引用的键.开始和停止监听须使用相同的键,故不使用绑定黑板的属性
+//
+//	@receiver e
+//	@return []string
+func (e *BBEntries) observingKeys() []string {
+	props := e.Properties().(IBBEntriesProperties)
+	keys := props.GetKeys()
+	if query := props.GetQueryExpression(); query != nil {
+		keys = lo.Union(keys, query.Vars())
+	}
+	return keys
 }
 
 func (e *BBEntries) getObserver(brain bcore.IBrain) bcore.Observer {
@@ -36,6 +36,10 @@ func (c *Condition) ConditionProperties() IConditionProperties {
 	return c.Properties().(IConditionProperties)
 }
 
+func (c *Condition) BoundConditionProperties(brain bcore.IBrain) IConditionProperties {
+	return c.BoundProperties(brain).(IConditionProperties)
+}
+
 // StartObserving
 //
 //	@override bcore.ObservingDecorator .StartObserving
@@ -43,19 +47,19 @@ func (c *Condition) ConditionProperties() IConditionProperties {
 //	@param brain
 func (c *Condition) StartObserving(brain bcore.IBrain) {
 	c.ObservingDecorator.StartObserving(brain)
-	interval := c.Properties().(IServiceProperties).GetInterval()
-	randomDeviation := c.Properties().(IServiceProperties).GetRandomDeviation()
+	interval := c.BoundProperties(brain).(IServiceProperties).GetInterval()
+	randomDeviation := c.BoundProperties(brain).(IServiceProperties).GetRandomDeviation()
 	if interval <= 0 {
 		interval = c.Root(brain).Interval()
 	}
 	c.StopObserving(brain)
-	lastTime := time.Now()
+	lastTime := brain.Now()
 	// 默认投递到黑板保存的线程ID
 	c.Memory(brain).CronTask = brain.Cron(interval, randomDeviation, func() {
 		if !c.IsActive(brain) {
 			return
 		}
-		currTime := time.Now()
+		currTime := brain.Now()
 		delta := currTime.Sub(lastTime)
 		c.Evaluate(brain, delta)
 		lastTime = currTime
@@ -84,8 +88,9 @@ func (c *Condition) StopObserving(brain bcore.IBrain) {
 //	@return bool
 func (c *Condition) ConditionMet(brain bcore.IBrain, args ...any) bool {
 	var delta time.Duration
+	// 定时检查时传入流逝的时间,黑板键变化(ObservingProperties.ObservePatterns)触发时参数为黑板变化
 	if len(args) > 0 {
-		delta = args[0].(time.Duration)
+		delta, _ = args[0].(time.Duration)
 	}
 	if c.HasDelegatorOrScript() {
 		ret := c.Update(brain, bcore.EventTypeOnUpdate, delta)
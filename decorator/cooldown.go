@@ -14,6 +14,7 @@ type ICooldownProperties interface {
 
 // CooldownProperties cd等待装饰器属性
 type CooldownProperties struct {
+	CooldownBaseProperties
 	CooldownTime util.Duration `json:"cooldownTime"` // 冷却时间
 }
 
@@ -41,5 +42,5 @@ func (b *Cooldown) PropertiesClassProvider() any {
 //  @param brain
 //  @return time.Duration
 func (b *Cooldown) CooldownTime(brain bcore.IBrain) time.Duration {
-	return b.Properties().(ICooldownProperties).GetCooldownTime()
+	return b.BoundProperties(brain).(ICooldownProperties).GetCooldownTime()
 }
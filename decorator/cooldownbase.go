@@ -76,6 +76,10 @@ func (b *CooldownBase) CooldownProperties() ICooldownBaseProperties {
 	return b.Properties().(ICooldownBaseProperties)
 }
 
+func (b *CooldownBase) BoundCooldownProperties(brain bcore.IBrain) ICooldownBaseProperties {
+	return b.BoundProperties(brain).(ICooldownBaseProperties)
+}
+
 // OnStart
 //
 //	@override Node.OnStart
@@ -85,13 +89,13 @@ func (b *CooldownBase) OnStart(brain bcore.IBrain) {
 	b.Decorator.OnStart(brain)
 	if !b.Memory(brain).Cooling {
 		b.Memory(brain).Cooling = true
-		if !b.CooldownProperties().GetStartAfterDecorated() {
+		if !b.BoundCooldownProperties(brain).GetStartAfterDecorated() {
 			b.startTimer(brain)
 		}
 		b.Decorated(brain).Start(brain)
 		return
 	}
-	if b.CooldownProperties().GetFailOnCoolDown() {
+	if b.BoundCooldownProperties(brain).GetFailOnCoolDown() {
 		b.Finish(brain, false)
 	}
 }
@@ -116,15 +120,28 @@ func (b *CooldownBase) OnAbort(brain bcore.IBrain) {
 //	@param succeeded
 func (b *CooldownBase) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeeded bool) {
 	b.Decorator.OnChildFinished(brain, child, succeeded)
-	if !succeeded && b.CooldownProperties().GetResetOnFailure() {
+	if !succeeded && b.BoundCooldownProperties(brain).GetResetOnFailure() {
 		b.Memory(brain).Cooling = false
 		b.stopTimer(brain)
-	} else if b.CooldownProperties().GetStartAfterDecorated() {
+	} else if b.BoundCooldownProperties(brain).GetStartAfterDecorated() {
 		b.startTimer(brain)
 	}
 	b.Finish(brain, succeeded)
 }
 
+// OnRestore 恢复冷却定时任务.冷却可能在节点结束后仍在进行,故不判断节点状态
+//
+//	@override Node.OnRestore
+//	@receiver b
+//	@param brain
+//	@param timerRemaining
+func (b *CooldownBase) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	b.Decorator.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && b.Memory(brain).Cooling {
+		b.Memory(brain).CronTask = brain.After(timerRemaining, 0, b.getTaskFun(brain))
+	}
+}
+
 func (b *CooldownBase) getTaskFun(brain bcore.IBrain) func() {
 	return func() {
 		if b.IsActive(brain) && !b.Decorated(brain).IsActive(brain) {
@@ -137,7 +154,7 @@ func (b *CooldownBase) getTaskFun(brain bcore.IBrain) func() {
 }
 func (b *CooldownBase) startTimer(brain bcore.IBrain) {
 	b.Memory(brain).CronTask = brain.After(b.CooldownTime(brain),
-		b.CooldownProperties().GetRandomDeviation(),
+		b.BoundCooldownProperties(brain).GetRandomDeviation(),
 		b.getTaskFun(brain))
 }
 func (b *CooldownBase) stopTimer(brain bcore.IBrain) {
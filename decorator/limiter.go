@@ -0,0 +1,81 @@
+package decorator
+
+import (
+	"github.com/alkaid/behavior/bcore"
+)
+
+type ILimiterProperties interface {
+	GetMaxLoop() int
+}
+
+// LimiterProperties 次数限制装饰器属性
+type LimiterProperties struct {
+	MaxLoop int `json:"maxLoop"` // 子节点最多执行的次数
+}
+
+func (l *LimiterProperties) GetMaxLoop() int {
+	return l.MaxLoop
+}
+
+// Limiter 次数限制装饰器
+//
+//	子节点执行完成的次数达到 MaxLoop 后,不再启动子节点而是直接返回失败.计数保存在节点数据中,不随节点重新启动而清零
+type Limiter struct {
+	bcore.Decorator
+}
+
+// PropertiesClassProvider
+//
+//	@implement INodeWorker.PropertiesClassProvider
+//	@receiver l
+//	@return any
+func (l *Limiter) PropertiesClassProvider() any {
+	return &LimiterProperties{}
+}
+
+func (l *Limiter) LimiterProperties() ILimiterProperties {
+	return l.Properties().(ILimiterProperties)
+}
+
+func (l *Limiter) BoundLimiterProperties(brain bcore.IBrain) ILimiterProperties {
+	return l.BoundProperties(brain).(ILimiterProperties)
+}
+
+// OnStart
+//
+//	@override Node.OnStart
+//	@receiver l
+//	@param brain
+func (l *Limiter) OnStart(brain bcore.IBrain) {
+	l.Decorator.OnStart(brain)
+	if l.Memory(brain).CurrIndex >= l.BoundLimiterProperties(brain).GetMaxLoop() {
+		l.Finish(brain, false)
+		return
+	}
+	l.Decorated(brain).Start(brain)
+}
+
+// OnAbort
+//
+//	@override Node.OnAbort
+//	@receiver l
+//	@param brain
+func (l *Limiter) OnAbort(brain bcore.IBrain) {
+	l.Decorator.OnAbort(brain)
+}
+
+// OnChildFinished
+//
+//	@override bcore.Decorator .OnChildFinished
+//	@receiver l
+//	@param brain
+//	@param child
+//	@param succeeded
+func (l *Limiter) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeeded bool) {
+	l.Decorator.OnChildFinished(brain, child, succeeded)
+	// 被中断的执行不计数
+	if !l.IsAborting(brain) {
+		l.Memory(brain).CurrIndex++
+	}
+	l.Finish(brain, succeeded)
+}
@@ -1,8 +1,11 @@
 package decorator
 
 import (
+	"fmt"
 	"math/rand/v2"
 
+	"github.com/pkg/errors"
+
 	"github.com/alkaid/behavior/bcore"
 )
 
@@ -15,6 +18,18 @@ type RandomProperties struct {
 	Probability float64 `json:"probability"` // 概率,必须0<=probability<=1
 }
 
+// Valid
+//
+//	@implement bcore.IPropertiesValidator .Valid
+//	@receiver r
+//	@return error
+func (r *RandomProperties) Valid() error {
+	if r.Probability < 0 || r.Probability > 1 {
+		return errors.New(fmt.Sprintf("probability must be in [0,1],probability=%v", r.Probability))
+	}
+	return nil
+}
+
 func (r *RandomProperties) GetProbability() float64 {
 	if r.Probability < 0 {
 		return 0
@@ -48,7 +63,7 @@ func (r *Random) PropertiesClassProvider() any {
 //	@param brain
 func (r *Random) OnStart(brain bcore.IBrain) {
 	r.Decorator.OnStart(brain)
-	if rand.Float64() <= r.Properties().(IRandomProperties).GetProbability() {
+	if rand.Float64() <= r.BoundProperties(brain).(IRandomProperties).GetProbability() {
 		r.Decorated(brain).Start(brain)
 	} else {
 		r.Finish(brain, false)
@@ -1,22 +1,28 @@
 package decorator
 
 import (
+	"time"
+
 	"github.com/alkaid/behavior/bcore"
-	"github.com/alkaid/behavior/thread"
 )
 
 type IRepeaterProperties interface {
 	GetTimes() int
+	GetUntilSuccess() bool
 }
 
 // RepeaterProperties 黑板条件节点属性
 type RepeaterProperties struct {
-	Times int `json:"times"` // 循环次数 0或负值将永远循环
+	Times        int  `json:"times"`        // 循环次数 0或负值将永远循环
+	UntilSuccess bool `json:"untilSuccess"` // false:子节点失败时结束并返回失败 true:子节点失败时重试,成功时结束并返回成功,达到循环次数仍失败则返回失败
 }
 
 func (r *RepeaterProperties) GetTimes() int {
 	return r.Times
 }
+func (r *RepeaterProperties) GetUntilSuccess() bool {
+	return r.UntilSuccess
+}
 
 // Repeater 条件装饰器
 //
@@ -54,6 +60,19 @@ func (r *Repeater) OnAbort(brain bcore.IBrain) {
 	r.Decorator.OnAbort(brain)
 }
 
+// OnRestore 快照可能发生在两次循环之间,此时子节点尚未重新启动,需补发启动
+//
+//	@override Node.OnRestore
+//	@receiver r
+//	@param brain
+//	@param timerRemaining
+func (r *Repeater) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	r.Decorator.OnRestore(brain, timerRemaining)
+	if r.IsActive(brain) && r.Decorated(brain).IsInactive(brain) {
+		r.startDecorated(brain)
+	}
+}
+
 // OnChildFinished
 //
 //	@override bcore.Decorator .OnChildFinished
@@ -63,18 +82,28 @@ func (r *Repeater) OnAbort(brain bcore.IBrain) {
 //	@param succeeded
 func (r *Repeater) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeeded bool) {
 	r.Decorator.OnChildFinished(brain, child, succeeded)
-	if !succeeded {
-		r.Finish(brain, false)
+	untilSuccess := r.BoundProperties(brain).(IRepeaterProperties).GetUntilSuccess()
+	// 结束条件的结果:默认模式子节点失败即结束,重试模式子节点成功即结束
+	if succeeded == untilSuccess {
+		r.Finish(brain, succeeded)
 		return
 	}
 	r.Memory(brain).CurrIndex++
-	if r.IsAborting(brain) || (r.Properties().(IRepeaterProperties).GetTimes() > 0 && r.Memory(brain).CurrIndex >= r.Properties().(IRepeaterProperties).GetTimes()) {
-		r.Finish(brain, true)
+	if r.IsAborting(brain) || (r.BoundProperties(brain).(IRepeaterProperties).GetTimes() > 0 && r.Memory(brain).CurrIndex >= r.BoundProperties(brain).(IRepeaterProperties).GetTimes()) {
+		r.Finish(brain, !untilSuccess)
 		return
 	}
 	// 不能直接 Finish(),会堆栈溢出且阻塞其他分支,应该重新异步派发
-	thread.GoByID(brain.Blackboard().(bcore.IBlackboardInternal).ThreadID(), func() {
-		if !r.IsActive(brain) {
+	r.startDecorated(brain)
+}
+
+// startDecorated 异步启动子节点,避免同步循环导致堆栈溢出
+//
+//	@receiver r
+//	@param brain
+func (r *Repeater) startDecorated(brain bcore.IBrain) {
+	brain.Go(func() {
+		if !r.IsActive(brain) || !r.Decorated(brain).IsInactive(brain) {
 			return
 		}
 		r.Decorated(brain).Start(brain)
@@ -51,25 +51,47 @@ func (s *Service) PropertiesClassProvider() any {
 //	@param brain
 func (s *Service) OnStart(brain bcore.IBrain) {
 	s.Decorator.OnStart(brain)
-	interval := s.Properties().(IServiceProperties).GetInterval()
-	randomDeviation := s.Properties().(IServiceProperties).GetRandomDeviation()
+	interval := s.BoundProperties(brain).(IServiceProperties).GetInterval()
+	randomDeviation := s.BoundProperties(brain).(IServiceProperties).GetRandomDeviation()
 	if interval <= 0 {
 		interval = s.Root(brain).Interval()
 	}
-	lastTime := time.Now()
+	s.startTimer(brain, interval, randomDeviation)
+	s.Update(brain, bcore.EventTypeOnStart, 0)
+	s.Decorated(brain).Start(brain)
+}
+
+// OnRestore 恢复定时服务
+//
+//	@override Node.OnRestore
+//	@receiver s
+//	@param brain
+//	@param timerRemaining
+func (s *Service) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	s.Decorator.OnRestore(brain, timerRemaining)
+	if timerRemaining < 0 || !s.IsActive(brain) {
+		return
+	}
+	interval := s.BoundProperties(brain).(IServiceProperties).GetInterval()
+	if interval <= 0 {
+		interval = s.Root(brain).Interval()
+	}
+	s.startTimer(brain, interval, s.BoundProperties(brain).(IServiceProperties).GetRandomDeviation())
+}
+
+func (s *Service) startTimer(brain bcore.IBrain, interval time.Duration, randomDeviation time.Duration) {
+	lastTime := brain.Now()
 	s.stopTimer(brain)
 	// 默认投递到黑板保存的线程ID
 	s.Memory(brain).CronTask = brain.Cron(interval, randomDeviation, func() {
 		if !s.IsActive(brain) {
 			return
 		}
-		currTime := time.Now()
+		currTime := brain.Now()
 		delta := currTime.Sub(lastTime)
 		s.Update(brain, bcore.EventTypeOnStart, delta)
 		lastTime = currTime
 	})
-	s.Update(brain, bcore.EventTypeOnStart, 0)
-	s.Decorated(brain).Start(brain)
 }
 
 // OnAbort
@@ -51,6 +51,10 @@ func (m *TimeMax) TimeMaxProperties() ITimeMaxProperties {
 	return m.Properties().(ITimeMaxProperties)
 }
 
+func (m *TimeMax) BoundTimeMaxProperties(brain bcore.IBrain) ITimeMaxProperties {
+	return m.BoundProperties(brain).(ITimeMaxProperties)
+}
+
 // OnStart
 //
 //	@override Node.OnStart
@@ -90,12 +94,25 @@ func (m *TimeMax) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeed
 	}
 }
 
+// OnRestore 恢复时限定时任务
+//
+//	@override Node.OnRestore
+//	@receiver m
+//	@param brain
+//	@param timerRemaining
+func (m *TimeMax) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	m.Decorator.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && m.IsActive(brain) {
+		m.Memory(brain).CronTask = brain.After(timerRemaining, 0, m.getTaskFun(brain))
+	}
+}
+
 func (b *TimeMax) getTaskFun(brain bcore.IBrain) func() {
 	return func() {
 		if !b.IsActive(brain) {
 			return
 		}
-		if !b.TimeMaxProperties().GetWaitForChildButFail() {
+		if !b.BoundTimeMaxProperties(brain).GetWaitForChildButFail() {
 			b.Decorated(brain).SetUpstream(brain, b)
 			b.Decorated(brain).Abort(brain)
 		} else {
@@ -108,8 +125,8 @@ func (b *TimeMax) getTaskFun(brain bcore.IBrain) func() {
 	}
 }
 func (b *TimeMax) startTimer(brain bcore.IBrain) {
-	b.Memory(brain).CronTask = brain.After(b.TimeMaxProperties().GetLimit(),
-		b.TimeMaxProperties().GetRandomDeviation(),
+	b.Memory(brain).CronTask = brain.After(b.BoundTimeMaxProperties(brain).GetLimit(),
+		b.BoundTimeMaxProperties(brain).GetRandomDeviation(),
 		b.getTaskFun(brain))
 }
 func (b *TimeMax) stopTimer(brain bcore.IBrain) {
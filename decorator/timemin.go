@@ -50,6 +50,10 @@ func (m *TimeMin) TimeMinProperties() ITimeMinProperties {
 	return m.Properties().(ITimeMinProperties)
 }
 
+func (m *TimeMin) BoundTimeMinProperties(brain bcore.IBrain) ITimeMinProperties {
+	return m.BoundProperties(brain).(ITimeMinProperties)
+}
+
 // OnStart
 //
 //	@override Node.OnStart
@@ -86,7 +90,7 @@ func (m *TimeMin) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeed
 	m.Decorator.OnChildFinished(brain, child, succeeded)
 	m.Memory(brain).DecoratedDone = true
 	m.Memory(brain).DecoratedSuccess = succeeded
-	if m.Memory(brain).LimitReached || (!succeeded && m.TimeMinProperties().GetFinishOnChildFailure()) {
+	if m.Memory(brain).LimitReached || (!succeeded && m.BoundTimeMinProperties(brain).GetFinishOnChildFailure()) {
 		m.stopTimer(brain)
 		m.Finish(brain, succeeded)
 		return
@@ -97,6 +101,19 @@ func (m *TimeMin) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeed
 	}
 }
 
+// OnRestore 恢复时限定时任务
+//
+//	@override Node.OnRestore
+//	@receiver m
+//	@param brain
+//	@param timerRemaining
+func (m *TimeMin) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	m.Decorator.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && m.IsActive(brain) {
+		m.Memory(brain).CronTask = brain.After(timerRemaining, 0, m.getTaskFun(brain))
+	}
+}
+
 func (b *TimeMin) getTaskFun(brain bcore.IBrain) func() {
 	return func() {
 		if !b.IsActive(brain) {
@@ -114,8 +131,8 @@ func (b *TimeMin) getTaskFun(brain bcore.IBrain) func() {
 	}
 }
 func (b *TimeMin) startTimer(brain bcore.IBrain) {
-	b.Memory(brain).CronTask = brain.After(b.TimeMinProperties().GetLimit(),
-		b.TimeMinProperties().GetRandomDeviation(),
+	b.Memory(brain).CronTask = brain.After(b.BoundTimeMinProperties(brain).GetLimit(),
+		b.BoundTimeMinProperties(brain).GetRandomDeviation(),
 		b.getTaskFun(brain))
 }
 func (b *TimeMin) stopTimer(brain bcore.IBrain) {
@@ -1,29 +1,235 @@
 package decorator
 
 import (
+	"time"
+
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/util"
 )
 
 type IWaitConditionProperties interface {
+	IConditionProperties
+	GetKeys() []string
+	GetTimeout() time.Duration
 }
 
 // WaitConditionProperties 条件等待装饰器属性
+//
+//	ConditionProperties 中仅检查间隔 Interval 和随机离差 RandomDeviation 有效,中断模式 AbortMode 无效
 type WaitConditionProperties struct {
 	ConditionProperties
+	Keys    []string      `json:"keys"`    // 监听的黑板键,任一改变时立即检查条件,可为空.可以是含通配符的模式,如 "enemy.*" ,参看 bcore.PatternWildcard
+	Timeout util.Duration `json:"timeout"` // 超时时间,超时后以失败结束,<=0表示不超时.与检查间隔无关
+}
+
+// BlackboardKeys
+//
+//	@implement bcore.IBlackboardKeysProvider .BlackboardKeys
+//	@receiver w
+//	@return map[string]config.KeyType
+func (w *WaitConditionProperties) BlackboardKeys() map[string]config.KeyType {
+	keys := make(map[string]config.KeyType, len(w.Keys))
+	for _, key := range w.Keys {
+		keys[key] = config.KeyTypeAny
+	}
+	return keys
+}
+
+func (w *WaitConditionProperties) GetKeys() []string {
+	return w.Keys
+}
+
+func (w *WaitConditionProperties) GetTimeout() time.Duration {
+	return w.Timeout.Duration
 }
 
 // WaitCondition 条件等待装饰器
-//  将阻塞等待条件成功才开始执行分支
+//
+//	将阻塞等待条件成功才开始执行分支,分支的结果即为自己的结果.
+//	等待期间按间隔检查委托方法或脚本,配置了黑板键时键值改变也会立即检查;超时则以失败结束.
 type WaitCondition struct {
 	bcore.Decorator
 }
 
 // PropertiesClassProvider
-//  @implement INodeWorker.PropertiesClassProvider
-//  @receiver n
-//  @return any
-func (o *WaitCondition) PropertiesClassProvider() any {
+//
+//	@implement INodeWorker.PropertiesClassProvider
+//	@receiver n
+//	@return any
+func (w *WaitCondition) PropertiesClassProvider() any {
 	return &WaitConditionProperties{}
 }
 
-// TODO 未完,同样的还有 黑板条件等待 黑板条目等待,考虑抽象一个等待监听器基类
+func (w *WaitCondition) WaitConditionProperties() IWaitConditionProperties {
+	return w.Properties().(IWaitConditionProperties)
+}
+
+func (w *WaitCondition) BoundWaitConditionProperties(brain bcore.IBrain) IWaitConditionProperties {
+	return w.BoundProperties(brain).(IWaitConditionProperties)
+}
+
+// OnStart
+//
+//	@override Node.OnStart
+//	@receiver w
+//	@param brain
+func (w *WaitCondition) OnStart(brain bcore.IBrain) {
+	w.Decorator.OnStart(brain)
+	w.Memory(brain).Elapsed = 0
+	if w.conditionMet(brain, 0) {
+		w.Decorated(brain).Start(brain)
+		return
+	}
+	w.startWaiting(brain)
+}
+
+// OnAbort
+//
+//	@override Node.OnAbort
+//	@receiver w
+//	@param brain
+func (w *WaitCondition) OnAbort(brain bcore.IBrain) {
+	w.stopWaiting(brain)
+	w.Decorator.OnAbort(brain)
+}
+
+// OnChildFinished
+//
+//	@override bcore.Decorator .OnChildFinished
+//	@receiver w
+//	@param brain
+//	@param child
+//	@param succeeded
+func (w *WaitCondition) OnChildFinished(brain bcore.IBrain, child bcore.INode, succeeded bool) {
+	w.Decorator.OnChildFinished(brain, child, succeeded)
+	w.Finish(brain, succeeded)
+}
+
+// OnRestore 恢复等待
+//
+//	@override Node.OnRestore
+//	@receiver w
+//	@param brain
+//	@param timerRemaining
+func (w *WaitCondition) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	w.Decorator.OnRestore(brain, timerRemaining)
+	if w.Memory(brain).Observing {
+		w.Memory(brain).Observing = false
+		w.startWaiting(brain)
+	}
+}
+
+// check 检查条件,满足则停止等待并启动子节点,超时则以失败结束
+//
+//	@receiver w
+//	@param brain
+//	@param delta 距上次检查流逝的时间,不是定时检查时为0
+func (w *WaitCondition) check(brain bcore.IBrain, delta time.Duration) {
+	if !w.IsActive(brain) || !w.Memory(brain).Observing {
+		return
+	}
+	w.Memory(brain).Elapsed += delta
+	if w.conditionMet(brain, delta) {
+		w.stopWaiting(brain)
+		w.Decorated(brain).Start(brain)
+	}
+}
+
+// timeout 超时,停止等待并以失败结束
+//
+//	@receiver w
+//	@param brain
+func (w *WaitCondition) timeout(brain bcore.IBrain) {
+	if !w.IsActive(brain) || !w.Memory(brain).Observing {
+		return
+	}
+	w.stopWaiting(brain)
+	w.Finish(brain, false)
+}
+
+func (w *WaitCondition) conditionMet(brain bcore.IBrain, delta time.Duration) bool {
+	if !w.HasDelegatorOrScript() {
+		w.Log(brain).Error("must set delegator method")
+		return false
+	}
+	return w.Update(brain, bcore.EventTypeOnUpdate, delta) == bcore.ResultSucceeded
+}
+
+func (w *WaitCondition) startWaiting(brain bcore.IBrain) {
+	memory := w.Memory(brain)
+	if memory.Observing {
+		return
+	}
+	memory.Observing = true
+	interval := w.BoundWaitConditionProperties(brain).GetInterval()
+	if interval <= 0 {
+		interval = w.Root(brain).Interval()
+	}
+	lastTime := brain.Now()
+	memory.CronTask = brain.Cron(interval, w.BoundWaitConditionProperties(brain).GetRandomDeviation(), func() {
+		currTime := brain.Now()
+		delta := currTime.Sub(lastTime)
+		lastTime = currTime
+		w.check(brain, delta)
+	})
+	if timeout := w.BoundWaitConditionProperties(brain).GetTimeout(); timeout > 0 {
+		// 恢复快照时使用剩余时间
+		if memory.TimeoutRemaining != nil {
+			timeout = *memory.TimeoutRemaining
+		}
+		memory.TimeoutTask = brain.After(timeout, 0, func() {
+			w.timeout(brain)
+		})
+	}
+	// 开始和停止监听须使用相同的键,故不使用绑定黑板的属性
+	for _, key := range w.Properties().(IWaitConditionProperties).GetKeys() {
+		if bcore.IsPatternKey(key) {
+			w.Blackboard(brain).AddPatternObserver(key, w.getObserver(brain))
+			continue
+		}
+		w.Blackboard(brain).AddObserver(key, w.getObserver(brain))
+	}
+}
+
+func (w *WaitCondition) stopWaiting(brain bcore.IBrain) {
+	memory := w.Memory(brain)
+	if !memory.Observing {
+		return
+	}
+	memory.Observing = false
+	if memory.CronTask != nil {
+		memory.CronTask.Stop()
+		memory.CronTask = nil
+	}
+	if memory.TimeoutTask != nil {
+		memory.TimeoutTask.Stop()
+		memory.TimeoutTask = nil
+	}
+	for _, key := range w.Properties().(IWaitConditionProperties).GetKeys() {
+		if bcore.IsPatternKey(key) {
+			w.Blackboard(brain).RemovePatternObserver(key, w.getObserver(brain))
+			continue
+		}
+		w.Blackboard(brain).RemoveObserver(key, w.getObserver(brain))
+	}
+	memory.DefaultObserver = nil
+}
+
+func (w *WaitCondition) getObserver(brain bcore.IBrain) bcore.Observer {
+	ob := w.Memory(brain).DefaultObserver
+	if ob == nil {
+		ob = &waitConditionObserver{brain: brain, w: w}
+		w.Memory(brain).DefaultObserver = ob
+	}
+	return ob
+}
+
+type waitConditionObserver struct {
+	brain bcore.IBrain
+	w     *WaitCondition
+}
+
+func (o *waitConditionObserver) Fire(op bcore.OpType, key string, oldValue any, newValue any) {
+	o.w.check(o.brain, 0)
+}
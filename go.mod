@@ -5,6 +5,7 @@ go 1.24.2
 require (
 	github.com/alkaid/timingwheel v1.0.5
 	github.com/disiqueira/gotree v1.0.0
+	github.com/expr-lang/expr v1.17.8
 	github.com/google/go-cmp v0.7.0
 	github.com/matoous/go-nanoid v1.5.1
 	github.com/panjf2000/ants/v2 v2.11.3
@@ -16,11 +16,13 @@ type Handler struct {
 
 type HandlerPool struct {
 	handlers map[string]*Handler // all handler method
+	targets  map[string]bool     // all registered target name
 }
 
 func NewHandlerPool() *HandlerPool {
 	return &HandlerPool{
 		handlers: make(map[string]*Handler),
+		targets:  make(map[string]bool),
 	}
 }
 
@@ -49,9 +51,18 @@ func (h *HandlerPool) Register(name string, target any) (err error) {
 	for methodName, handler := range handles {
 		h.handlers[fmt.Sprintf("%s.%s", name, methodName)] = handler
 	}
+	h.targets[name] = true
 	return nil
 }
 
+// ContainsTarget 是否注册了代理类
+//  @receiver h
+//  @param targetName
+//  @return bool
+func (h *HandlerPool) ContainsTarget(targetName string) bool {
+	return h.targets[targetName]
+}
+
 func (h *HandlerPool) GetHandle(targetName string, methodName string) *Handler {
 	handler := h.handlers[fmt.Sprintf("%s.%s", targetName, methodName)]
 	if handler == nil {
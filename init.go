@@ -50,51 +50,62 @@ func InitSystem(opts ...Option) {
 		return
 	}
 	timer.InitPool(option.TimerPoolSize, option.TimerInterval, option.TimerNumSlots)
+	if option.Clock != nil {
+		timer.SetGlobalClock(option.Clock)
+	}
 	err = script.InitPool(option.ScriptPoolMinSize, option.ScriptPoolMaxSize, option.ScriptPoolApiLib)
 	if err != nil {
 		logger.Log.Fatal("init behavior system error", zap.Error(err))
 		return
 	}
-	// built in class register
-	GlobalClassLoader().Register(&bcore.Root{})
-
-	GlobalClassLoader().Register(&composite.Sequence{})
-	GlobalClassLoader().Register(&composite.Selector{})
-	GlobalClassLoader().Register(&composite.RandomSequence{})
-	GlobalClassLoader().Register(&composite.RandomSelector{})
-	GlobalClassLoader().Register(&composite.Parallel{})
-
-	GlobalClassLoader().Register(&decorator.BBCondition{})
-	GlobalClassLoader().Register(&decorator.BBCooldown{})
-	GlobalClassLoader().Register(&decorator.BBEntries{})
-	GlobalClassLoader().Register(&decorator.Condition{})
-	GlobalClassLoader().Register(&decorator.Cooldown{})
-	GlobalClassLoader().Register(&decorator.Failure{})
-	GlobalClassLoader().Register(&decorator.Inverter{})
-	GlobalClassLoader().Register(&decorator.Random{})
-	GlobalClassLoader().Register(&decorator.Repeater{})
-	GlobalClassLoader().Register(&decorator.Service{})
-	GlobalClassLoader().Register(&decorator.Succeeded{})
-	GlobalClassLoader().Register(&decorator.TimeMax{})
-	GlobalClassLoader().Register(&decorator.TimeMin{})
-	GlobalClassLoader().Register(&decorator.WaitCondition{})
-
-	GlobalClassLoader().Register(&task.Action{})
-	GlobalClassLoader().Register(&task.Wait{})
-	GlobalClassLoader().Register(&task.WaitBB{})
-	GlobalClassLoader().Register(&task.Subtree{})
-	GlobalClassLoader().Register(&task.DynamicSubtree{})
+	registerBuiltinNodes(GlobalClassLoader())
 	// 注册自定义节点
 	for _, class := range option.CustomNodeClass {
 		GlobalClassLoader().Register(class)
 	}
 }
 
+// registerBuiltinNodes 注册内置节点类
+//
+//	@param loader
+func registerBuiltinNodes(loader *ClassLoader) {
+	loader.Register(&bcore.Root{})
+
+	loader.Register(&composite.Sequence{})
+	loader.Register(&composite.Selector{})
+	loader.Register(&composite.RandomSequence{})
+	loader.Register(&composite.RandomSelector{})
+	loader.Register(&composite.Parallel{})
+
+	loader.Register(&decorator.BBCondition{})
+	loader.Register(&decorator.BBCooldown{})
+	loader.Register(&decorator.BBEntries{})
+	loader.Register(&decorator.Condition{})
+	loader.Register(&decorator.Cooldown{})
+	loader.Register(&decorator.Failure{})
+	loader.Register(&decorator.Inverter{})
+	loader.Register(&decorator.Limiter{})
+	loader.Register(&decorator.Random{})
+	loader.Register(&decorator.Repeater{})
+	loader.Register(&decorator.Service{})
+	loader.Register(&decorator.Succeeded{})
+	loader.Register(&decorator.TimeMax{})
+	loader.Register(&decorator.TimeMin{})
+	loader.Register(&decorator.WaitCondition{})
+
+	loader.Register(&task.Action{})
+	loader.Register(&task.Wait{})
+	loader.Register(&task.WaitBB{})
+	loader.Register(&task.Subtree{})
+	loader.Register(&task.DynamicSubtree{})
+}
+
 type InitialOption struct {
 	ThreadPool        *ants.PoolWithID // 线程池 为空则使用默认
 	TimerPoolSize     int              // 时间轮池子容量 为0则使用默认
 	TimerInterval     time.Duration    // 时间轮帧间隔 为0则使用默认
 	TimerNumSlots     int              // 时间槽数量 时间轮第一层总时长=interval*numSlots 为0则使用默认
+	Clock             timer.Clock      // 全局时钟 为空则使用真实时间+时间轮
 	LogLevel          zapcore.Level    // 日志级别
 	LogDevelopment    bool             // 日志模式是否开发模式
 	CustomNodeClass   []bcore.INode    // 用于注册自定义节点类
@@ -126,6 +137,16 @@ func WithTimerNumSlots(slots int) Option {
 		o.TimerNumSlots = slots
 	}
 }
+
+// WithClock 设置全局时钟,测试或模拟时可传入 timer.ManualClock 以手动推进时间
+//
+//	@param clock
+//	@return Option
+func WithClock(clock timer.Clock) Option {
+	return func(o *InitialOption) {
+		o.Clock = clock
+	}
+}
 func WithLogLevel(level zapcore.Level) Option {
 	return func(o *InitialOption) {
 		o.LogLevel = level
@@ -159,6 +180,24 @@ func WithScriptPoolApiLib(api map[string]any) Option {
 	}
 }
 
+// WithValidateOnLoad 加载树时先调用 TreeRegistry.Validate 静态检查,有错误则拒绝加载,警告仅打印日志
+//
+//	@return Option
+func WithValidateOnLoad() Option {
+	return func(o *InitialOption) {
+		internal.GlobalConfig.ValidateOnLoad = true
+	}
+}
+
+// WithStrictBlackboard 黑板严格模式,主树声明了黑板键( config.TreeCfg .Blackboard )时, IBlackboard.Set 拒绝写入类型不符的值并打印错误日志
+//
+//	@return Option
+func WithStrictBlackboard() Option {
+	return func(o *InitialOption) {
+		internal.GlobalConfig.StrictBlackboard = true
+	}
+}
+
 // WithActionSuccessIfNotDelegate 当委托方法不存在时,默认返回成功. 常用于debug时,避免每次都要写委托方法.
 //
 //	@return Option
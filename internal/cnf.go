@@ -2,6 +2,8 @@ package internal
 
 type globalConfig struct {
 	ActionSuccessIfNotDelegate bool // 当委托不存在时,action是否返回成功. 常用于debug时,避免每次都要写委托方法.
+	ValidateOnLoad             bool // 加载树时是否先静态检查,有错误则拒绝加载
+	StrictBlackboard           bool // 黑板严格模式,拒绝写入与树声明的类型不符的值
 }
 
 var GlobalConfig = &globalConfig{}
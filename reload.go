@@ -0,0 +1,555 @@
+package behavior
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/logger"
+	"github.com/alkaid/behavior/util"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+// ReloadPolicy 热更时正在运行旧版树的AI切换到新版的时机
+type ReloadPolicy int
+
+const (
+	ReloadNextLoop    ReloadPolicy = iota // 当前轮运行结束,下一轮开始前切换.一次性(once)的主树不会切换,运行结束后旧版自然退役
+	ReloadImmediately                     // 立即中断当前轮并切换,主树处于两轮之间时直接切换
+)
+
+// dynamicMount Brain 上的一次动态挂载记录,用于热更迁移后重新挂载
+type dynamicMount struct {
+	subtreeTag string         // 子树tag
+	params     map[string]any // 子树的模板参数
+	rootID     string         // 当前挂载的子树 IRoot.ID
+}
+
+// Reload 热更树.新版本与旧版本并存,静态依赖热更树(直接或间接)的树也会重建并重新挂载子树.
+// 之后 Brain.Run 将运行新版本;正在运行旧版本(包括动态挂载了旧版子树)的AI按 policy 在安全点切换到新版本,并重新挂载原有的动态子树.
+// 旧版本在不再被任何AI使用后自动从注册器移除.ver与当前版本相同的配置将被忽略.
+//
+//	线程安全
+//	@receiver r
+//	@param policy
+//	@param cfgs
+//	@return error
+func (r *TreeRegistry) Reload(policy ReloadPolicy, cfgs ...*config.TreeCfg) error {
+	var brains map[*Brain]struct{}
+	err := r.update(func(idx *treeIndex) error {
+		var err error
+		brains, err = r.reload(idx, cfgs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if policy == ReloadImmediately {
+		for brain := range brains {
+			brain.Go(brain.migrateNow)
+		}
+	}
+	return nil
+}
+
+// reload 在修改中的索引上热更
+//
+//	@receiver r
+//	@param idx
+//	@param cfgs
+//	@return map[*Brain]struct{} 需要迁移的AI
+//	@return error
+func (r *TreeRegistry) reload(idx *treeIndex, cfgs []*config.TreeCfg) (map[*Brain]struct{}, error) {
+	// 1.先实例化全部新版本,出错时不影响注册器
+	fresh := map[string]*Tree{}
+	for _, cfg := range cfgs {
+		err := r.checkCfg(cfg)
+		if err != nil {
+			return nil, err
+		}
+		olds := idx.byTag[cfg.Tag]
+		if len(olds) > 0 && olds[0].Ver == cfg.Ver {
+			logger.Log.Warn("tree ver not changed,ignore reload", zap.String("ver", cfg.Ver), zap.String("tag", cfg.Tag))
+			continue
+		}
+		// 节点id是黑板中节点数据和脚本的索引,与旧版并存时须换成新id,避免新旧版本共用
+		if len(olds) > 0 || idx.byID[cfg.Root] != nil {
+			cfg = renewIDs(cfg)
+		}
+		tree, err := r.newTree(cfg)
+		if err != nil {
+			return nil, err
+		}
+		fresh[cfg.Tag] = tree
+	}
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+	// 挂载失败会导致旧版已退役而新版不可用,故先检查子树是否都能找到
+	for _, tree := range fresh {
+		err := r.checkSubtreeTags(idx, tree, fresh)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 2.找出静态依赖热更树的树
+	affected := lo.MapEntries(fresh, func(tag string, _ *Tree) (string, bool) { return tag, true })
+	for changed := true; changed; {
+		changed = false
+		for tag, trees := range idx.byTag {
+			if affected[tag] || len(trees) == 0 || !trees[0].dependsOn(affected) {
+				continue
+			}
+			affected[tag] = true
+			changed = true
+		}
+	}
+	// 3.退役旧版本,依赖树以旧版为模板重建
+	var retired []*Tree
+	templates := map[string]*Tree{}
+	for tag := range affected {
+		trees := idx.byTag[tag]
+		if fresh[tag] == nil && len(trees) > 0 {
+			templates[tag] = trees[0]
+		}
+		// 特化的树不再重建,之后使用时从新版模板重新特化
+		trees = append(slices.Clip(trees), idx.specialized(tag)...)
+		for _, tree := range trees {
+			idx.retire(tree)
+		}
+		retired = append(retired, trees...)
+	}
+	for _, tree := range fresh {
+		idx.add(tree)
+	}
+	for _, tree := range templates {
+		_, err := r.cloneAndReg(idx, tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := r.mountAll(idx)
+	if err != nil {
+		return nil, err
+	}
+	// 4.旧版树上挂载的其他静态子树实例归旧版独占,一并退役.须在 MountAll 之后,此前它们可能是新版克隆的模板
+	r.usersMutex.Lock()
+	defer r.usersMutex.Unlock()
+	for _, tree := range retired {
+		r.retireStaticSubtrees(idx, tree)
+	}
+	// 5.之后不会再出错,退役生效并移除无人使用的旧版,通知使用旧版的AI迁移
+	r.commitRetired(idx)
+	brains := map[*Brain]struct{}{}
+	for id := range r.retired {
+		for brain := range r.users[id] {
+			brains[brain] = struct{}{}
+		}
+	}
+	return brains, nil
+}
+
+// renewIDs 拷贝配置并为所有节点生成新id
+//
+//	@param cfg
+//	@return *config.TreeCfg
+func renewIDs(cfg *config.TreeCfg) *config.TreeCfg {
+	ids := lo.MapValues(cfg.Nodes, func(_ *config.NodeCfg, _ string) string { return util.NanoID() })
+	dst := *cfg
+	dst.Root = ids[cfg.Root]
+	dst.Nodes = make(map[string]*config.NodeCfg, len(cfg.Nodes))
+	for id, node := range cfg.Nodes {
+		n := *node
+		n.ID = ids[id]
+		n.Children = lo.Map(node.Children, func(child string, _ int) string { return ids[child] })
+		dst.Nodes[n.ID] = &n
+	}
+	return &dst
+}
+
+// checkSubtreeTags 检查树的子树容器配置的tag是否都已加载或在本次热更中
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+//	@param fresh
+//	@return error
+func (r *TreeRegistry) checkSubtreeTags(idx *treeIndex, tree *Tree, fresh map[string]*Tree) error {
+	for _, container := range tree.StaticSubtrees {
+		tag := container.GetPropChildTag()
+		if tag == "" || (fresh[tag] == nil && len(idx.byTag[tag]) == 0) {
+			return errors.New(fmt.Sprintf("cannot find subtree,tag=%s,containerTag=%s", tree.Tag, tag))
+		}
+	}
+	for _, container := range tree.DynamicSubtrees {
+		tag := container.GetPropChildTag()
+		if tag != "" && fresh[tag] == nil && len(idx.byTag[tag]) == 0 {
+			return errors.New(fmt.Sprintf("cannot find subtree,tag=%s,containerTag=%s", tree.Tag, tag))
+		}
+	}
+	return nil
+}
+
+// dependsOn 是否静态挂载了 tags 中的子树
+//
+//	@receiver t
+//	@param tags
+//	@return bool
+func (t *Tree) dependsOn(tags map[string]bool) bool {
+	for _, container := range t.StaticSubtrees {
+		if tags[container.GetPropChildTag()] {
+			return true
+		}
+	}
+	for _, container := range t.DynamicSubtrees {
+		if tags[container.GetPropChildTag()] {
+			return true
+		}
+	}
+	return false
+}
+
+// staticSubtrees 静态挂载在该树上的子树
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+//	@return []*Tree
+func (r *TreeRegistry) staticSubtrees(idx *treeIndex, tree *Tree) []*Tree {
+	var children []*Tree
+	each := func(container bcore.IDecorator) {
+		child := container.Decorated(nil)
+		if child == nil {
+			return
+		}
+		if subtree := idx.byID[child.ID()]; subtree != nil {
+			children = append(children, subtree)
+		}
+	}
+	for _, container := range tree.StaticSubtrees {
+		each(container)
+	}
+	for _, container := range tree.DynamicSubtrees {
+		each(container)
+	}
+	return children
+}
+
+// retireStaticSubtrees 递归退役静态挂载在旧版树上的子树.须持有 usersMutex
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+func (r *TreeRegistry) retireStaticSubtrees(idx *treeIndex, tree *Tree) {
+	for _, child := range r.staticSubtrees(idx, tree) {
+		_, ok := r.retired[child.Root.ID()]
+		if !ok && idx.retiring[child.Root.ID()] == nil {
+			idx.retire(child)
+		}
+		r.retireStaticSubtrees(idx, child)
+	}
+}
+
+// commitRetired 修改成功后将本次退役的树并入 retired,并移除无人使用的旧版.须持有 mutex 和 usersMutex
+//
+//	@receiver r
+//	@param idx
+func (r *TreeRegistry) commitRetired(idx *treeIndex) {
+	for id, tree := range idx.retiring {
+		r.retired[id] = tree
+	}
+	idx.retiring = nil
+	for id, tree := range r.retired {
+		if len(r.users[id]) == 0 && tree.Root.Parent(nil) == nil {
+			r.dispose(idx, tree)
+		}
+	}
+}
+
+// dispose 从注册器移除已退役或闲置的树及其静态子树.须持有 mutex 和 usersMutex
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+func (r *TreeRegistry) dispose(idx *treeIndex, tree *Tree) {
+	for _, child := range r.staticSubtrees(idx, tree) {
+		r.dispose(idx, child)
+	}
+	delete(r.retired, tree.Root.ID())
+	delete(idx.byID, tree.Root.ID())
+	idx.without(tree)
+	idx.removed = append(idx.removed, tree)
+	logger.Log.Debug("retired tree disposed", zap.String("tag", tree.Tag), zap.String("ver", tree.Ver), zap.String("id", tree.Root.ID()))
+}
+
+// isRetired 树是否已退役
+//
+//	线程安全
+//	@receiver r
+//	@param rootID
+//	@return bool
+func (r *TreeRegistry) isRetired(rootID string) bool {
+	r.usersMutex.Lock()
+	defer r.usersMutex.Unlock()
+	_, ok := r.retired[rootID]
+	return ok
+}
+
+// acquire 记录 brain 开始使用该树(作为主树运行或动态挂载)
+//
+//	线程安全
+//	@receiver r
+//	@param rootID
+//	@param brain
+func (r *TreeRegistry) acquire(rootID string, brain *Brain) {
+	r.usersMutex.Lock()
+	defer r.usersMutex.Unlock()
+	if r.users == nil {
+		r.users = map[string]map[*Brain]struct{}{}
+	}
+	if r.users[rootID] == nil {
+		r.users[rootID] = map[*Brain]struct{}{}
+	}
+	r.users[rootID][brain] = struct{}{}
+}
+
+// releaseSweepDelay 释放后延迟清理无人使用的树,期间所有释放合并为一次修改
+const releaseSweepDelay = time.Second
+
+// release 记录 brain 不再使用该树.已退役的树或特化树无人使用时记入待清理列表,
+// 在下次修改或 releaseSweepDelay 后清理,避免大量AI同时停止时逐个修改索引
+//
+//	线程安全
+//	@receiver r
+//	@param rootID
+//	@param brain
+func (r *TreeRegistry) release(rootID string, brain *Brain) {
+	if r.releaseUser(rootID, brain) {
+		time.AfterFunc(releaseSweepDelay, r.sweep)
+	}
+}
+
+// sweep 清理无人使用的树
+//
+//	线程安全
+//	@receiver r
+func (r *TreeRegistry) sweep() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sweepLocked()
+}
+
+// isIdleSpec 是否为可在无人使用时移除的特化树:作为主树运行或动态挂载,而非静态挂载在其他树上
+//
+//	@param tree
+//	@return bool
+func isIdleSpec(tree *Tree) bool {
+	return tree.paramsKey != "" && tree.Root.Parent(nil) == nil
+}
+
+// sweepIdle 移除上次修改后仍无人使用的特化树.须持有 mutex 和 usersMutex
+//
+//	@receiver r
+//	@param idx
+func (r *TreeRegistry) sweepIdle(idx *treeIndex) {
+	for id, tree := range r.idle {
+		delete(r.idle, id)
+		// 被获取后又有人使用,之后不再使用时会重新进入闲置
+		if len(r.users[id]) == 0 {
+			r.dispose(idx, tree)
+		}
+	}
+}
+
+// sweepUnused 清理释放后仍无人使用的树:移除已退役的树,特化树先从特化索引中移除并闲置.须持有 mutex 和 usersMutex
+//
+//	@receiver r
+//	@param idx
+func (r *TreeRegistry) sweepUnused(idx *treeIndex) {
+	r.sweeping = false
+	for id := range r.unused {
+		delete(r.unused, id)
+		if len(r.users[id]) > 0 {
+			continue
+		}
+		if tree, ok := r.retired[id]; ok {
+			if tree.Root.Parent(nil) == nil {
+				r.dispose(idx, tree)
+			}
+			continue
+		}
+		// 此前已获取到它但还未记录使用的AI仍可使用,故闲置到下次修改时再移除
+		if tree := idx.byID[id]; tree != nil && isIdleSpec(tree) {
+			idx.without(tree)
+			r.idle[id] = tree
+		}
+	}
+}
+
+// releaseUser 移除使用记录,无人使用的已退役树或特化树记入待清理列表
+//
+//	@receiver r
+//	@param rootID
+//	@param brain
+//	@return bool 是否需要安排延迟清理
+func (r *TreeRegistry) releaseUser(rootID string, brain *Brain) bool {
+	r.usersMutex.Lock()
+	defer r.usersMutex.Unlock()
+	delete(r.users[rootID], brain)
+	if len(r.users[rootID]) > 0 {
+		return false
+	}
+	delete(r.users, rootID)
+	if _, ok := r.retired[rootID]; !ok {
+		tree := r.published().byID[rootID]
+		if tree == nil || !isIdleSpec(tree) {
+			return false
+		}
+	}
+	r.unused[rootID] = struct{}{}
+	if r.sweeping {
+		return false
+	}
+	r.sweeping = true
+	return true
+}
+
+// needMigrate brain 是否需要迁移:运行的主树或动态挂载的子树已退役
+//
+//	@receiver r
+//	@param brain
+//	@return bool
+func (r *TreeRegistry) needMigrate(brain *Brain) bool {
+	if brain.root == nil {
+		return false
+	}
+	if r.isRetired(brain.root.ID()) {
+		tree := r.TreeByID(brain.root.ID())
+		return tree != nil && len(r.TreesByTag(tree.Tag)) > 0
+	}
+	for _, mount := range brain.mounts {
+		if r.isRetired(mount.rootID) {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationTarget
+//
+//	@implement bcore.IBrainInternal.MigrationTarget
+//	@receiver b
+//	@param root
+//	@param aborting
+//	@return bcore.IRoot
+func (b *Brain) MigrationTarget(root bcore.IRoot, aborting bool) bcore.IRoot {
+	if aborting && !b.migrating {
+		return nil
+	}
+	b.migrating = false
+	registry := b.Runtime().TreeRegistry()
+	if !registry.needMigrate(b) {
+		return nil
+	}
+	next := registry.TreeByID(root.ID())
+	if registry.isRetired(root.ID()) {
+		tree, _, err := registry.getNotParentTree(next.Tag, next.Params)
+		if err != nil || tree == nil {
+			logger.Log.Error("cannot find new version tree,migrate canceled", zap.String("tag", next.Tag), zap.Error(err))
+			return nil
+		}
+		next = tree
+	}
+	// 在目标树上重新挂载原有的动态子树,此时目标树未运行,动态容器均未激活.外层先挂载,内层容器才能找到
+	paths := lo.Keys(b.mounts)
+	sort.Slice(paths, func(i, j int) bool {
+		di, dj := strings.Count(paths[i], "/"), strings.Count(paths[j], "/")
+		return di < dj || (di == dj && paths[i] < paths[j])
+	})
+	for _, path := range paths {
+		mount := b.mounts[path]
+		if mount == nil {
+			continue
+		}
+		found, _ := lo.Find(b.dynamicContainers(next), func(c *dynamicContainer) bool { return c.path == path })
+		var subtree *Tree
+		var err error
+		if found != nil {
+			subtree, _, err = registry.getNotDynamicParentTree(mount.subtreeTag, mount.params, found.container, b)
+		}
+		if found == nil || subtree == nil || err != nil {
+			logger.Log.Warn("cannot remount dynamic subtree after migrate", zap.String("containerPath", path), zap.String("subtreeTag", mount.subtreeTag), zap.Error(err))
+			delete(b.mounts, path)
+			registry.release(mount.rootID, b)
+			continue
+		}
+		found.container.DynamicDecorate(b, subtree.Root)
+		b.recordMount(found, subtree)
+	}
+	logger.Log.Debug("brain migrate tree", zap.Int("brain", b.ID()), zap.String("tag", next.Tag), zap.String("ver", next.Ver))
+	return next.Root
+}
+
+// migrateNow 立即迁移到新版本树,须在 brain 线程调用
+//
+//	@receiver b
+func (b *Brain) migrateNow() {
+	if !b.Running() || !b.root.IsActive(b) || !b.Runtime().TreeRegistry().needMigrate(b) {
+		return
+	}
+	b.migrating = true
+	b.root.Migrate(b)
+}
+
+// recordMount 记录动态挂载,用于热更迁移后重新挂载
+//
+//	@receiver b
+//	@param container
+//	@param subtree 挂载的子树
+func (b *Brain) recordMount(container *dynamicContainer, subtree *Tree) {
+	subtreeTag, rootID := subtree.Tag, subtree.Root.ID()
+	registry := b.Runtime().TreeRegistry()
+	if old := b.mounts[container.path]; old != nil {
+		if old.rootID == rootID {
+			return
+		}
+		// 同tag的子树(如热更迁移)内层容器路径不变,保留内层的挂载记录
+		if old.subtreeTag == subtreeTag {
+			registry.release(old.rootID, b)
+		} else {
+			b.removeMount(container)
+		}
+	}
+	if b.mounts == nil {
+		b.mounts = map[string]*dynamicMount{}
+	}
+	b.mounts[container.path] = &dynamicMount{subtreeTag: subtreeTag, params: subtree.Params, rootID: rootID}
+	registry.acquire(rootID, b)
+}
+
+// removeMount 移除动态挂载记录.换成其他tag的子树时,原子树内层的挂载记录一并移除
+//
+//	@receiver b
+//	@param container
+func (b *Brain) removeMount(container *dynamicContainer) {
+	old := b.mounts[container.path]
+	if old == nil {
+		return
+	}
+	registry := b.Runtime().TreeRegistry()
+	delete(b.mounts, container.path)
+	registry.release(old.rootID, b)
+	prefix := strings.Join(append(slices.Clone(container.scope), old.subtreeTag), "/") + "/"
+	for path, mount := range b.mounts {
+		if strings.HasPrefix(path, prefix) {
+			delete(b.mounts, path)
+			registry.release(mount.rootID, b)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package behavior
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/handle"
+	"github.com/alkaid/behavior/internal"
+	"github.com/alkaid/behavior/logger"
+	"github.com/alkaid/behavior/thread"
+	"github.com/alkaid/behavior/timer"
+	"github.com/panjf2000/ants/v2"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Runtime 运行时实例,独立持有树注册器,类加载器,反射代理缓存池,线程池和时间轮池,用于在同一进程中隔离多个房间或测试用例.
+// 通过 Runtime.NewBrain 创建的 Brain 只使用所属 Runtime 的资源.
+// 日志,脚本引擎池以及 WithValidateOnLoad 等开关仍是进程级的,须先调用 InitSystem.黑板严格模式可由 WithRuntimeStrictBlackboard 按实例设置.
+//
+//	DefaultRuntime 为全局资源的默认实例,与 NewBrain 等原有接口等价
+type Runtime struct {
+	classLoader *ClassLoader
+	handlerPool *handle.HandlerPool
+	registry    *TreeRegistry
+	threadPool  *ants.PoolWithID
+	ownPool     bool                 // 线程池是否由 Runtime 创建,是则 Close 时释放
+	wheels      *timer.TimeWheelPool // 时间轮池,为空则未使用真实时钟
+	clock       timer.Clock
+	strict      *bool // 黑板严格模式,为空则使用 WithStrictBlackboard 的全局设置
+	closeOnce   sync.Once
+}
+
+var defaultRuntime = &Runtime{}
+
+// DefaultRuntime 使用全局资源的默认实例
+//
+//	@return *Runtime
+func DefaultRuntime() *Runtime {
+	return defaultRuntime
+}
+
+type runtimeOption struct {
+	threadPool    *ants.PoolWithID
+	timerPoolSize int
+	timerInterval time.Duration
+	timerNumSlots int
+	clock         timer.Clock
+	customNodes   []bcore.INode
+	strict        *bool
+}
+
+// RuntimeOption Runtime 的可选配置
+type RuntimeOption func(o *runtimeOption)
+
+// WithRuntimeThreadPool 使用外部线程池,Close 时不会释放.为空则创建私有线程池
+//
+//	@param pool
+//	@return RuntimeOption
+func WithRuntimeThreadPool(pool *ants.PoolWithID) RuntimeOption {
+	return func(o *runtimeOption) {
+		o.threadPool = pool
+	}
+}
+
+// WithRuntimeTimerPool 私有时间轮池参数,同 InitSystem 的 WithTimerPoolSize,WithTimerInterval,WithTimerNumSlots
+//
+//	@param size 池子容量
+//	@param interval 时间轮帧间隔
+//	@param numSlots 时间槽数量
+//	@return RuntimeOption
+func WithRuntimeTimerPool(size int, interval time.Duration, numSlots int) RuntimeOption {
+	return func(o *runtimeOption) {
+		o.timerPoolSize = size
+		o.timerInterval = interval
+		o.timerNumSlots = numSlots
+	}
+}
+
+// WithRuntimeClock 设置时钟,如 timer.ManualClock.设置后不再创建时间轮池
+//
+//	@param clock
+//	@return RuntimeOption
+func WithRuntimeClock(clock timer.Clock) RuntimeOption {
+	return func(o *runtimeOption) {
+		o.clock = clock
+	}
+}
+
+// WithRuntimeCustomNodes 注册自定义节点类
+//
+//	@param nodes
+//	@return RuntimeOption
+func WithRuntimeCustomNodes(nodes ...bcore.INode) RuntimeOption {
+	return func(o *runtimeOption) {
+		o.customNodes = append(o.customNodes, nodes...)
+	}
+}
+
+// WithRuntimeStrictBlackboard 设置该实例的黑板严格模式,参看 WithStrictBlackboard.不设置则使用全局设置
+//
+//	@param strict
+//	@return RuntimeOption
+func WithRuntimeStrictBlackboard(strict bool) RuntimeOption {
+	return func(o *runtimeOption) {
+		o.strict = &strict
+	}
+}
+
+// NewRuntime 实例化运行时,内置节点类已注册.用完须调用 Runtime.Close 释放私有的线程池和时间轮池
+//
+//	@param opts
+//	@return *Runtime
+//	@return error
+func NewRuntime(opts ...RuntimeOption) (*Runtime, error) {
+	o := &runtimeOption{
+		timerPoolSize: 1,
+		timerInterval: 10 * time.Millisecond,
+		timerNumSlots: 100,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	rt := &Runtime{
+		classLoader: NewClassLoader(),
+		handlerPool: handle.NewHandlerPool(),
+		registry:    NewTreeRegistry(),
+		threadPool:  o.threadPool,
+		clock:       o.clock,
+		strict:      o.strict,
+	}
+	rt.registry.classLoader = rt.classLoader
+	rt.registry.handlerPool = rt.handlerPool
+	registerBuiltinNodes(rt.classLoader)
+	for _, class := range o.customNodes {
+		rt.classLoader.Register(class)
+	}
+	if rt.threadPool == nil {
+		pool, err := ants.NewPoolWithID(
+			ants.DefaultAntsPoolSize,
+			ants.WithTaskBuffer(thread.DefaultTaskBuffer),
+			ants.WithExpiryDuration(time.Hour),
+			ants.WithDisablePurgeRunning(false))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rt.threadPool = pool
+		rt.ownPool = true
+	}
+	if rt.clock == nil {
+		rt.wheels = timer.NewTimeWheelPool(o.timerPoolSize, o.timerInterval, o.timerNumSlots)
+		rt.wheels.Start()
+		rt.clock = timer.NewWheelClock(rt.wheels)
+	}
+	return rt, nil
+}
+
+// Close 停止私有的时间轮池并释放私有的线程池,之后该 Runtime 的 Brain 均不可再运行.默认实例不可关闭
+//
+//	@receiver rt
+func (rt *Runtime) Close() {
+	if rt == defaultRuntime {
+		logger.Log.Warn("default runtime can not be closed")
+		return
+	}
+	rt.closeOnce.Do(func() {
+		if rt.wheels != nil {
+			rt.wheels.Stop()
+		}
+		if rt.ownPool {
+			rt.threadPool.Release()
+		}
+	})
+}
+
+// ClassLoader 类加载器
+//
+//	@receiver rt
+//	@return *ClassLoader
+func (rt *Runtime) ClassLoader() *ClassLoader {
+	if rt.classLoader != nil {
+		return rt.classLoader
+	}
+	return GlobalClassLoader()
+}
+
+// HandlerPool 反射代理缓存池
+//
+//	@receiver rt
+//	@return *handle.HandlerPool
+func (rt *Runtime) HandlerPool() *handle.HandlerPool {
+	if rt.handlerPool != nil {
+		return rt.handlerPool
+	}
+	return GlobalHandlerPool()
+}
+
+// TreeRegistry 树注册器
+//
+//	@receiver rt
+//	@return *TreeRegistry
+func (rt *Runtime) TreeRegistry() *TreeRegistry {
+	if rt.registry != nil {
+		return rt.registry
+	}
+	return GlobalTreeRegistry()
+}
+
+// ThreadPool 线程池
+//
+//	@receiver rt
+//	@return *ants.PoolWithID
+func (rt *Runtime) ThreadPool() *ants.PoolWithID {
+	if rt.threadPool != nil {
+		return rt.threadPool
+	}
+	return thread.PoolInstance()
+}
+
+// Clock 时钟
+//
+//	@receiver rt
+//	@return timer.Clock
+func (rt *Runtime) Clock() timer.Clock {
+	if rt.clock != nil {
+		return rt.clock
+	}
+	return timer.GlobalClock()
+}
+
+// StrictBlackboard 是否黑板严格模式
+//
+//	@receiver rt
+//	@return bool
+func (rt *Runtime) StrictBlackboard() bool {
+	if rt.strict != nil {
+		return *rt.strict
+	}
+	return internal.GlobalConfig.StrictBlackboard
+}
+
+// RegisterDelegatorType 注册代理类的反射信息
+//
+//	@receiver rt
+//	@param name
+//	@param target
+//	@return error
+func (rt *Runtime) RegisterDelegatorType(name string, target any) error {
+	return rt.HandlerPool().Register(name, target)
+}
+
+// Validate 使用该实例的注册器静态检查树配置,参看 TreeRegistry.Validate
+//
+//	@receiver rt
+//	@param cfgs
+//	@return []Diagnostic
+func (rt *Runtime) Validate(cfgs ...*config.TreeCfg) []Diagnostic {
+	return rt.TreeRegistry().Validate(cfgs...)
+}
+
+// NewBrain 实例化使用该 Runtime 资源的 Brain,参看 NewBrain
+//
+//	@receiver rt
+//	@param blackboard
+//	@param delegates
+//	@param finishChan
+//	@return *Brain
+func (rt *Runtime) NewBrain(blackboard bcore.IBlackboard, delegates map[string]any, finishChan chan *bcore.FinishEvent) *Brain {
+	b := NewBrain(blackboard, delegates, finishChan).(*Brain)
+	b.runtime = rt
+	if rt != defaultRuntime {
+		b.blackboard.SetExecutor(&poolExecutor{pool: rt.ThreadPool(), id: b.blackboard.ThreadID()})
+	}
+	return b
+}
+
+// NewTickBrain 实例化使用该 Runtime 注册器和代理的同步帧驱动模式 Brain,参看 NewTickBrain
+//
+//	@receiver rt
+//	@param blackboard
+//	@param delegates
+//	@param finishChan
+//	@return *Brain
+func (rt *Runtime) NewTickBrain(blackboard bcore.IBlackboard, delegates map[string]any, finishChan chan *bcore.FinishEvent) *Brain {
+	b := NewTickBrain(blackboard, delegates, finishChan)
+	b.runtime = rt
+	return b
+}
+
+// goByID 派发到指定线程
+//
+//	@receiver rt
+//	@param id
+//	@param task
+func (rt *Runtime) goByID(id int, task func()) {
+	if rt.threadPool == nil || id <= 0 {
+		thread.GoByID(id, task)
+		return
+	}
+	err := rt.threadPool.Submit(id, task)
+	if err != nil {
+		logger.Log.Error("submit goroutine with id error", zap.Error(err), zap.Int("goID", id))
+	}
+}
+
+// waitByID 派发到指定线程并等待执行完成
+//
+//	@receiver rt
+//	@param id
+//	@param task
+func (rt *Runtime) waitByID(id int, task func()) {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	rt.goByID(id, func() {
+		task()
+		wg.Done()
+	})
+	wg.Wait()
+}
+
+var _ bcore.Executor = (*poolExecutor)(nil)
+
+// poolExecutor 派发到 Runtime 私有线程池的执行器
+type poolExecutor struct {
+	pool *ants.PoolWithID
+	id   int
+}
+
+// Go
+//
+//	@implement bcore.Executor .Go
+//	@receiver e
+//	@param task
+func (e *poolExecutor) Go(task func()) {
+	err := e.pool.Submit(e.id, task)
+	if err != nil {
+		logger.Log.Error("submit goroutine with id error", zap.Error(err), zap.Int("goID", e.id))
+	}
+}
@@ -0,0 +1,108 @@
+package behavior
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/timer"
+)
+
+func TestRuntime_Isolation(t *testing.T) {
+	help()
+	tree := func(waitTime string) []byte {
+		return []byte(`{"root":"rt-root","tag":"rt_main","nodes":{
+"rt-root":{"id":"rt-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["rt-seq"]},
+"rt-seq":{"id":"rt-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["rt-wait","rt-count"]},
+"rt-wait":{"id":"rt-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"` + waitTime + `"}},
+"rt-count":{"id":"rt-count","name":"Action","category":"task","title":"count","properties":{},"delegator":{"target":"counter","method":"Count"}}
+}}`)
+	}
+	run := func(rt *Runtime, threadID int, counter *b3Counter) *bcore.FinishEvent {
+		fch := make(chan *bcore.FinishEvent, 1)
+		brain := rt.NewBrain(bcore.NewBlackboard(threadID, nil), map[string]any{"counter": counter}, fch)
+		if err := brain.Run("rt_main", false); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-fch:
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("tree not finished")
+		}
+		return nil
+	}
+	rt1, err := NewRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt1.Close()
+	rt2, err := NewRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt2.Close()
+	if err = rt1.RegisterDelegatorType("counter", &b3Counter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = rt1.TreeRegistry().LoadFromJson(tree("20ms")); err != nil {
+		t.Fatal(err)
+	}
+	if err = rt2.TreeRegistry().LoadFromJson(tree("50ms")); err != nil {
+		t.Fatal(err)
+	}
+	if err = rt1.TreeRegistry().MountAll(); err != nil {
+		t.Fatal(err)
+	}
+	if GlobalTreeRegistry().GetNotParentTreeWithoutClone("rt_main") != nil {
+		t.Fatal("runtime tree leaked into global registry")
+	}
+	if GlobalHandlerPool().ContainsTarget("counter") || rt2.HandlerPool().ContainsTarget("counter") {
+		t.Fatal("runtime delegator leaked into other handler pools")
+	}
+	counter := &b3Counter{}
+	if event := run(rt1, 1101, counter); !event.Succeeded || counter.n != 1 {
+		t.Fatalf("runtime1 event = %+v, count = %d", event, counter.n)
+	}
+	// rt2 未注册 counter,委托不会被调用
+	counter = &b3Counter{}
+	if event := run(rt2, 1101, counter); counter.n != 0 {
+		t.Fatalf("runtime2 event = %+v, count = %d", event, counter.n)
+	}
+}
+
+func TestRuntime_StrictBlackboard(t *testing.T) {
+	help()
+	content := []byte(`{"root":"rs-root","tag":"rt_strict","blackboard":{"hp":{"type":"int","default":100}},"nodes":{
+"rs-root":{"id":"rs-root","name":"Root","category":"decorator","title":"Root","properties":{"loopInterval":"1h"},"children":["rs-wait"]},
+"rs-wait":{"id":"rs-wait","name":"Wait","category":"task","title":"wait","properties":{"forever":true}}
+}}`)
+	set := func(strict bool) any {
+		rt, err := NewRuntime(WithRuntimeClock(timer.NewManualClock(time.Time{})), WithRuntimeStrictBlackboard(strict))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rt.Close()
+		if rt.StrictBlackboard() != strict {
+			t.Fatalf("StrictBlackboard() = %v, want %v", rt.StrictBlackboard(), strict)
+		}
+		if err = rt.TreeRegistry().LoadFromJson(content); err != nil {
+			t.Fatal(err)
+		}
+		bb := bcore.NewBlackboard(1102, nil)
+		brain := rt.NewTickBrain(bb, nil, nil)
+		if err = brain.Run("rt_strict", false); err != nil {
+			t.Fatal(err)
+		}
+		brain.Tick(0)
+		bb.Set("hp", "full")
+		v, _ := bb.Get("hp")
+		return v
+	}
+	if v := set(true); v == "full" {
+		t.Fatal("strict runtime accepted mismatched type")
+	}
+	if v := set(false); v != "full" {
+		t.Fatalf("non-strict runtime rejected write, hp = %v", v)
+	}
+}
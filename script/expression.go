@@ -0,0 +1,114 @@
+package script
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"github.com/pkg/errors"
+)
+
+// Expression 编译后的表达式,记录了表达式引用的外部变量,常用于以黑板键为变量的条件表达式
+//
+//	如 `hp < maxHp * 0.3 && target != ""` 引用的变量为 hp,maxHp,target.未赋值的变量为nil
+type Expression struct {
+	code    string
+	program *vm.Program
+	vars    []string
+}
+
+// CompileExpression 编译表达式并收集引用的外部变量.let声明的变量和注册的公共API不算外部变量
+//
+//	@param code
+//	@return *Expression
+//	@return error
+func CompileExpression(code string) (*Expression, error) {
+	collector := &varCollector{idents: map[string]struct{}{}, declared: map[string]struct{}{}}
+	program, err := expr.Compile(code, expr.Patch(collector))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mutex.RLock()
+	vars := make([]string, 0, len(collector.idents))
+	for name := range collector.idents {
+		_, declared := collector.declared[name]
+		_, isApi := apiLib[name]
+		if !declared && !isApi {
+			vars = append(vars, name)
+		}
+	}
+	mutex.RUnlock()
+	sort.Strings(vars)
+	return &Expression{code: code, program: program, vars: vars}, nil
+}
+
+// Code 源码
+//
+//	@receiver e
+//	@return string
+func (e *Expression) Code() string {
+	return e.code
+}
+
+// Vars 引用的外部变量,已排序
+//
+//	@receiver e
+//	@return []string
+func (e *Expression) Vars() []string {
+	return e.vars
+}
+
+// Eval 求值,变量由lookup提供,公共API自动注入
+//
+//	@receiver e
+//	@param lookup 根据变量名获取值,一般是黑板的Get
+//	@return any
+//	@return error
+func (e *Expression) Eval(lookup func(name string) (any, bool)) (any, error) {
+	env := make(map[string]any, len(e.vars))
+	mutex.RLock()
+	for k, v := range apiLib {
+		env[k] = v
+	}
+	mutex.RUnlock()
+	for _, name := range e.vars {
+		v, _ := lookup(name)
+		env[name] = v
+	}
+	return Run(e.program, env)
+}
+
+// EvalBool 求值并要求结果为bool
+//
+//	@receiver e
+//	@param lookup
+//	@return bool
+//	@return error
+func (e *Expression) EvalBool(lookup func(name string) (any, bool)) (bool, error) {
+	out, err := e.Eval(lookup)
+	if err != nil {
+		return false, err
+	}
+	ret, ok := out.(bool)
+	if !ok {
+		return false, errors.New(fmt.Sprintf("expression result is not bool:%v", out))
+	}
+	return ret, nil
+}
+
+// varCollector 编译时收集标识符
+type varCollector struct {
+	idents   map[string]struct{}
+	declared map[string]struct{}
+}
+
+func (c *varCollector) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		c.idents[n.Value] = struct{}{}
+	case *ast.VariableDeclaratorNode:
+		c.declared[n.Name] = struct{}{}
+	}
+}
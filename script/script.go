@@ -1,49 +1,46 @@
+// Package script 内嵌的脚本引擎,供节点在没有委托方法时执行配置里的脚本
+//
+//	脚本语法为表达式语言 https://expr-lang.org ,支持算术、比较、逻辑、字符串、三元运算、nil判断、
+//	let变量和以";"分隔的多条表达式(最后一条表达式的值即返回值),可调用注册的公共API和环境变量中对象的方法.
+//	注意被调用的函数或方法必须有且只有一个返回值(或返回值+error).
+//	脚本在加载树时编译,编译后的程序是线程安全的,可在多个AI线程中并发执行.
 package script
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 	"github.com/pkg/errors"
 
 	"github.com/alkaid/behavior/logger"
 	"go.uber.org/zap"
 )
 
-// var pool *engine.GenginePool    // 引擎池单例
-var codes = map[string]string{} // 代码,索引为nodeID
-var apiLib = map[string]any{}   // 公共api库
+var mutex sync.RWMutex
+var codes = map[string]string{}         // 代码,索引为nodeID
+var programs = map[string]*vm.Program{} // 编译后的代码,索引为nodeID
+var apiLib = map[string]any{}           // 公共api库
+var vmPool = sync.Pool{New: func() any { return &vm.VM{} }}
 
-// InitPool 初始化规则引擎池
+// InitPool 初始化脚本引擎
 //
-//	@param poolMinLen 最小容量
-//	@param poolMaxLen 最大容量
+//	@param poolMinLen 保留参数,虚拟机由 sync.Pool 按需复用,不再需要指定容量
+//	@param poolMaxLen 保留参数,同上
 //	@param api 注入的API
 //	@return error
 func InitPool(poolMinLen, poolMaxLen int, api map[string]any) error {
-	// 	if pool != nil {
-	// 		logger.Log.Warn("init goroutine pool duplicate")
-	// 		return nil
-	// 	}
-	// 	RegisterApi(api)
-	// 	var err error
-	// 	// 实例化pool时脚本不能为空
-	// 	codeWrap := fmt.Sprintf(`
-	// rule "%s"
-	// begin
-	// 	%s
-	// end
-	// 	`, "donothing", "")
-	// 	pool, err = engine.NewGenginePool(int64(poolMinLen), int64(poolMaxLen), engine.SortModel, codeWrap, apiLib)
-	// 	if err != nil {
-	// 		return errors.WithStack(err)
-	// 	}
+	RegisterApi(api)
 	return nil
 }
 
-// RegisterApi 向引擎池注册公共API
+// RegisterApi 向引擎注册公共API,脚本中可直接按名称访问
 //
 //	@param api
 func RegisterApi(api map[string]any) {
+	mutex.Lock()
+	defer mutex.Unlock()
 	for name, f := range api {
 		_, ok := apiLib[name]
 		if ok {
@@ -54,49 +51,114 @@ func RegisterApi(api map[string]any) {
 	}
 }
 
-// RegisterCode 注册代码
+// Compile 编译代码,不注册
+//
+//	@param code
+//	@return *vm.Program
+//	@return error
+func Compile(code string) (*vm.Program, error) {
+	program, err := expr.Compile(code)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return program, nil
+}
+
+// RegisterCode 编译并注册代码
 //
 //	@param name 规则名称 一般是nodeID
 //	@param code 代码
-func RegisterCode(name string, code string) {
-	codeWrap := fmt.Sprintf(`
-rule "%s"
-begin
-	%s
-end
-	`, name, code)
-	codes[name] = codeWrap
+//	@return error 编译错误
+func RegisterCode(name string, code string) error {
+	program, err := Compile(code)
+	if err != nil {
+		return errors.WithMessagef(err, "compile script failed,name=%s", name)
+	}
+	mutex.Lock()
+	codes[name] = code
+	programs[name] = program
+	mutex.Unlock()
+	return nil
 }
 
-// UpdateCode 全量更新池子里的代码
+// UnregisterCode 注销代码,节点所在的树移除后调用,未注册时忽略
+//
+//	@param name
+func UnregisterCode(name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(codes, name)
+	delete(programs, name)
+}
+
+// UpdateCode 全量重新编译已注册的代码
 //
 //	@return error
 func UpdateCode() error {
-	// all := ""
-	// for _, code := range codes {
-	// 	all += code
-	// }
-	// err := pool.UpdatePooledRules(all)
-	// if err != nil {
-	// 	return errors.WithStack(err)
-	// }
+	mutex.RLock()
+	all := make(map[string]string, len(codes))
+	for name, code := range codes {
+		all[name] = code
+	}
+	mutex.RUnlock()
+	for name, code := range all {
+		err := RegisterCode(name, code)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// ExistsCode 代码是否已注册
+//
+//	@param name
+//	@return bool
 func ExistsCode(name string) bool {
-	// return pool.IsExist([]string{name})[0]
-	return false
+	mutex.RLock()
+	defer mutex.RUnlock()
+	_, ok := programs[name]
+	return ok
 }
 
 // RunCode 执行代码
 //
 //	@param name
-//	@param env
+//	@param env 环境变量,与公共API同名时优先使用环境变量
+//	@return out 最后一条表达式的值
+//	@return err
 func RunCode(name string, env map[string]any) (out any, err error) {
-	// err, rets := pool.ExecuteSelectedRules(env, []string{name})
-	// if err != nil {
-	// 	return nil, errors.WithStack(err)
-	// }
-	// return rets[name], nil
-	err = errors.New("UnSupport because not compile with glibc now...")
-	return nil, err
+	if env == nil {
+		env = map[string]any{}
+	}
+	mutex.RLock()
+	program, ok := programs[name]
+	if ok {
+		for k, v := range apiLib {
+			if _, exists := env[k]; !exists {
+				env[k] = v
+			}
+		}
+	}
+	mutex.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("script not found,name=%s", name))
+	}
+	return Run(program, env)
+}
+
+// Run 执行编译后的代码,不会注入公共API
+//
+//	@param program
+//	@param env
+//	@return out
+//	@return err
+func Run(program *vm.Program, env map[string]any) (out any, err error) {
+	machine := vmPool.Get().(*vm.VM)
+	defer vmPool.Put(machine)
+	out, err = machine.Run(program, env)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
 }
@@ -0,0 +1,92 @@
+package script
+
+import (
+	"testing"
+)
+
+type npc struct {
+	hp int
+}
+
+func (n *npc) Hurt(damage int) int {
+	n.hp -= damage
+	return n.hp
+}
+
+func TestRunCode(t *testing.T) {
+	RegisterApi(map[string]any{
+		"max": func(a, b int) int {
+			if a > b {
+				return a
+			}
+			return b
+		},
+		"succeeded": 1,
+	})
+	tests := []struct {
+		name    string
+		code    string
+		env     map[string]any
+		want    any
+		wantErr bool
+	}{
+		{"condition", `hp < maxHp * 0.3 && target != "" && missing == nil`, map[string]any{"hp": 20, "maxHp": 100, "target": "enemy"}, true, false},
+		{"api", `max(hp, 5) + 1`, map[string]any{"hp": 3}, 6, false},
+		{"envOverridesApi", `succeeded`, map[string]any{"succeeded": 2}, 2, false},
+		{"delegate", `let left = self.Hurt(30); left > 0 ? succeeded : 0`, map[string]any{"self": &npc{hp: 100}}, 1, false},
+		{"runtimeError", `self.Hurt("x")`, map[string]any{"self": &npc{hp: 100}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RegisterCode(tt.name, tt.code); err != nil {
+				t.Fatal(err)
+			}
+			got, err := RunCode(tt.name, tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("RunCode() = %v(%T), want %v(%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+	if err := RegisterCode("syntaxError", `hp <`); err == nil {
+		t.Fatal("want compile error")
+	}
+	if ExistsCode("syntaxError") {
+		t.Fatal("code with compile error should not be registered")
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	RegisterApi(map[string]any{"clamp": func(v, lo, hi float64) float64 { return max(lo, min(v, hi)) }})
+	e, err := CompileExpression(`let ratio = hp / maxHp; clamp(ratio, 0.0, 1.0) < 0.3 && target != "" && buff == nil`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"buff", "hp", "maxHp", "target"}
+	if len(e.Vars()) != len(want) {
+		t.Fatalf("Vars() = %v, want %v", e.Vars(), want)
+	}
+	for i := range want {
+		if e.Vars()[i] != want[i] {
+			t.Fatalf("Vars() = %v, want %v", e.Vars(), want)
+		}
+	}
+	bb := map[string]any{"hp": 20, "maxHp": 100, "target": "enemy"}
+	lookup := func(name string) (any, bool) {
+		v, ok := bb[name]
+		return v, ok
+	}
+	if ok, err := e.EvalBool(lookup); err != nil || !ok {
+		t.Fatalf("EvalBool() = %v, %v, want true", ok, err)
+	}
+	bb["target"] = ""
+	if ok, err := e.EvalBool(lookup); err != nil || ok {
+		t.Fatalf("EvalBool() = %v, %v, want false", ok, err)
+	}
+	e, _ = CompileExpression(`hp + 1`)
+	if _, err = e.EvalBool(lookup); err == nil {
+		t.Fatal("want error for non-bool result")
+	}
+}
@@ -0,0 +1,501 @@
+package behavior
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/task"
+	"github.com/alkaid/behavior/timer"
+)
+
+// ErrSnapshotVerMismatch 快照中树的版本与当前加载的版本不一致
+var ErrSnapshotVerMismatch = errors.New("snapshot tree ver mismatch")
+
+// BrainSnapshot Brain 快照
+//
+//	节点ID在克隆树中是随机生成的,故节点数据以节点路径为索引:从主树root开始,按子节点索引以"/"连接,如"0/1/0",
+//	动态子树容器挂载的子树视为容器的第0个子节点.
+//	用户域数据以JSON序列化,恢复后数字类型将变为float64,自定义结构体将变为map
+type BrainSnapshot struct {
+	ThreadID   int                      `json:"threadID"`         // IBrain.ID
+	Tag        string                   `json:"tag"`              // 主树tag,为空表示快照时没有正在运行的树
+	Ver        string                   `json:"ver"`              // 主树版本
+	Params     map[string]any           `json:"params,omitempty"` // 主树的模板参数
+	Nodes      map[string]*NodeSnapshot `json:"nodes"`            // 节点数据,索引为节点路径
+	UserMemory bcore.Memory             `json:"userMemory"`       // 用户域数据
+}
+
+// NodeSnapshot 节点快照,对应 bcore.NodeMemory 中可序列化的部分
+type NodeSnapshot struct {
+	Tag                   string            `json:"tag,omitempty"`                   // 仅root有效:所属树tag
+	Ver                   string            `json:"ver,omitempty"`                   // 仅root有效:所属树版本
+	TreeMemory            bcore.Memory      `json:"treeMemory,omitempty"`            // 仅root有效:树数据
+	DynamicChild          string            `json:"dynamicChild,omitempty"`          // 仅动态子树容器有效:已挂载子树的tag
+	RequestDynamicChild   string            `json:"requestDynamicChild,omitempty"`   // 仅动态子树容器有效:请求挂载子树的tag
+	DynamicParams         map[string]any    `json:"dynamicParams,omitempty"`         // 仅动态子树容器有效:已挂载子树的模板参数
+	RequestDynamicParams  map[string]any    `json:"requestDynamicParams,omitempty"`  // 仅动态子树容器有效:请求挂载子树的模板参数
+	RequestDynamicUnmount bool              `json:"requestDynamicUnmount,omitempty"` // 仅动态子树容器有效:是否请求卸载子树
+	State                 bcore.NodeState   `json:"state,omitempty"`
+	Observing             bool              `json:"observing,omitempty"`
+	CurrIndex             int               `json:"currIndex,omitempty"`
+	ChildrenOrder         []int             `json:"childrenOrder,omitempty"`
+	Parallel              *ParallelSnapshot `json:"parallel,omitempty"`
+	Cooling               bool              `json:"cooling,omitempty"`
+	LimitReached          bool              `json:"limitReached,omitempty"`
+	DecoratedDone         bool              `json:"decoratedDone,omitempty"`
+	DecoratedSuccess      bool              `json:"decoratedSuccess,omitempty"`
+	Elapsed               time.Duration     `json:"elapsed,omitempty"`
+	Restarting            bool              `json:"restarting,omitempty"`
+	TimerRemaining        *time.Duration    `json:"timerRemaining,omitempty"`   // 定时任务剩余时间,为空表示没有定时任务
+	TimeoutRemaining      *time.Duration    `json:"timeoutRemaining,omitempty"` // 超时定时任务剩余时间,为空表示没有超时定时任务
+	Ext                   bcore.Memory      `json:"ext,omitempty"`
+}
+
+// ParallelSnapshot 并发节点快照, ChildrenSucceeded 以子节点索引代替子节点ID
+type ParallelSnapshot struct {
+	RunningCount      int          `json:"runningCount"`
+	SucceededCount    int          `json:"succeededCount"`
+	FailedCount       int          `json:"failedCount"`
+	ChildrenSucceeded map[int]bool `json:"childrenSucceeded"`
+	Succeeded         bool         `json:"succeeded"`
+	ChildrenAborted   bool         `json:"childrenAborted"`
+}
+
+// Snapshot 生成快照,包括节点状态和运行索引、剩余的冷却/等待时间、并发计数、动态子树挂载和用户域数据
+//
+//	线程安全,会派发到 Brain 的线程执行并等待完成,故不能在树的线程内(如委托方法中)调用.同步帧驱动模式下须在 Tick 的协程调用
+//
+// @receiver b
+// @return []byte
+// @return error
+func (b *Brain) Snapshot() ([]byte, error) {
+	var snap *BrainSnapshot
+	var err error
+	b.wait(func() {
+		snap, err = b.snapshot()
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+func (b *Brain) snapshot() (*BrainSnapshot, error) {
+	snap := &BrainSnapshot{
+		ThreadID:   b.ID(),
+		Nodes:      map[string]*NodeSnapshot{},
+		UserMemory: b.blackboard.UserMemory(),
+	}
+	if !b.Running() {
+		return snap, nil
+	}
+	registry := b.Runtime().TreeRegistry()
+	maintree := registry.TreeByID(b.RunningTree().ID())
+	if maintree == nil {
+		return nil, errors.New(fmt.Sprintf("brain can not snapshot cause not main tree,rootID=%s", b.RunningTree().ID()))
+	}
+	snap.Tag = maintree.Tag
+	snap.Ver = maintree.Ver
+	snap.Params = maintree.Params
+	now := b.Now()
+	err := b.walkNodes(maintree.Root, "", func(node bcore.INode, path string) error {
+		mem := node.Memory(b)
+		ns := &NodeSnapshot{
+			State:            mem.State,
+			Observing:        mem.Observing,
+			CurrIndex:        mem.CurrIndex,
+			ChildrenOrder:    mem.ChildrenOrder,
+			Cooling:          mem.Cooling,
+			LimitReached:     mem.LimitReached,
+			DecoratedDone:    mem.DecoratedDone,
+			DecoratedSuccess: mem.DecoratedSuccess,
+			Elapsed:          mem.Elapsed,
+			Restarting:       mem.Restarting,
+		}
+		if len(mem.Ext) > 0 {
+			ns.Ext = mem.Ext
+		}
+		var err error
+		if ns.TimerRemaining, err = timerRemaining(mem.CronTask, now, path); err != nil {
+			return err
+		}
+		if ns.TimeoutRemaining, err = timerRemaining(mem.TimeoutTask, now, path); err != nil {
+			return err
+		}
+		if mem.Parallel != nil {
+			ns.Parallel = &ParallelSnapshot{
+				RunningCount:      mem.Parallel.RunningCount,
+				SucceededCount:    mem.Parallel.SucceededCount,
+				FailedCount:       mem.Parallel.FailedCount,
+				ChildrenSucceeded: map[int]bool{},
+				Succeeded:         mem.Parallel.Succeeded,
+				ChildrenAborted:   mem.Parallel.ChildrenAborted,
+			}
+			for i, child := range node.(bcore.IComposite).Children() {
+				if succeeded, ok := mem.Parallel.ChildrenSucceeded[child.ID()]; ok {
+					ns.Parallel.ChildrenSucceeded[i] = succeeded
+				}
+			}
+		}
+		if node.Name() == bcore.NodeNameRoot {
+			tree := registry.TreeByID(node.ID())
+			if tree == nil {
+				return errors.New(fmt.Sprintf("brain can not snapshot cause tree not registered,rootID=%s,path=%s", node.ID(), path))
+			}
+			ns.Tag = tree.Tag
+			ns.Ver = tree.Ver
+			if treeMem := b.blackboard.TreeMemory(node.ID()); len(treeMem) > 0 {
+				ns.TreeMemory = treeMem
+			}
+		}
+		if _, ok := node.(task.IDynamicSubtree); ok {
+			if mem.DynamicChild != nil {
+				subtree := registry.TreeByID(mem.DynamicChild.ID())
+				if subtree == nil {
+					return errors.New(fmt.Sprintf("brain can not snapshot cause dynamic subtree not registered,rootID=%s,path=%s", mem.DynamicChild.ID(), path))
+				}
+				ns.DynamicChild, ns.DynamicParams = subtree.Tag, subtree.Params
+			}
+			if mem.RequestDynamicChild != nil {
+				subtree := registry.TreeByID(mem.RequestDynamicChild.ID())
+				if subtree == nil {
+					return errors.New(fmt.Sprintf("brain can not snapshot cause requested dynamic subtree not registered,rootID=%s,path=%s", mem.RequestDynamicChild.ID(), path))
+				}
+				ns.RequestDynamicChild, ns.RequestDynamicParams = subtree.Tag, subtree.Params
+			}
+			ns.RequestDynamicUnmount = mem.RequestDynamicUnmount
+		}
+		snap.Nodes[path] = ns
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// timerRemaining 计算定时任务的剩余时间
+//
+//	@param t
+//	@param now
+//	@param path 节点路径
+//	@return *time.Duration t为空时返回nil
+//	@return error t未记录到期时间
+func timerRemaining(t timer.Timer, now time.Time, path string) (*time.Duration, error) {
+	if t == nil {
+		return nil, nil
+	}
+	dt, ok := t.(*timer.DeadlineTimer)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("brain can not snapshot cause timer deadline unknown,timer=%T,path=%s", t, path))
+	}
+	remaining := max(dt.Deadline.Sub(now), 0)
+	return &remaining, nil
+}
+
+// Restore 从快照恢复,Brain 必须未在运行.恢复后树将从快照时的状态继续运行
+//
+//	快照中所有树的版本必须与当前加载的版本一致,否则返回 ErrSnapshotVerMismatch
+//	线程安全,会派发到 Brain 的线程执行并等待完成,故不能在树的线程内调用.同步帧驱动模式下须在 Tick 的协程调用
+//
+// @receiver b
+// @param data Snapshot 生成的数据
+// @return error
+func (b *Brain) Restore(data []byte) error {
+	snap := &BrainSnapshot{}
+	err := json.Unmarshal(data, snap)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	b.wait(func() {
+		err = b.restore(snap)
+	})
+	return err
+}
+
+// RestoreBrain 根据快照创建 Brain 并恢复运行.黑板使用快照中的线程ID创建且没有父黑板,若需要父黑板请使用 NewBrain 后调用 Brain.Restore
+//
+//	@param data Brain.Snapshot 生成的数据
+//	@param delegates 要注册的委托对象
+//	@return bcore.IBrain
+//	@return error
+func RestoreBrain(data []byte, delegates map[string]any) (bcore.IBrain, error) {
+	snap := &BrainSnapshot{}
+	err := json.Unmarshal(data, snap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b := NewBrain(bcore.NewBlackboard(snap.ThreadID, nil), delegates, nil).(*Brain)
+	b.wait(func() {
+		err = b.restore(snap)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// restore 先校验快照中所有树的tag和版本并解析动态子树,全部通过后才修改 Brain
+//
+//	@receiver b
+//	@param snap
+//	@return error
+func (b *Brain) restore(snap *BrainSnapshot) error {
+	if b.Running() {
+		return errors.New("brain can not restore cause running")
+	}
+	if snap.Tag == "" {
+		b.blackboard.RestoreUserMemory(snap.UserMemory)
+		return nil
+	}
+	tree, err := b.validateSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	return b.applySnapshot(snap, tree)
+}
+
+// validateSnapshot 校验快照:解析主树及快照中挂载和请求挂载的所有动态子树,检查每棵树的tag和版本.
+//
+//	不挂载也不修改 Brain 的任何数据,动态子树只按快照解析后遍历
+//	@receiver b
+//	@param snap
+//	@return *Tree 主树
+//	@return error
+func (b *Brain) validateSnapshot(snap *BrainSnapshot) (*Tree, error) {
+	registry := b.Runtime().TreeRegistry()
+	tree, _, err := registry.getNotParentTree(snap.Tag, snap.Params)
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil || tree.Root == nil {
+		return nil, errors.New(fmt.Sprintf("can not find main tree for tag %s", snap.Tag))
+	}
+	if tree.Ver != snap.Ver {
+		return nil, errors.WithMessagef(ErrSnapshotVerMismatch, "tag=%s,snapshotVer=%s,ver=%s", snap.Tag, snap.Ver, tree.Ver)
+	}
+	decorated := func(decorator bcore.IDecorator, path string) (bcore.INode, error) {
+		container, ok := decorator.(task.IDynamicSubtree)
+		if !ok {
+			return decorator.Decorated(b), nil
+		}
+		ns := snap.Nodes[path]
+		if ns == nil {
+			return nil, nil
+		}
+		if ns.RequestDynamicChild != "" {
+			if _, _, err := registry.getNotDynamicParentTree(ns.RequestDynamicChild, ns.RequestDynamicParams, container, b); err != nil {
+				return nil, err
+			}
+		}
+		if ns.DynamicChild == "" {
+			return nil, nil
+		}
+		subtree, _, err := registry.getNotDynamicParentTree(ns.DynamicChild, ns.DynamicParams, container, b)
+		if err != nil {
+			return nil, err
+		}
+		if subtree == nil {
+			return nil, errors.New(fmt.Sprintf("brain can not restore cause not enough subtree,path=%s,subtreeTag=%s", path, ns.DynamicChild))
+		}
+		return subtree.Root, nil
+	}
+	err = b.walk(tree.Root, "", decorated, func(node bcore.INode, path string) error {
+		ns := snap.Nodes[path]
+		if ns == nil || node.Name() != bcore.NodeNameRoot {
+			return nil
+		}
+		t := registry.TreeByID(node.ID())
+		if t == nil || t.Tag != ns.Tag || t.Ver != ns.Ver {
+			return errors.WithMessagef(ErrSnapshotVerMismatch, "path=%s,tag=%s,snapshotVer=%s", path, ns.Tag, ns.Ver)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// applySnapshot 恢复已校验的快照:用户域数据,黑板声明,动态子树挂载,节点数据,最后重建定时任务和监听
+//
+//	@receiver b
+//	@param snap
+//	@param tree 主树
+//	@return error
+//
+//nolint:gocyclo
+func (b *Brain) applySnapshot(snap *BrainSnapshot, tree *Tree) error {
+	registry := b.Runtime().TreeRegistry()
+	b.blackboard.RestoreUserMemory(snap.UserMemory)
+	b.blackboard.SetSchema(tree.Blackboard, b.Runtime().StrictBlackboard())
+	// 1.重新挂载动态子树,此时所有节点都是非活跃的,动态挂载会立即生效
+	err := b.walkNodes(tree.Root, "", func(node bcore.INode, path string) error {
+		ns := snap.Nodes[path]
+		if ns == nil {
+			return nil
+		}
+		container, ok := node.(task.IDynamicSubtree)
+		if !ok || ns.DynamicChild == "" {
+			return nil
+		}
+		subtree, _, err := registry.getNotDynamicParentTree(ns.DynamicChild, ns.DynamicParams, container, b)
+		if err != nil {
+			return err
+		}
+		if subtree == nil {
+			return errors.New(fmt.Sprintf("brain can not restore cause not enough subtree,path=%s,subtreeTag=%s", path, ns.DynamicChild))
+		}
+		container.DynamicDecorate(b, subtree.Root)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 2.恢复节点数据
+	err = b.walkNodes(tree.Root, "", func(node bcore.INode, path string) error {
+		ns := snap.Nodes[path]
+		if ns == nil {
+			return nil
+		}
+		mem := node.Memory(b)
+		mem.State = ns.State
+		mem.Observing = ns.Observing
+		mem.CurrIndex = ns.CurrIndex
+		mem.ChildrenOrder = ns.ChildrenOrder
+		mem.Cooling = ns.Cooling
+		mem.LimitReached = ns.LimitReached
+		mem.DecoratedDone = ns.DecoratedDone
+		mem.DecoratedSuccess = ns.DecoratedSuccess
+		mem.Elapsed = ns.Elapsed
+		mem.Restarting = ns.Restarting
+		mem.RequestDynamicUnmount = ns.RequestDynamicUnmount
+		if ns.Ext != nil {
+			mem.Ext = ns.Ext
+		}
+		if ns.Parallel != nil {
+			mem.Parallel = &bcore.ParallelMemory{
+				RunningCount:      ns.Parallel.RunningCount,
+				SucceededCount:    ns.Parallel.SucceededCount,
+				FailedCount:       ns.Parallel.FailedCount,
+				ChildrenSucceeded: map[string]bool{},
+				Succeeded:         ns.Parallel.Succeeded,
+				ChildrenAborted:   ns.Parallel.ChildrenAborted,
+			}
+			children := node.(bcore.IComposite).Children()
+			for i, succeeded := range ns.Parallel.ChildrenSucceeded {
+				if i >= 0 && i < len(children) {
+					mem.Parallel.ChildrenSucceeded[children[i].ID()] = succeeded
+				}
+			}
+		}
+		if len(ns.TreeMemory) > 0 {
+			treeMem := b.blackboard.TreeMemory(node.ID())
+			for k, v := range ns.TreeMemory {
+				treeMem[k] = v
+			}
+		}
+		if container, ok := node.(task.IDynamicSubtree); ok && ns.RequestDynamicChild != "" {
+			subtree, _, err := registry.getNotDynamicParentTree(ns.RequestDynamicChild, ns.RequestDynamicParams, container, b)
+			if err != nil {
+				return err
+			}
+			if subtree != nil {
+				mem.RequestDynamicChild = subtree.Root
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !tree.Root.IsActive(b) {
+		return nil
+	}
+	// 3.启动黑板并重建定时任务和监听
+	b.blackboard.Start()
+	b.SetRunningTree(tree.Root)
+	return b.walkNodes(tree.Root, "", func(node bcore.INode, path string) error {
+		remaining := time.Duration(-1)
+		ns := snap.Nodes[path]
+		if ns != nil && ns.TimerRemaining != nil {
+			remaining = *ns.TimerRemaining
+		}
+		mem := node.Memory(b)
+		if ns != nil {
+			mem.TimeoutRemaining = ns.TimeoutRemaining
+		}
+		node.NodeWorker().OnRestore(b, remaining)
+		mem.TimeoutRemaining = nil
+		return nil
+	})
+}
+
+// walkNodes 以先序遍历 Brain 当前挂载的整棵树(包括静态和动态子树),先访问节点再读取其子节点,故访问时挂载的动态子树也会被遍历
+//
+//	@receiver b
+//	@param node
+//	@param path
+//	@param visit
+//	@return error
+func (b *Brain) walkNodes(node bcore.INode, path string, visit func(node bcore.INode, path string) error) error {
+	return b.walk(node, path, func(decorator bcore.IDecorator, _ string) (bcore.INode, error) {
+		return decorator.Decorated(b), nil
+	}, visit)
+}
+
+// walk 以先序遍历树,装饰节点的子节点由 decorated 给出,可用于遍历尚未挂载的动态子树
+//
+//	@receiver b
+//	@param node
+//	@param path
+//	@param decorated 返回装饰节点的子节点,为空则不再向下遍历
+//	@param visit
+//	@return error
+func (b *Brain) walk(node bcore.INode, path string, decorated func(decorator bcore.IDecorator, path string) (bcore.INode, error), visit func(node bcore.INode, path string) error) error {
+	err := visit(node, path)
+	if err != nil {
+		return err
+	}
+	prefix := lo.If(path == "", "").Else(path + "/")
+	switch v := node.(type) {
+	case bcore.IComposite:
+		for i, child := range v.Children() {
+			err = b.walk(child, prefix+strconv.Itoa(i), decorated, visit)
+			if err != nil {
+				return err
+			}
+		}
+	case bcore.IDecorator:
+		child, err := decorated(v, path)
+		if err != nil {
+			return err
+		}
+		if child != nil {
+			return b.walk(child, prefix+"0", decorated, visit)
+		}
+	}
+	return nil
+}
+
+// wait 在 Brain 的线程执行任务并等待完成.同步帧驱动模式下直接在当前协程执行
+//
+//	@receiver b
+//	@param task
+func (b *Brain) wait(task func()) {
+	if b.tick != nil {
+		task()
+		return
+	}
+	b.Runtime().waitByID(b.ID(), task)
+}
@@ -0,0 +1,141 @@
+package behavior
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+)
+
+func TestBrain_SnapshotRestore(t *testing.T) {
+	help()
+	content := `
+{"root":"ss-root","tag":"test_snapshot","ver":"1","nodes":{
+"ss-root":{"id":"ss-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["ss-seq"]},
+"ss-seq":{"id":"ss-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["ss-wait","ss-action"]},
+"ss-wait":{"id":"ss-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":"30s"}},
+"ss-action":{"id":"ss-action","name":"Action","category":"task","title":"Action","properties":{}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	brain := NewTickBrain(bcore.NewBlackboard(1003, nil), nil, make(chan *bcore.FinishEvent, 1))
+	if err := brain.Run("test_snapshot", false); err != nil {
+		t.Fatal(err)
+	}
+	brain.Blackboard().Set("hp", 3)
+	brain.Tick(10 * time.Second)
+	data, err := brain.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fch := make(chan *bcore.FinishEvent, 1)
+	restored := NewTickBrain(bcore.NewBlackboard(1004, nil), nil, fch)
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Running() {
+		t.Fatal("restored brain not running")
+	}
+	if hp, _ := restored.Blackboard().Get("hp"); hp != float64(3) {
+		t.Fatalf("hp = %v, want 3", hp)
+	}
+	restored.Tick(19 * time.Second)
+	select {
+	case <-fch:
+		t.Fatal("tree finished before remaining wait elapsed")
+	default:
+	}
+	restored.Tick(time.Second)
+	select {
+	case event := <-fch:
+		if !event.Succeeded {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished after remaining wait elapsed")
+	}
+
+	// 版本不一致的快照应被拒绝
+	snap := &BrainSnapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		t.Fatal(err)
+	}
+	snap.Ver = "0"
+	data, _ = json.Marshal(snap)
+	other := NewTickBrain(bcore.NewBlackboard(1005, nil), nil, nil)
+	other.Blackboard().Set("mp", 1)
+	if err := other.Restore(data); !errors.Is(err, ErrSnapshotVerMismatch) {
+		t.Fatalf("err = %v, want ErrSnapshotVerMismatch", err)
+	}
+	// 被拒绝的快照不修改黑板
+	if mp, _ := other.Blackboard().Get("mp"); mp != 1 || other.Blackboard().(bcore.IBlackboardInternal).UserMemory()["hp"] != nil {
+		t.Fatalf("blackboard changed by rejected snapshot: %v", other.Blackboard().(bcore.IBlackboardInternal).UserMemory())
+	}
+
+	// 无法得知到期时间的定时任务不能快照
+	mem := brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory("ss-wait")
+	deadline := mem.CronTask
+	mem.CronTask = noDeadlineTimer{}
+	if _, err := brain.Snapshot(); err == nil {
+		t.Fatal("snapshot timer without deadline")
+	}
+	mem.CronTask = deadline
+}
+
+type noDeadlineTimer struct{}
+
+func (noDeadlineTimer) Stop() bool { return false }
+
+func TestBrain_SnapshotRejectNested(t *testing.T) {
+	help()
+	sub := []byte(`{"root":"sn-sub-root","tag":"sn_sub","ver":"1","nodes":{
+"sn-sub-root":{"id":"sn-sub-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["sn-sub-wait"]},
+"sn-sub-wait":{"id":"sn-sub-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":"30s"}}
+}}`)
+	main := []byte(`{"root":"sn-root","tag":"sn_main","ver":"1","nodes":{
+"sn-root":{"id":"sn-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["sn-seq"]},
+"sn-seq":{"id":"sn-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["sn-slot","sn-wait"]},
+"sn-slot":{"id":"sn-slot","name":"DynamicSubtree","category":"decorator","title":"slot","properties":{"tag":"slot","runMode":1,"isSuccessWhenNotChild":true}},
+"sn-wait":{"id":"sn-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":"30s"}}
+}}`)
+	if err := GlobalTreeRegistry().LoadFromJsons([][]byte{sub, main}); err != nil {
+		t.Fatal(err)
+	}
+	brain := runTickTree(t, bcore.NewBlackboard(1006, nil), "sn_main", nil, nil)
+	if err := brain.DynamicDecorate("slot", "sn_sub"); err != nil {
+		t.Fatal(err)
+	}
+	brain.Tick(0)
+	brain.Blackboard().Set("hp", 3)
+	data, err := brain.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &BrainSnapshot{}
+	if err = json.Unmarshal(data, snap); err != nil {
+		t.Fatal(err)
+	}
+	if ns := snap.Nodes["0/0/0"]; ns == nil || ns.Tag != "sn_sub" {
+		t.Fatalf("dynamic subtree root not in snapshot: %+v", ns)
+	}
+	snap.Nodes["0/0/0"].Ver = "0"
+	data, _ = json.Marshal(snap)
+	other := NewTickBrain(bcore.NewBlackboard(1007, nil), nil, nil)
+	other.Blackboard().Set("mp", 1)
+	if err = other.Restore(data); !errors.Is(err, ErrSnapshotVerMismatch) {
+		t.Fatalf("err = %v, want ErrSnapshotVerMismatch", err)
+	}
+	if mp, _ := other.Blackboard().Get("mp"); mp != 1 || other.Blackboard().(bcore.IBlackboardInternal).UserMemory()["hp"] != nil {
+		t.Fatalf("blackboard changed by rejected snapshot: %v", other.Blackboard().(bcore.IBlackboardInternal).UserMemory())
+	}
+	for _, tree := range GlobalTreeRegistry().TreesByTag("sn_main") {
+		slot := tree.Root.Decorated(nil).(bcore.IComposite).Children()[0].(bcore.IDecorator)
+		if child := slot.Decorated(other); child != nil {
+			t.Fatalf("slot mounted %v by rejected snapshot", child.ID())
+		}
+	}
+}
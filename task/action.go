@@ -53,19 +53,36 @@ func (a *Action) OnStart(brain bcore.IBrain) {
 		a.Finish(brain, result == bcore.ResultSucceeded)
 		return
 	}
+	a.startTimer(brain)
+}
+
+// OnRestore 恢复定时更新
+//
+//	@override Node.OnRestore
+//	@receiver a
+//	@param brain
+//	@param timerRemaining
+func (a *Action) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	a.Task.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && a.IsActive(brain) {
+		a.startTimer(brain)
+	}
+}
+
+func (a *Action) startTimer(brain bcore.IBrain) {
 	// 按root节点时钟频率定时调用
 	interval := a.Root(brain).Interval()
 	a.stopTimer(brain)
-	lastTime := time.Now()
+	lastTime := brain.Now()
 	// 默认投递到黑板保存的线程ID
 	a.Memory(brain).CronTask = brain.Cron(interval, 0, func() {
 		if !a.IsActive(brain) {
 			return
 		}
-		currTime := time.Now()
+		currTime := brain.Now()
 		delta := currTime.Sub(lastTime)
 		lastTime = currTime
-		result = a.Update(brain, bcore.EventTypeOnUpdate, delta)
+		result := a.Update(brain, bcore.EventTypeOnUpdate, delta)
 		if result != bcore.ResultInProgress {
 			a.stopTimer(brain)
 			a.Finish(brain, result == bcore.ResultSucceeded)
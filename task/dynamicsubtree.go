@@ -1,6 +1,10 @@
 package task
 
 import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
 	"github.com/alkaid/behavior/bcore"
 )
 
@@ -16,6 +20,21 @@ type DynamicSubtreeProperties struct {
 	RunMode bcore.DynamicBehaviorMode `json:"runMode"` // 动态子树中断模式
 }
 
+// Valid
+//
+//	@implement bcore.IPropertiesValidator .Valid
+//	@receiver p
+//	@return error
+func (p *DynamicSubtreeProperties) Valid() error {
+	if p.Tag == "" {
+		return errors.New("tag cannot be nil")
+	}
+	if p.RunMode < bcore.DynamicBehaviorModeRestart || p.RunMode > bcore.DynamicBehaviorModeAbort {
+		return errors.New(fmt.Sprintf("runMode out of range,runMode=%d", p.RunMode))
+	}
+	return nil
+}
+
 func (p *DynamicSubtreeProperties) GetTag() string {
 	return p.Tag
 }
@@ -35,6 +54,11 @@ type IDynamicSubtree interface {
 	// @param brain
 	// @param decorated
 	DynamicDecorate(brain bcore.IBrain, decorated bcore.IRoot)
+	// DynamicUnmount 卸载动态装饰的子节点,恢复为配置的默认子树(未配置则为空)
+	//
+	// 非线程安全,由调用方自己保证
+	// @param brain
+	DynamicUnmount(brain bcore.IBrain)
 }
 
 var _ IDynamicSubtree = (*DynamicSubtree)(nil)
@@ -75,6 +99,7 @@ func (t *DynamicSubtree) Tag() string {
 //	@param decorated
 //	@param abort
 func (t *DynamicSubtree) DynamicDecorate(brain bcore.IBrain, decorated bcore.IRoot) {
+	t.Memory(brain).RequestDynamicUnmount = false
 	if t.IsInactive(brain) {
 		t.Memory(brain).DynamicChild = decorated
 		decorated.DynamicMount(brain, t)
@@ -82,6 +107,34 @@ func (t *DynamicSubtree) DynamicDecorate(brain bcore.IBrain, decorated bcore.IRo
 	}
 	// 如果已经激活,需要等待子树完成或强制中断
 	t.Memory(brain).RequestDynamicChild = decorated
+	t.applyRequest(brain)
+}
+
+// DynamicUnmount
+//
+//	@implement IDynamicSubtree.DynamicUnmount
+//	@receiver t
+//	@param brain
+func (t *DynamicSubtree) DynamicUnmount(brain bcore.IBrain) {
+	t.Memory(brain).RequestDynamicChild = nil
+	if t.Memory(brain).DynamicChild == nil {
+		t.Memory(brain).RequestDynamicUnmount = false
+		return
+	}
+	if t.IsInactive(brain) {
+		t.Memory(brain).DynamicChild = nil
+		return
+	}
+	// 如果已经激活,需要等待子树完成或强制中断
+	t.Memory(brain).RequestDynamicUnmount = true
+	t.applyRequest(brain)
+}
+
+// applyRequest 激活状态下请求更换子节点后,按中断模式处理当前分支
+//
+//	@receiver t
+//	@param brain
+func (t *DynamicSubtree) applyRequest(brain bcore.IBrain) {
 	if t.IsAborting(brain) {
 		return
 	}
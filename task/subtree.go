@@ -1,6 +1,8 @@
 package task
 
 import (
+	"github.com/samber/lo"
+
 	"github.com/alkaid/behavior/bcore"
 )
 
@@ -8,13 +10,21 @@ type ISubtreeProperties interface {
 	GetChildID() string
 	GetChildTag() string
 	GetIsSuccessWhenNotChild() bool
+	GetIn() map[string]string
+	GetOut() map[string]string
+	IsIsolated() bool
+	GetParams() map[string]any
 }
 
 // SubtreeProperties 子树容器属性
 type SubtreeProperties struct {
-	ChildID               string `json:"childID"`               // 默认子节点ID
-	ChildTag              string `json:"childTag"`              // 默认子节点Tag
-	IsSuccessWhenNotChild bool   `json:"isSuccessWhenNotChild"` // 无子节点时执行是否返回成功
+	ChildID               string            `json:"childID"`               // 默认子节点ID
+	ChildTag              string            `json:"childTag"`              // 默认子节点Tag
+	IsSuccessWhenNotChild bool              `json:"isSuccessWhenNotChild"` // 无子节点时执行是否返回成功
+	In                    map[string]string `json:"in,omitempty"`          // 输入端口:子树内的键->父树的键,子树读取该键时读取父树的键
+	Out                   map[string]string `json:"out,omitempty"`         // 输出端口:子树内的键->父树的键,子树写入该键时写入父树的键
+	Isolated              bool              `json:"isolated,omitempty"`    // 是否隔离作用域,是则未映射的键只在该容器内可见,否则与父树共用
+	Params                map[string]any    `json:"params,omitempty"`      // 子树为模板时的参数,挂载以此特化的子树,参看 config.TreeCfg.Params
 }
 
 func (s *SubtreeProperties) GetChildTag() string {
@@ -26,6 +36,18 @@ func (s *SubtreeProperties) GetChildID() string {
 func (s *SubtreeProperties) GetIsSuccessWhenNotChild() bool {
 	return s.IsSuccessWhenNotChild
 }
+func (s *SubtreeProperties) GetIn() map[string]string {
+	return s.In
+}
+func (s *SubtreeProperties) GetOut() map[string]string {
+	return s.Out
+}
+func (s *SubtreeProperties) IsIsolated() bool {
+	return s.Isolated
+}
+func (s *SubtreeProperties) GetParams() map[string]any {
+	return s.Params
+}
 
 // ISubtree 静态子树容器
 //
@@ -38,9 +60,13 @@ type ISubtree interface {
 	// GetPropChildTag 获取配置中的子节点Tag
 	//  @return string
 	GetPropChildTag() string
+	// GetPropParams 获取配置中的子树模板参数
+	//  @return map[string]any
+	GetPropParams() map[string]any
 }
 
 var _ ISubtree = (*Subtree)(nil)
+var _ bcore.IBlackboardPorts = (*Subtree)(nil)
 
 // Subtree 静态子树容器
 //
@@ -132,3 +158,29 @@ func (t *Subtree) GetPropChildID() string {
 func (t *Subtree) GetPropChildTag() string {
 	return t.Properties().(ISubtreeProperties).GetChildTag()
 }
+
+// GetPropParams
+//
+//	@implement ISubtree.GetPropParams
+//	@receiver t
+//	@return map[string]any
+func (t *Subtree) GetPropParams() map[string]any {
+	return t.Properties().(ISubtreeProperties).GetParams()
+}
+
+// BlackboardPorts 端口映射.节点配置的 config.NodeCfg.Remappings (如从 Groot 导入的 SubTree 端口)同时作为输入和输出端口,属性中的 in,out 优先
+//
+//	@implement bcore.IBlackboardPorts .BlackboardPorts
+//	@receiver t
+//	@return in
+//	@return out
+//	@return isolated
+func (t *Subtree) BlackboardPorts() (in map[string]string, out map[string]string, isolated bool) {
+	props := t.Properties().(ISubtreeProperties)
+	in, out = props.GetIn(), props.GetOut()
+	if cfg := t.RawCfg(); cfg != nil && len(cfg.Remappings) > 0 {
+		in = lo.Assign(cfg.Remappings, in)
+		out = lo.Assign(cfg.Remappings, out)
+	}
+	return in, out, props.IsIsolated()
+}
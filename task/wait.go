@@ -57,6 +57,10 @@ func (w *Wait) WaitProperties() IWaitProperties {
 	return w.Properties().(IWaitProperties)
 }
 
+func (w *Wait) BoundWaitProperties(brain bcore.IBrain) IWaitProperties {
+	return w.BoundProperties(brain).(IWaitProperties)
+}
+
 // OnStart
 //
 //	@override Node.OnStart
@@ -65,14 +69,31 @@ func (w *Wait) WaitProperties() IWaitProperties {
 func (w *Wait) OnStart(brain bcore.IBrain) {
 	w.Task.OnStart(brain)
 	w.Memory(brain).Elapsed = 0
-	if w.WaitProperties().GetForever() {
+	if w.BoundWaitProperties(brain).GetForever() {
 		return
 	}
-	w.Memory(brain).CronTask = brain.After(w.WaitProperties().GetWaitTime(), w.WaitProperties().GetRandomDeviation(), func() {
+	w.Memory(brain).CronTask = brain.After(w.BoundWaitProperties(brain).GetWaitTime(), w.BoundWaitProperties(brain).GetRandomDeviation(), w.getTaskFun(brain))
+}
+
+// OnRestore
+//
+//	@override Node.OnRestore
+//	@receiver w
+//	@param brain
+//	@param timerRemaining
+func (w *Wait) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	w.Task.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && w.IsActive(brain) {
+		w.Memory(brain).CronTask = brain.After(timerRemaining, 0, w.getTaskFun(brain))
+	}
+}
+
+func (w *Wait) getTaskFun(brain bcore.IBrain) func() {
+	return func() {
 		if w.IsActive(brain) {
 			w.Finish(brain, true)
 		}
-	})
+	}
 }
 
 // OnAbort
@@ -83,7 +104,7 @@ func (w *Wait) OnStart(brain bcore.IBrain) {
 func (w *Wait) OnAbort(brain bcore.IBrain) {
 	w.Task.OnAbort(brain)
 	w.stopTimer(brain)
-	w.Finish(brain, w.WaitProperties().GetResultOnAbort())
+	w.Finish(brain, w.BoundWaitProperties(brain).GetResultOnAbort())
 }
 
 func (w *Wait) stopTimer(brain bcore.IBrain) {
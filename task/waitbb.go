@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
 )
 
 type IWaitBBProperties interface {
@@ -21,6 +22,15 @@ type WaitBBProperties struct {
 	RandomDeviation util.Duration `json:"randomDeviation"` // 随机离差:允许向 等待时间（WaitTime）属性添加随机时间 WaitTime=WaitTime+RandomDeviation*[-0.5,0.5)
 }
 
+// BlackboardKeys
+//
+//	@implement bcore.IBlackboardKeysProvider .BlackboardKeys
+//	@receiver w
+//	@return map[string]config.KeyType
+func (w *WaitBBProperties) BlackboardKeys() map[string]config.KeyType {
+	return map[string]config.KeyType{w.Key: config.KeyTypeDuration}
+}
+
 func (w *WaitBBProperties) GetKey() string {
 	return w.Key
 }
@@ -32,6 +42,7 @@ func (w *WaitBBProperties) GetRandomDeviation() time.Duration {
 // WaitBB 等待黑板时间
 //
 //	与 等待 WaitBB 任务节点的原理类似，但该节点会拉取等待时间黑板值。
+//	也可以使用 Wait 并将 waitTime 绑定黑板键,如 "waitTime":{"$bb":"key"},参看 bcore.BindingKey
 type WaitBB struct {
 	bcore.Task
 }
@@ -48,6 +59,10 @@ func (w *WaitBB) WaitBBProperties() IWaitBBProperties {
 	return w.Properties().(IWaitBBProperties)
 }
 
+func (w *WaitBB) BoundWaitBBProperties(brain bcore.IBrain) IWaitBBProperties {
+	return w.BoundProperties(brain).(IWaitBBProperties)
+}
+
 // OnStart
 //
 //	@override Node.OnStart
@@ -56,18 +71,35 @@ func (w *WaitBB) WaitBBProperties() IWaitBBProperties {
 func (w *WaitBB) OnStart(brain bcore.IBrain) {
 	w.Task.OnStart(brain)
 	w.Memory(brain).Elapsed = 0
-	delay, ok := brain.Blackboard().GetDuration(w.WaitBBProperties().GetKey())
+	delay, ok := w.Blackboard(brain).GetDuration(w.BoundWaitBBProperties(brain).GetKey())
 	if !ok {
-		w.Log(brain).Error("not found wait time in blackboard", zap.String("key", w.WaitBBProperties().GetKey()))
+		w.Log(brain).Error("not found wait time in blackboard", zap.String("key", w.BoundWaitBBProperties(brain).GetKey()))
 		// 取值失败则默认为不等待
 		w.Finish(brain, true)
 	}
-	w.Memory(brain).CronTask = brain.After(delay, w.WaitBBProperties().GetRandomDeviation(), func() {
+	w.Memory(brain).CronTask = brain.After(delay, w.BoundWaitBBProperties(brain).GetRandomDeviation(), w.getTaskFun(brain))
+}
+
+// OnRestore
+//
+//	@override Node.OnRestore
+//	@receiver w
+//	@param brain
+//	@param timerRemaining
+func (w *WaitBB) OnRestore(brain bcore.IBrain, timerRemaining time.Duration) {
+	w.Task.OnRestore(brain, timerRemaining)
+	if timerRemaining >= 0 && w.IsActive(brain) {
+		w.Memory(brain).CronTask = brain.After(timerRemaining, 0, w.getTaskFun(brain))
+	}
+}
+
+func (w *WaitBB) getTaskFun(brain bcore.IBrain) func() {
+	return func() {
 		if !w.IsActive(brain) {
 			return
 		}
 		w.Finish(brain, true)
-	})
+	}
 }
 
 // OnAbort
@@ -0,0 +1,114 @@
+package behavior
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/logger"
+	"github.com/alkaid/behavior/timer"
+)
+
+var _ bcore.Executor = (*tickExecutor)(nil)
+
+// tickExecutor 同步帧驱动模式下的执行器
+//
+//	所有派发给 Brain 的任务(启动/中断、黑板通知)都先入队,到期定时任务由私有的 timer.ManualClock 管理,
+//	均在调用 Brain.Tick 的协程里按先进先出的顺序执行
+type tickExecutor struct {
+	mu      sync.Mutex
+	queue   []func()           // 待执行任务
+	clock   *timer.ManualClock // 帧时钟,只在 Tick 时推进
+	ticking bool               // 是否正在 Tick,仅在 Tick 协程中读写
+}
+
+func newTickExecutor() *tickExecutor {
+	return &tickExecutor{clock: timer.NewManualClock(time.Time{})}
+}
+
+// Go 任务入队,线程安全
+//
+//	@implement bcore.Executor .Go
+//	@receiver e
+//	@param task
+func (e *tickExecutor) Go(task func()) {
+	e.mu.Lock()
+	e.queue = append(e.queue, task)
+	e.mu.Unlock()
+}
+
+// drain 执行队列中所有任务,包括执行过程中新入队的任务
+//
+//	@receiver e
+func (e *tickExecutor) drain() {
+	for {
+		e.mu.Lock()
+		tasks := e.queue
+		e.queue = nil
+		e.mu.Unlock()
+		if len(tasks) == 0 {
+			return
+		}
+		for _, task := range tasks {
+			task()
+		}
+	}
+}
+
+// wrap 包装定时任务,执行后立即处理其派生出的任务,保证与异步模式下的执行顺序一致
+//
+//	@receiver e
+//	@param task
+//	@return func()
+func (e *tickExecutor) wrap(task func()) func() {
+	return func() {
+		task()
+		e.drain()
+	}
+}
+
+// NewTickBrain 实例化一个同步帧驱动模式的 Brain
+//
+//	该模式下 Brain 不依赖线程池和时间轮,所有任务只在调用 Brain.Tick 时于调用方协程内同步执行,
+//	适合嵌入已有固定帧率循环的服务器,以及需要每次运行顺序都一致的帧同步模拟和回放
+//	@param blackboard
+//	@param delegates 要注册的委托对象
+//	@param finishChan
+//	@return *Brain
+func NewTickBrain(blackboard bcore.IBlackboard, delegates map[string]any, finishChan chan *bcore.FinishEvent) *Brain {
+	b := NewBrain(blackboard, delegates, finishChan).(*Brain)
+	b.tick = newTickExecutor()
+	b.blackboard.SetExecutor(b.tick)
+	return b
+}
+
+// TickMode 是否同步帧驱动模式
+//
+//	@receiver b
+//	@return bool
+func (b *Brain) TickMode() bool {
+	return b.tick != nil
+}
+
+// Tick 推进一帧:依次执行已派发的任务、推进时钟 delta 并执行期间到期的定时任务及其派生的任务,全部执行完后返回
+//
+//	仅同步帧驱动模式( NewTickBrain )有效.同一个 Brain 须始终在同一个协程里调用,且不能在树的回调中调用
+//	@receiver b
+//	@param delta 帧间隔
+func (b *Brain) Tick(delta time.Duration) {
+	if b.tick == nil {
+		logger.Log.Error("brain is not in tick mode,please create it by NewTickBrain")
+		return
+	}
+	if b.tick.ticking {
+		logger.Log.Error("brain can not tick reentrant")
+		return
+	}
+	b.tick.ticking = true
+	defer func() {
+		b.tick.ticking = false
+	}()
+	b.tick.drain()
+	b.tick.clock.Advance(delta)
+	b.tick.drain()
+}
@@ -0,0 +1,82 @@
+package behavior
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+)
+
+func TestBrain_Tick(t *testing.T) {
+	help()
+	content := `
+{"root":"tk-root","tag":"test_tick","nodes":{
+"tk-root":{"id":"tk-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["tk-sel"]},
+"tk-sel":{"id":"tk-sel","name":"Selector","category":"composite","title":"Selector","properties":{},"children":["tk-cond","tk-wait"]},
+"tk-cond":{"id":"tk-cond","name":"BBCondition","category":"decorator","title":"alarm?","properties":{"operator":0,"key":"alarm","abortMode":2},"children":["tk-seq"]},
+"tk-seq":{"id":"tk-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["tk-react","tk-action"]},
+"tk-react":{"id":"tk-react","name":"Wait","category":"task","title":"react","properties":{"waitTime":"500ms"}},
+"tk-action":{"id":"tk-action","name":"Action","category":"task","title":"Action","properties":{}},
+"tk-wait":{"id":"tk-wait","name":"Wait","category":"task","title":"idle","properties":{"forever":true}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := NewTickBrain(bcore.NewBlackboard(1002, nil), nil, fch)
+	if err := brain.Run("test_tick", false); err != nil {
+		t.Fatal(err)
+	}
+	if brain.Running() {
+		t.Fatal("tree started before Tick")
+	}
+	brain.Tick(0)
+	if !brain.Running() {
+		t.Fatal("tree not started after Tick")
+	}
+	for i := 0; i < 100; i++ {
+		brain.Tick(50 * time.Millisecond)
+	}
+	select {
+	case <-fch:
+		t.Fatal("idle branch should wait forever")
+	default:
+	}
+	brain.Blackboard().Set("alarm", true)
+	brain.Tick(400 * time.Millisecond)
+	select {
+	case <-fch:
+		t.Fatal("tree finished before react wait elapsed")
+	default:
+	}
+	brain.Tick(100 * time.Millisecond)
+	select {
+	case event := <-fch:
+		if !event.Succeeded || event.IsAbort {
+			t.Fatalf("finish event = %+v, want succeeded", event)
+		}
+	default:
+		t.Fatal("tree not finished after alarm branch ran")
+	}
+	if brain.Running() {
+		t.Fatal("brain still running after finish")
+	}
+}
+
+// runTickTree 以同步帧驱动模式运行树,并执行首帧使树启动
+//
+//	@param t
+//	@param bb
+//	@param tag
+//	@param delegates
+//	@param fch
+//	@return *Brain
+func runTickTree(t *testing.T, bb *bcore.Blackboard, tag string, delegates map[string]any, fch chan *bcore.FinishEvent) *Brain {
+	t.Helper()
+	brain := NewTickBrain(bb, delegates, fch)
+	if err := brain.Run(tag, false); err != nil {
+		t.Fatal(err)
+	}
+	brain.Tick(0)
+	return brain
+}
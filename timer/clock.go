@@ -0,0 +1,270 @@
+package timer
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alkaid/behavior/logger"
+	"github.com/alkaid/timingwheel"
+	"go.uber.org/zap"
+)
+
+// Timer 可停止的定时任务
+type Timer interface {
+	// Stop 停止定时任务
+	//  @return bool 是否由本次调用停止,已过期或已停止的返回false
+	Stop() bool
+}
+
+// Clock 时钟,为所有定时节点提供当前时间和定时任务
+//
+//	默认使用 WheelClock 即真实时间+时间轮,测试或模拟时可替换为 ManualClock
+type Clock interface {
+	// Now 当前时间
+	//  @return time.Time
+	Now() time.Time
+	// AfterFunc interval后执行一次task
+	//  @param interval
+	//  @param task
+	//  @param opts 同 timingwheel.Option,用于指定执行线程
+	//  @return Timer
+	AfterFunc(interval time.Duration, task func(), opts ...timingwheel.Option) Timer
+	// Cron 每隔interval执行一次task,直到被停止
+	//  @param interval
+	//  @param task
+	//  @param opts 同 timingwheel.Option,用于指定执行线程
+	//  @return Timer
+	Cron(interval time.Duration, task func(), opts ...timingwheel.Option) Timer
+}
+
+// DeadlineTimer 记录了到期时间的 Timer,用于快照时计算剩余时间
+type DeadlineTimer struct {
+	Timer
+	Deadline time.Time // 到期时间
+}
+
+// WithDeadline 包装 Timer 并记录到期时间
+//
+//	@param t
+//	@param deadline
+//	@return Timer t为空时返回nil
+func WithDeadline(t Timer, deadline time.Time) Timer {
+	if t == nil {
+		return nil
+	}
+	return &DeadlineTimer{Timer: t, Deadline: deadline}
+}
+
+var _ Clock = (*WheelClock)(nil)
+var _ Clock = (*ManualClock)(nil)
+
+// WheelClock 真实时钟,定时任务由时间轮池驱动.零值使用全局时间轮池,必须调用 InitPool 后方可使用
+type WheelClock struct {
+	pool *TimeWheelPool // 时间轮池,为空则使用全局时间轮池
+}
+
+// NewWheelClock 实例化使用指定时间轮池的真实时钟
+//
+//	@param pool 为空则使用全局时间轮池
+//	@return *WheelClock
+func NewWheelClock(pool *TimeWheelPool) *WheelClock {
+	return &WheelClock{pool: pool}
+}
+
+func (w *WheelClock) Now() time.Time {
+	return time.Now()
+}
+
+func (w *WheelClock) AfterFunc(interval time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	return wrapTimer(w.wheel().AfterFunc(interval, task, opts...))
+}
+
+func (w *WheelClock) Cron(interval time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	return wrapTimer(w.wheel().Cron(interval, task, opts...))
+}
+
+func (w *WheelClock) wheel() *timingwheel.TimingWheel {
+	if w.pool != nil {
+		return w.pool.Get()
+	}
+	return TimeWheelInstance()
+}
+
+// wrapTimer 避免nil指针被包装成非nil接口
+//
+//	@param t
+//	@return Timer
+func wrapTimer(t *timingwheel.Timer) Timer {
+	if t == nil {
+		return nil
+	}
+	return t
+}
+
+// ManualClock 手动时钟,时间只在调用 Advance 时推进,到期任务按到期时间顺序(相同时按添加顺序)执行.
+//
+//	用于单元测试和模拟:30秒的cd只需 Advance(30*time.Second) 即可,且每次运行顺序一致.
+//	指定了线程(timingwheel.WithGoID + timingwheel.WithPool)的任务会派发到该线程并等待执行完成,
+//	故 Advance 不能在被派发的线程内调用,否则将死锁.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int64
+	timers manualTimerHeap
+}
+
+// NewManualClock 实例化手动时钟
+//
+//	@param start 起始时间
+//	@return *ManualClock
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(interval time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	return c.add(interval, 0, task, opts)
+}
+
+func (c *ManualClock) Cron(interval time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	// 间隔不能小于1ms,否则 Advance 将无限循环,与时间轮的最小精度保持一致
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return c.add(interval, interval, task, opts)
+}
+
+func (c *ManualClock) add(delay time.Duration, period time.Duration, task func(), opts []timingwheel.Option) *manualTimer {
+	options := &timingwheel.Options{GoID: -1}
+	for _, opt := range opts {
+		opt(options)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &manualTimer{
+		clock:   c,
+		when:    c.now.Add(delay),
+		period:  period,
+		task:    task,
+		options: options,
+		seq:     c.seq,
+	}
+	heap.Push(&c.timers, t)
+	return t
+}
+
+// Pending 待执行的定时任务数量
+//
+//	@return int
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timers.Len()
+}
+
+// Advance 推进时间d,并依次执行期间到期的定时任务.任务中新添加的且在d内到期的任务也会被执行
+//
+//	@param d 不能为负,时间不可回退,否则panic
+func (c *ManualClock) Advance(d time.Duration) {
+	if d < 0 {
+		panic(fmt.Sprintf("ManualClock.Advance: negative duration %v, time cannot go backward", d))
+	}
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		if c.timers.Len() == 0 || c.timers[0].when.After(target) {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := heap.Pop(&c.timers).(*manualTimer)
+		c.now = t.when
+		if t.period > 0 {
+			c.seq++
+			t.seq = c.seq
+			t.when = t.when.Add(t.period)
+			heap.Push(&c.timers, t)
+		}
+		c.mu.Unlock()
+		t.run()
+	}
+}
+
+// manualTimer ManualClock 的定时任务
+type manualTimer struct {
+	clock   *ManualClock
+	when    time.Time
+	period  time.Duration // >0 表示循环任务
+	task    func()
+	options *timingwheel.Options
+	seq     int64 // 添加顺序,到期时间相同时先添加的先执行
+	index   int   // 在堆中的索引,-1表示已移出
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.clock.timers, t.index)
+	return true
+}
+
+func (t *manualTimer) run() {
+	if t.options.Pool == nil || t.options.GoID <= 0 {
+		t.task()
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err := t.options.Pool.Submit(t.options.GoID, func() {
+		defer wg.Done()
+		t.task()
+	})
+	if err != nil {
+		logger.Log.Error("submit manual clock task error", zap.Error(err), zap.Int("goID", t.options.GoID))
+		return
+	}
+	wg.Wait()
+}
+
+// manualTimerHeap 按到期时间排序的最小堆
+type manualTimerHeap []*manualTimer
+
+func (h manualTimerHeap) Len() int { return len(h) }
+func (h manualTimerHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+func (h manualTimerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *manualTimerHeap) Push(x any) {
+	t := x.(*manualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *manualTimerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
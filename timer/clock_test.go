@@ -0,0 +1,70 @@
+package timer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManualClock_Advance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+	var got []string
+	c.AfterFunc(3*time.Second, func() { got = append(got, "after3s") })
+	c.AfterFunc(time.Second, func() { got = append(got, "after1s.a") })
+	c.AfterFunc(time.Second, func() { got = append(got, "after1s.b") })
+	stopped := c.AfterFunc(2*time.Second, func() { got = append(got, "stopped") })
+	cron := c.Cron(time.Second, func() { got = append(got, "cron") })
+	if !stopped.Stop() {
+		t.Fatal("Stop() = false, want true")
+	}
+	if stopped.Stop() {
+		t.Fatal("second Stop() = true, want false")
+	}
+	c.Advance(500 * time.Millisecond)
+	if len(got) != 0 {
+		t.Fatalf("fired too early: %v", got)
+	}
+	c.Advance(2500 * time.Millisecond)
+	want := []string{"after1s.a", "after1s.b", "cron", "cron", "after3s", "cron"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Advance() fired %v, want %v", got, want)
+	}
+	if now := c.Now(); !now.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("Now() = %v, want %v", now, start.Add(3*time.Second))
+	}
+	cron.Stop()
+	if c.Pending() != 0 {
+		t.Fatalf("Pending() = %d, want 0", c.Pending())
+	}
+}
+
+func TestManualClock_NestedTimer(t *testing.T) {
+	c := NewManualClock(time.Time{})
+	var fired []time.Duration
+	c.AfterFunc(time.Second, func() {
+		fired = append(fired, c.Now().Sub(time.Time{}))
+		c.AfterFunc(time.Second, func() {
+			fired = append(fired, c.Now().Sub(time.Time{}))
+		})
+	})
+	c.Advance(5 * time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second}
+	if !reflect.DeepEqual(fired, want) {
+		t.Fatalf("nested timers fired at %v, want %v", fired, want)
+	}
+}
+
+func TestManualClock_AdvanceNegative(t *testing.T) {
+	c := NewManualClock(time.Time{})
+	c.Advance(time.Second)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Advance() with negative duration should panic")
+		}
+		if got := c.Now().Sub(time.Time{}); got != time.Second {
+			t.Fatalf("Now() = %v after rejected Advance, want %v", got, time.Second)
+		}
+	}()
+	c.Advance(-time.Millisecond)
+}
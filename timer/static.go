@@ -7,7 +7,8 @@ import (
 	"github.com/alkaid/timingwheel"
 )
 
-var pool *TimeWheelPool // 全局时间轮单例,必须调用 InitPool 后方可使用
+var pool *TimeWheelPool         // 全局时间轮单例,必须调用 InitPool 后方可使用
+var clock Clock = &WheelClock{} // 全局时钟,默认为真实时钟
 const half = 0.5
 
 // InitPool 初始化 pool
@@ -30,24 +31,51 @@ func TimeWheelInstance() *timingwheel.TimingWheel {
 	return pool.Get()
 }
 
-// Cron wrap timingwheel.TimingWheel .Cron
+// GlobalClock 获取全局时钟
+//
+//	@return Clock
+func GlobalClock() Clock {
+	return clock
+}
+
+// SetGlobalClock 设置全局时钟,为nil时恢复为真实时钟
+//
+//	@param c
+func SetGlobalClock(c Clock) {
+	if c == nil {
+		c = &WheelClock{}
+	}
+	clock = c
+}
+
+// Deviate 为间隔添加随机离差
+//
+//	@param interval 间隔
+//	@param randomDeviation 随机离差范围 interval = interval + randomDeviation*[-0.5,0.5)
+//	@return time.Duration
+func Deviate(interval time.Duration, randomDeviation time.Duration) time.Duration {
+	if randomDeviation == 0 {
+		return interval
+	}
+	return interval - time.Duration(half*float32(randomDeviation)) + time.Duration(rand.Float32()*float32(randomDeviation))
+}
+
+// Cron 使用全局时钟执行循环任务
 //
 //	@param interval 间隔
 //	@param randomDeviation 随机离差范围,interval=interval+randomDeviation*[-0.5,0.5)
 //	@param task
 //	@param opts
-func Cron(interval time.Duration, randomDeviation time.Duration, task func(), opts ...timingwheel.Option) *timingwheel.Timer {
-	interval = interval - time.Duration(half*float32(randomDeviation)) + time.Duration(rand.Float32()*float32(randomDeviation))
-	return TimeWheelInstance().Cron(interval, task, opts...)
+func Cron(interval time.Duration, randomDeviation time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	return clock.Cron(Deviate(interval, randomDeviation), task, opts...)
 }
 
-// After wrap timingwheel.TimingWheel .AfterFunc
+// After 使用全局时钟执行延时任务
 //
 //	@param interval 间隔
 //	@param randomDeviation 随机离差范围 interval = interval + randomDeviation*[-0.5,0.5)
 //	@param task
 //	@param opts
-func After(interval time.Duration, randomDeviation time.Duration, task func(), opts ...timingwheel.Option) *timingwheel.Timer {
-	interval = interval - time.Duration(half*float32(randomDeviation)) + time.Duration(rand.Float32()*float32(randomDeviation))
-	return TimeWheelInstance().AfterFunc(interval, task, opts...)
+func After(interval time.Duration, randomDeviation time.Duration, task func(), opts ...timingwheel.Option) Timer {
+	return clock.AfterFunc(Deviate(interval, randomDeviation), task, opts...)
 }
@@ -0,0 +1,73 @@
+package behavior
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alkaid/behavior/bcore"
+)
+
+type recordTracer struct {
+	events []bcore.TraceEvent
+}
+
+func (r *recordTracer) OnTrace(brain bcore.IBrain, event *bcore.TraceEvent) {
+	r.events = append(r.events, *event)
+}
+
+func TestBrain_Tracer(t *testing.T) {
+	help()
+	content := `
+{"root":"tr-root","tag":"test_tracer","nodes":{
+"tr-root":{"id":"tr-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["tr-seq"]},
+"tr-seq":{"id":"tr-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["tr-wait","tr-action"]},
+"tr-wait":{"id":"tr-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":"1s"}},
+"tr-action":{"id":"tr-action","name":"Action","category":"task","title":"Action","properties":{}}
+}}`
+	if err := GlobalTreeRegistry().LoadFromJson([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	tracer := &recordTracer{}
+	brain := NewTickBrain(bcore.NewBlackboard(1006, nil), nil, make(chan *bcore.FinishEvent, 1))
+	brain.SetTracer(tracer)
+	if err := brain.Run("test_tracer", false); err != nil {
+		t.Fatal(err)
+	}
+	brain.Tick(time.Second)
+	type step struct {
+		typ    bcore.TraceEventType
+		nodeID string
+	}
+	want := []step{
+		{bcore.TraceStart, "tr-root"},
+		{bcore.TraceStart, "tr-seq"},
+		{bcore.TraceStart, "tr-wait"},
+		{bcore.TraceFinish, "tr-wait"},
+		{bcore.TraceChildFinished, "tr-seq"},
+		{bcore.TraceStart, "tr-action"},
+		{bcore.TraceFinish, "tr-action"},
+		{bcore.TraceChildFinished, "tr-seq"},
+		{bcore.TraceFinish, "tr-seq"},
+		{bcore.TraceChildFinished, "tr-root"},
+		{bcore.TraceFinish, "tr-root"},
+	}
+	if len(tracer.events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(tracer.events), len(want), tracer.events)
+	}
+	var last time.Duration
+	for i, e := range tracer.events {
+		if e.Type != want[i].typ || e.NodeID != want[i].nodeID {
+			t.Fatalf("event %d = %d %s, want %d %s", i, e.Type, e.NodeID, want[i].typ, want[i].nodeID)
+		}
+		if e.BrainID != 1006 || e.Timestamp < last {
+			t.Fatalf("event %d = %+v", i, e)
+		}
+		last = e.Timestamp
+	}
+	if e := tracer.events[3]; e.PrevState != bcore.NodeStateActive || e.State != bcore.NodeStateInactive || e.Result != bcore.ResultSucceeded {
+		t.Fatalf("wait finish event = %+v", e)
+	}
+	if e := tracer.events[4]; e.Child == nil || e.Child.ID() != "tr-wait" || e.State != bcore.NodeStateActive {
+		t.Fatalf("sequence child finished event = %+v", e)
+	}
+}
@@ -4,14 +4,27 @@ import (
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alkaid/behavior/internal"
 
 	"github.com/alkaid/behavior/task"
 
 	"github.com/alkaid/behavior/bcore"
 	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/handle"
 	"github.com/alkaid/behavior/logger"
+	"github.com/alkaid/behavior/script"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.uber.org/zap"
 )
 
@@ -19,32 +32,82 @@ type Tree struct {
 	Root              bcore.IRoot
 	Ver               string
 	Tag               string
-	StaticSubtrees    map[string]task.ISubtree        // 所有静态子树容器,索引为id
-	DynamicSubtrees   map[string]task.IDynamicSubtree // 所有动态子树容器,key为tag
-	AllSubtreeMounted bool                            // 是否所有子树已经全部挂载完(不包括childTag为空的)
+	StaticSubtrees    map[string]task.ISubtree            // 所有静态子树容器,索引为id
+	DynamicSubtrees   map[string]task.IDynamicSubtree     // 所有动态子树容器,key为tag
+	AllSubtreeMounted bool                                // 是否所有子树已经全部挂载完(不包括childTag为空的)
+	Params            map[string]any                      // 特化所用的完整参数集,模板以默认参数实例化的树和非模板的树为空
+	Blackboard        map[string]*config.BlackboardKeyCfg // 黑板键声明,作为主树运行时生效,参看 config.TreeCfg .Blackboard
+
+	template  *config.TreeCfg // 模板配置(未替换占位符),非模板为空
+	paramsKey string          // 特化参数集的哈希,参看 config.ParamsKey
+}
+
+// indexKey 树在索引中的键,特化的树为 tag#参数哈希
+//
+//	@receiver t
+//	@return string
+func (t *Tree) indexKey() string {
+	return specKey(t.Tag, t.paramsKey)
+}
+
+func specKey(tag string, paramsKey string) string {
+	if paramsKey == "" {
+		return tag
+	}
+	return tag + "#" + paramsKey
 }
 
 // clone 拷贝整个树,不会注册。要注册请调用 TreeRegistry.CloneAndReg
 //
 // @receiver t
+// @param loader 类加载器
 // @return *Tree
 // @return error
-func (t *Tree) clone() (*Tree, error) {
+func (t *Tree) clone(loader *ClassLoader) (*Tree, error) {
 	tree := &Tree{
 		Tag:             t.Tag,
 		Ver:             t.Ver,
 		StaticSubtrees:  map[string]task.ISubtree{},
 		DynamicSubtrees: map[string]task.IDynamicSubtree{},
+		Params:          t.Params,
+		Blackboard:      t.Blackboard,
+		template:        t.template,
+		paramsKey:       t.paramsKey,
 	}
-	_, err := t.backtrackingClone(t.Root, tree)
+	_, err := t.backtrackingClone(loader, t.Root, tree)
 	if err != nil {
 		return nil, err
 	}
 	return tree, nil
 }
 
-func (t *Tree) backtrackingClone(originNode bcore.INode, newTree *Tree) (bcore.INode, error) {
-	newNode, err := globalClassLoader.Clone(originNode)
+// eachNode 先序遍历树自身的节点,不进入挂载的子树
+//
+//	@receiver t
+//	@param visit
+func (t *Tree) eachNode(visit func(node bcore.INode)) {
+	var walk func(node bcore.INode)
+	walk = func(node bcore.INode) {
+		visit(node)
+		if node.Name() == bcore.NodeNameSubtree || node.Name() == bcore.NodeNameDynamicSubtree {
+			return
+		}
+		switch v := node.(type) {
+		case bcore.IComposite:
+			for _, child := range v.Children() {
+				walk(child)
+			}
+		case bcore.IDecorator:
+			if child := v.Decorated(nil); child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(t.Root)
+}
+
+func (t *Tree) backtrackingClone(loader *ClassLoader, originNode bcore.INode, newTree *Tree) (bcore.INode, error) {
+	newNode, err := loader.Clone(originNode)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +136,7 @@ func (t *Tree) backtrackingClone(originNode bcore.INode, newTree *Tree) (bcore.I
 	switch v := originNode.(type) {
 	case bcore.IComposite:
 		for _, child := range v.Children() {
-			newChild, err := t.backtrackingClone(child, newTree)
+			newChild, err := t.backtrackingClone(loader, child, newTree)
 			if err != nil {
 				return nil, err
 			}
@@ -82,7 +145,7 @@ func (t *Tree) backtrackingClone(originNode bcore.INode, newTree *Tree) (bcore.I
 	case bcore.IDecorator:
 		child := v.Decorated(nil)
 		if child != nil {
-			newChild, err := t.backtrackingClone(child, newTree)
+			newChild, err := t.backtrackingClone(loader, child, newTree)
 			if err != nil {
 				return nil, err
 			}
@@ -95,132 +158,614 @@ func (t *Tree) backtrackingClone(originNode bcore.INode, newTree *Tree) (bcore.I
 	return newNode, nil
 }
 
+// treeIndex 树索引.发布后只读,修改时先拷贝再整体替换,故读取无需加锁
+type treeIndex struct {
+	byID   map[string]*Tree   // 所有树,索引为 IRoot.ID
+	byTag  map[string][]*Tree // 所有树,索引为 IRoot.Tag,不包括特化的树
+	bySpec map[string][]*Tree // 模板按参数特化的树,索引为 Tree.indexKey
+	// 本次修改中退役的树,索引为 IRoot.ID.修改成功后才并入 TreeRegistry.retired,失败时随索引一起丢弃
+	retiring map[string]*Tree
+	removed  []*Tree // 本次修改中移除的树,修改成功后注销其节点脚本
+}
+
+func newTreeIndex() *treeIndex {
+	return &treeIndex{
+		byID:   map[string]*Tree{},
+		byTag:  map[string][]*Tree{},
+		bySpec: map[string][]*Tree{},
+	}
+}
+
+// clone 浅拷贝索引,tag下的切片在修改时另行拷贝
+//
+//	@receiver idx
+//	@return *treeIndex
+func (idx *treeIndex) clone() *treeIndex {
+	dst := &treeIndex{
+		byID:   make(map[string]*Tree, len(idx.byID)),
+		byTag:  make(map[string][]*Tree, len(idx.byTag)),
+		bySpec: make(map[string][]*Tree, len(idx.bySpec)),
+	}
+	for id, tree := range idx.byID {
+		dst.byID[id] = tree
+	}
+	for tag, trees := range idx.byTag {
+		dst.byTag[tag] = trees
+	}
+	for key, trees := range idx.bySpec {
+		dst.bySpec[key] = trees
+	}
+	return dst
+}
+
+func (idx *treeIndex) add(tree *Tree) {
+	idx.byID[tree.Root.ID()] = tree
+	// 不能在原切片上追加,其底层数组可能被已发布的索引共享
+	if tree.paramsKey == "" {
+		idx.byTag[tree.Tag] = append(slices.Clip(idx.byTag[tree.Tag]), tree)
+		return
+	}
+	key := tree.indexKey()
+	idx.bySpec[key] = append(slices.Clip(idx.bySpec[key]), tree)
+}
+
+// lookup 按 Tree.indexKey 查找
+//
+//	@receiver idx
+//	@param key
+//	@return []*Tree
+func (idx *treeIndex) lookup(key string) []*Tree {
+	if trees, ok := idx.byTag[key]; ok {
+		return trees
+	}
+	return idx.bySpec[key]
+}
+
+// without 从tag索引中移除树,保留id索引
+//
+//	@receiver idx
+//	@param tree
+func (idx *treeIndex) without(tree *Tree) {
+	if tree.paramsKey == "" {
+		idx.byTag[tree.Tag] = lo.Without(idx.byTag[tree.Tag], tree)
+		return
+	}
+	key := tree.indexKey()
+	idx.bySpec[key] = lo.Without(idx.bySpec[key], tree)
+	if len(idx.bySpec[key]) == 0 {
+		delete(idx.bySpec, key)
+	}
+}
+
+// retire 在本次修改中退役树,并从tag索引中移除
+//
+//	@receiver idx
+//	@param tree
+func (idx *treeIndex) retire(tree *Tree) {
+	if idx.retiring == nil {
+		idx.retiring = map[string]*Tree{}
+	}
+	idx.retiring[tree.Root.ID()] = tree
+	idx.without(tree)
+}
+
+// specialized 该tag的模板特化出的所有树
+//
+//	@receiver idx
+//	@param tag
+//	@return []*Tree
+func (idx *treeIndex) specialized(tag string) []*Tree {
+	var trees []*Tree
+	for _, list := range idx.bySpec {
+		if len(list) > 0 && list[0].Tag == tag {
+			trees = append(trees, list...)
+		}
+	}
+	return trees
+}
+
 // TreeRegistry 行为树注册器
+//
+//	线程安全:读取无锁,加载,移除,克隆和挂载串行执行
 type TreeRegistry struct {
-	TreesByID  map[string]*Tree   // 所有树,索引为 IRoot.ID
-	TreesByTag map[string][]*Tree // 所有树,索引为 IRoot.Tag
+	index      atomic.Pointer[treeIndex] // 已发布的索引
+	mutex      sync.Mutex                // 串行化所有修改
+	retired    map[string]*Tree          // 热更后已退役但仍有AI在使用的旧版树,索引为 IRoot.ID,已从tag索引中移除
+	idle       map[string]*Tree          // 无人使用的特化树,索引为 IRoot.ID,已从特化索引中移除,下次修改时移除
+	unused     map[string]struct{}       // 释放后无人使用的已退役树或特化树的 IRoot.ID,下次修改时清理
+	sweeping   bool                      // 是否已安排延迟清理 unused
+	users      map[string]map[*Brain]struct{}
+	usersMutex sync.Mutex // 保护 users, retired, idle, unused 和 sweeping,须在 mutex 之后获取
+
+	classLoader *ClassLoader        // 类加载器,为空则使用 GlobalClassLoader
+	handlerPool *handle.HandlerPool // 反射代理缓存池,仅用于静态检查,为空则使用 GlobalHandlerPool
 }
 
 func NewTreeRegistry() *TreeRegistry {
-	return &TreeRegistry{
-		TreesByID:  map[string]*Tree{},
-		TreesByTag: map[string][]*Tree{},
+	r := &TreeRegistry{
+		retired: map[string]*Tree{},
+		idle:    map[string]*Tree{},
+		unused:  map[string]struct{}{},
+		users:   map[string]map[*Brain]struct{}{},
+	}
+	r.index.Store(newTreeIndex())
+	return r
+}
+
+// published 当前已发布的索引
+//
+//	@receiver r
+//	@return *treeIndex
+func (r *TreeRegistry) published() *treeIndex {
+	return r.index.Load()
+}
+
+// update 串行修改索引,fn 在拷贝上修改,成功后发布.fn 出错时丢弃所有修改,包括退役记录.修改前先清理无人使用的树,参看 TreeRegistry.sweepLocked
+//
+//	@receiver r
+//	@param fn
+//	@return error
+func (r *TreeRegistry) update(fn func(idx *treeIndex) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sweepLocked()
+	idx := r.published().clone()
+	err := fn(idx)
+	if err != nil {
+		return err
+	}
+	if len(idx.retiring) > 0 {
+		r.usersMutex.Lock()
+		r.commitRetired(idx)
+		r.usersMutex.Unlock()
+	}
+	r.releaseScripts(idx)
+	r.index.Store(idx)
+	return nil
+}
+
+// sweepLocked 清理闲置和释放后无人使用的树并单独发布,不随之后的修改失败而丢弃.须持有 mutex
+//
+//	@receiver r
+func (r *TreeRegistry) sweepLocked() {
+	r.usersMutex.Lock()
+	if len(r.idle) == 0 && len(r.unused) == 0 {
+		r.usersMutex.Unlock()
+		return
+	}
+	idx := r.published().clone()
+	r.sweepIdle(idx)
+	r.sweepUnused(idx)
+	r.usersMutex.Unlock()
+	r.releaseScripts(idx)
+	r.index.Store(idx)
+}
+
+// releaseScripts 注销本次修改中移除的树的节点脚本.本次加入的树可能复用了相同的节点id(如 Load 替换旧版),其脚本保留
+//
+//	@receiver r
+//	@param idx
+func (r *TreeRegistry) releaseScripts(idx *treeIndex) {
+	if len(idx.removed) == 0 {
+		return
+	}
+	published := r.published()
+	keep := map[string]bool{}
+	for id, tree := range idx.byID {
+		if published.byID[id] != tree {
+			tree.eachNode(func(node bcore.INode) { keep[node.ID()] = true })
+		}
+	}
+	for _, tree := range idx.removed {
+		tree.eachNode(func(node bcore.INode) {
+			if !keep[node.ID()] && node.Delegator().Script != "" {
+				script.UnregisterCode(node.ID())
+			}
+		})
+	}
+	idx.removed = nil
+}
+
+// TreeByID 根据 IRoot.ID 获取树
+//
+//	线程安全
+//	@receiver r
+//	@param id
+//	@return *Tree
+func (r *TreeRegistry) TreeByID(id string) *Tree {
+	return r.published().byID[id]
+}
+
+// TreesByTag 获取该tag的所有树,包括作为子树挂载的实例,不包括已退役的旧版.返回值只读
+//
+//	线程安全
+//	@receiver r
+//	@param tag
+//	@return []*Tree
+func (r *TreeRegistry) TreesByTag(tag string) []*Tree {
+	return r.published().byTag[tag]
+}
+
+// Trees 获取所有树,包括已退役但仍在使用的旧版
+//
+//	线程安全
+//	@receiver r
+//	@return []*Tree
+func (r *TreeRegistry) Trees() []*Tree {
+	return lo.Values(r.published().byID)
+}
+
+// loader 实例化节点使用的类加载器
+//
+//	@receiver r
+//	@return *ClassLoader
+func (r *TreeRegistry) loader() *ClassLoader {
+	if r.classLoader != nil {
+		return r.classLoader
+	}
+	return globalClassLoader
+}
+
+// handlers 静态检查委托使用的反射代理缓存池
+//
+//	@receiver r
+//	@return *handle.HandlerPool
+func (r *TreeRegistry) handlers() *handle.HandlerPool {
+	if r.handlerPool != nil {
+		return r.handlerPool
 	}
+	return handlerPool
 }
 
 // CloneAndReg 拷贝树并注册
 //
-// @receiver r
-// @param src
-// @return error
+//	线程安全
+//	@receiver r
+//	@param src
+//	@return error
 func (r *TreeRegistry) CloneAndReg(src *Tree) (*Tree, error) {
-	dst, err := src.clone()
+	var dst *Tree
+	err := r.update(func(idx *treeIndex) error {
+		var err error
+		dst, err = r.cloneAndReg(idx, src)
+		return err
+	})
+	return dst, err
+}
+
+func (r *TreeRegistry) cloneAndReg(idx *treeIndex, src *Tree) (*Tree, error) {
+	dst, err := src.clone(r.loader())
 	if err != nil {
 		return dst, err
 	}
-	r.TreesByID[dst.Root.ID()] = dst
-	r.TreesByTag[dst.Tag] = append(r.TreesByTag[dst.Tag], dst)
+	idx.add(dst)
 	return dst, nil
 }
 
 func (r *TreeRegistry) LoadFromPaths(paths []string) error {
+	cfgs := make([]*config.TreeCfg, 0, len(paths))
 	for _, path := range paths {
 		file, err := os.ReadFile(path)
 		if err != nil {
 			return errors.WithStack(err)
 		}
-		err = r.LoadFromJson(file)
+		cfg, err := parseTreeJson(file)
 		if err != nil {
 			return err
 		}
+		cfgs = append(cfgs, cfg)
 	}
-	err := r.MountAll()
-	if err != nil {
-		return err
-	}
-	return nil
+	return r.loadAll(cfgs, nil)
 }
 
 func (r *TreeRegistry) LoadFromJsons(cfgJson [][]byte) error {
+	cfgs := make([]*config.TreeCfg, 0, len(cfgJson))
 	for _, j := range cfgJson {
-		err := r.LoadFromJson(j)
+		cfg, err := parseTreeJson(j)
 		if err != nil {
 			return err
 		}
+		cfgs = append(cfgs, cfg)
 	}
-	err := r.MountAll()
+	return r.loadAll(cfgs, nil)
+}
+
+func (r *TreeRegistry) LoadFromJson(cfgJson []byte) error {
+	cfg, err := parseTreeJson(cfgJson)
 	if err != nil {
 		return err
 	}
-	return nil
+	return r.Load(cfg)
 }
 
-func (r *TreeRegistry) LoadFromJson(cfgJson []byte) error {
+// parseTreeJson 解析树的json配置,未配置ver时以内容的md5作为ver
+//
+//	@param cfgJson
+//	@return *config.TreeCfg
+//	@return error
+func parseTreeJson(cfgJson []byte) (*config.TreeCfg, error) {
 	var cfg config.TreeCfg
 	err := json.Unmarshal(cfgJson, &cfg)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 	if cfg.Ver == "" {
 		cfg.Ver = fmt.Sprintf("%x", md5.Sum(cfgJson))
 	}
-	return r.Load(&cfg)
+	return &cfg, nil
 }
 
-// Remove 根据tag移除树,移除前请务必:1.停止使用该树运行的AI 2.同时移除关联树(该树的静态子树和动态子树)
+// LoadFromFS 加载 fsys 中匹配 patterns 的所有树并挂载子树,可用于 go:embed 嵌入的配置
 //
-// @receiver r
-// @param tag
+//	@receiver r
+//	@param fsys
+//	@param patterns fs.Glob 语法,为空时加载所有 .json 文件(包括子目录)
+//	@return error 出错时包含文件路径
+func (r *TreeRegistry) LoadFromFS(fsys fs.FS, patterns ...string) error {
+	paths, err := globFS(fsys, patterns)
+	if err != nil {
+		return err
+	}
+	cfgs := make([]*config.TreeCfg, 0, len(paths))
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		cfg, err := parseTreeJson(data)
+		if err != nil {
+			return errors.WithMessagef(err, "load tree file failed,path=%s", path)
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return r.loadAll(cfgs, paths)
+}
+
+// LoadFromReader 从 reader 读取一棵树的json配置,加载并挂载子树
+//
+//	@receiver r
+//	@param reader
+//	@return error
+func (r *TreeRegistry) LoadFromReader(reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cfg, err := parseTreeJson(data)
+	if err != nil {
+		return err
+	}
+	return r.loadAll([]*config.TreeCfg{cfg}, nil)
+}
+
+// globFS 匹配 fsys 中的文件,去重并排序
+//
+//	@param fsys
+//	@param patterns 为空时匹配所有 .json 文件(包括子目录)
+//	@return []string
+//	@return error
+func globFS(fsys fs.FS, patterns []string) ([]string, error) {
+	var paths []string
+	if len(patterns) == 0 {
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".json") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return paths, nil
+	}
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "bad pattern %s", pattern)
+		}
+		if len(matches) == 0 {
+			return nil, errors.New(fmt.Sprintf("no file matches pattern %s", pattern))
+		}
+		paths = append(paths, matches...)
+	}
+	paths = lo.Uniq(paths)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// LoadFromGrootXML 加载 BehaviorTree.CPP v4 (Groot2) XML 中的所有树并挂载子树,转换规则参看 config.ParseGrootXML
+//
+//	@receiver r
+//	@param data
+//	@param opts
+//	@return error
+func (r *TreeRegistry) LoadFromGrootXML(data []byte, opts ...config.GrootOption) error {
+	cfgs, err := config.ParseGrootXML(data, opts...)
+	if err != nil {
+		return err
+	}
+	return r.loadAll(cfgs, nil)
+}
+
+// LoadFromB3Project 加载 behavior3editor 工程中的所有树并挂载子树,转换规则参看 config.ParseB3Project
+//
+//	@receiver r
+//	@param data
+//	@param opts
+//	@return error
+func (r *TreeRegistry) LoadFromB3Project(data []byte, opts ...config.B3Option) error {
+	cfgs, err := config.ParseB3Project(data, opts...)
+	if err != nil {
+		return err
+	}
+	return r.loadAll(cfgs, nil)
+}
+
+// Remove 根据tag移除树,移除前请务必:1.停止使用该树运行的AI 2.同时移除关联树(该树的静态子树和动态子树).
+// 若有AI正在运行,请使用 TreeRegistry.Reload 热更
+//
+//	线程安全
+//	@receiver r
+//	@param tag
 func (r *TreeRegistry) Remove(tag string) {
-	for _, tree := range r.TreesByTag[tag] {
-		delete(r.TreesByID, tree.Root.ID())
+	_ = r.update(func(idx *treeIndex) error {
+		idx.remove(tag)
+		return nil
+	})
+}
+
+func (idx *treeIndex) remove(tag string) {
+	for _, tree := range idx.byTag[tag] {
+		delete(idx.byID, tree.Root.ID())
+		idx.removed = append(idx.removed, tree)
+	}
+	delete(idx.byTag, tag)
+	for _, tree := range idx.specialized(tag) {
+		delete(idx.byID, tree.Root.ID())
+		delete(idx.bySpec, tree.indexKey())
+		idx.removed = append(idx.removed, tree)
 	}
-	delete(r.TreesByTag, tag)
 }
 
-// Load 加载树,加载前请务必:1.停止使用该树运行的AI 2.移除该树旧版及其关联树(该树的静态子树和动态子树)
+// Load 加载树,加载前请务必:1.停止使用该树运行的AI 2.移除该树旧版及其关联树(该树的静态子树和动态子树).
+// 若有AI正在运行,请使用 TreeRegistry.Reload 热更
 //
+//	线程安全
 //	@receiver r
 //	@param cfg
 //	@return *Tree
 //	@return error
-//
-//nolint:gocyclo
 func (r *TreeRegistry) Load(cfg *config.TreeCfg) error {
+	tree, err := r.build(cfg)
+	if err != nil || tree == nil {
+		return err
+	}
+	return r.update(func(idx *treeIndex) error {
+		r.register(idx, tree)
+		return nil
+	})
+}
+
+// loadAll 在一次修改中加载多棵树并挂载子树,加载的树作为子树时直接挂载,不必另外克隆
+//
+//	@receiver r
+//	@param cfgs
+//	@param paths 配置对应的文件路径,用于错误信息,可为空
+//	@return error
+func (r *TreeRegistry) loadAll(cfgs []*config.TreeCfg, paths []string) error {
+	trees := make([]*Tree, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		tree, err := r.build(cfg)
+		if err != nil {
+			if paths != nil {
+				return errors.WithMessagef(err, "load tree file failed,path=%s", paths[i])
+			}
+			return err
+		}
+		if tree != nil {
+			trees = append(trees, tree)
+		}
+	}
+	return r.update(func(idx *treeIndex) error {
+		for _, tree := range trees {
+			r.register(idx, tree)
+		}
+		return r.mountAll(idx)
+	})
+}
+
+// build 检查配置并实例化树,tag+ver与已加载的相同时返回nil
+//
+//	@receiver r
+//	@param cfg
+//	@return *Tree
+//	@return error
+func (r *TreeRegistry) build(cfg *config.TreeCfg) (*Tree, error) {
+	err := r.checkCfg(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// 先从缓存中找 tag+ver重复时返回旧树忽略加载
+	trees := r.TreesByTag(cfg.Tag)
+	if len(trees) > 0 && trees[0].Ver == cfg.Ver {
+		logger.Log.Warn("tree id already exists,ignore load", zap.String("ver", cfg.Ver), zap.String("tag", cfg.Tag), zap.String("id", cfg.Root))
+		return nil, nil
+	}
+	return r.newTree(cfg)
+}
+
+// register 注册树,替换该tag的旧树.实例化后已有其他线程加载了相同版本时忽略
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+func (r *TreeRegistry) register(idx *treeIndex, tree *Tree) {
+	trees := idx.byTag[tree.Tag]
+	if len(trees) > 0 && trees[0].Ver == tree.Ver {
+		return
+	}
+	idx.remove(tree.Tag)
+	idx.add(tree)
+}
+
+// checkCfg 加载前检查配置
+//
+//	@receiver r
+//	@param cfg
+//	@return error
+func (r *TreeRegistry) checkCfg(cfg *config.TreeCfg) error {
 	err := cfg.Valid()
 	if err != nil {
 		return err
 	}
-	// 先从缓存中找 tag+ver重复时返回旧树忽略加载
-	trees, ok := r.TreesByTag[cfg.Tag]
-	var tree *Tree
-	if ok {
-		tree = trees[0]
+	if internal.GlobalConfig.ValidateOnLoad {
+		return r.validateOnLoad(cfg)
 	}
-	if tree != nil && tree.Ver == cfg.Ver {
-		logger.Log.Warn("tree id already exists,ignore load", zap.String("ver", cfg.Ver), zap.String("tag", cfg.Tag), zap.String("id", cfg.Root))
-		return nil
+	return nil
+}
+
+// newTree 根据配置实例化树,不会注册,子树容器暂不挂载
+//
+//	@receiver r
+//	@param cfg
+//	@return *Tree
+//	@return error
+//
+//nolint:gocyclo
+func (r *TreeRegistry) newTree(cfg *config.TreeCfg) (*Tree, error) {
+	// 模板以默认参数实例化
+	if cfg.IsTemplate() {
+		return r.specialize(cfg, nil)
 	}
-	r.Remove(cfg.Tag)
-	tree = &Tree{
+	tree := &Tree{
 		Tag:             cfg.Tag,
 		Ver:             cfg.Ver,
 		StaticSubtrees:  map[string]task.ISubtree{},
 		DynamicSubtrees: map[string]task.IDynamicSubtree{},
+		Blackboard:      cfg.Blackboard,
 	}
 	nodes := map[string]bcore.INode{}
-	for _, nodeCfg := range cfg.Nodes {
-		err = nodeCfg.Valid()
-		if err != nil {
-			return err
+	for id, nodeCfg := range cfg.Nodes {
+		err := nodeCfg.Valid()
+		if err == nil {
+			var node bcore.INode
+			node, err = r.loader().New(nodeCfg.Name, nodeCfg)
+			if err == nil {
+				nodes[node.ID()] = node
+			}
 		}
-		node, err := globalClassLoader.New(nodeCfg.Name, nodeCfg)
 		if err != nil {
-			return err
+			return nil, errors.WithMessagef(err, "load node failed,tag=%s,id=%s,name=%s,title=%s", cfg.Tag, id, nodeCfg.Name, nodeCfg.Title)
 		}
-		nodes[node.ID()] = node
 	}
-	tree.Root = nodes[cfg.Root].(bcore.IRoot)
+	root, ok := nodes[cfg.Root].(bcore.IRoot)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("root node not found or not a Root,tag=%s,root=%s", cfg.Tag, cfg.Root))
+	}
+	tree.Root = root
 	for _, node := range nodes {
 		chidlrenIDs := cfg.Nodes[node.ID()].Children
 		// 子树容器特殊处理,暂存待依赖树全部加载完再挂载
@@ -234,45 +779,163 @@ func (r *TreeRegistry) Load(cfg *config.TreeCfg) error {
 			tree.DynamicSubtrees[dst.Tag()] = dst
 			continue
 		}
+		for _, id := range chidlrenIDs {
+			if nodes[id] == nil {
+				return nil, errors.New(fmt.Sprintf("child node not found,tag=%s,id=%s,title=%s,childID=%s", cfg.Tag, node.ID(), node.Title(), id))
+			}
+		}
 		switch node.Category() {
 		case bcore.CategoryComposite:
 			if len(chidlrenIDs) == 0 {
-				return errors.New("composite must have one child at least")
+				return nil, errors.New(fmt.Sprintf("composite must have one child at least,tag=%s,id=%s,title=%s", cfg.Tag, node.ID(), node.Title()))
 			}
 			for _, id := range chidlrenIDs {
 				node.(bcore.IComposite).AddChild(nodes[id])
 			}
 		case bcore.CategoryDecorator:
 			if len(chidlrenIDs) != 1 {
-				return errors.New("decorator can have only one child")
+				return nil, errors.New(fmt.Sprintf("decorator can have only one child,tag=%s,id=%s,title=%s", cfg.Tag, node.ID(), node.Title()))
 			}
 			node.(bcore.IDecorator).Decorate(nodes[chidlrenIDs[0]])
 		case bcore.CategoryTask:
 			// do nothing
 		default:
-			return errors.New("unsupport this category:" + node.Category())
+			return nil, errors.New(fmt.Sprintf("unsupport this category:%s,tag=%s,id=%s,title=%s", node.Category(), cfg.Tag, node.ID(), node.Title()))
 		}
 	}
 	for _, node := range nodes {
 		node.SetRoot(nil, tree.Root)
 	}
-	r.TreesByID[cfg.Root] = tree
-	r.TreesByTag[tree.Tag] = append(r.TreesByTag[tree.Tag], tree)
+	return tree, nil
+}
+
+// specialize 以参数实例化模板,不会注册.参数与默认值相同时节点ID与模板配置相同,否则换成新id
+//
+//	@receiver r
+//	@param template 模板配置
+//	@param params 可为空
+//	@return *Tree
+//	@return error
+func (r *TreeRegistry) specialize(template *config.TreeCfg, params map[string]any) (*Tree, error) {
+	resolved, err := template.ResolveParams(params)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := template.Specialize(resolved)
+	if err != nil {
+		return nil, err
+	}
+	paramsKey := config.ParamsKey(resolved)
+	if paramsKey == config.ParamsKey(template.Params) {
+		paramsKey, resolved = "", nil
+	} else {
+		// 节点id是黑板中节点数据和脚本的索引,不能与模板共用
+		cfg = renewIDs(cfg)
+	}
+	tree, err := r.newTree(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tree.template = template
+	tree.Params = resolved
+	tree.paramsKey = paramsKey
+	return tree, nil
+}
+
+// resolveKey 获取 tag 的树以 params 特化后在索引中的键
+//
+//	@receiver r
+//	@param idx
+//	@param tag
+//	@param params 可为空
+//	@return key 参数与默认值相同时为 tag
+//	@return err 该tag的树不是模板或参数未声明
+func (r *TreeRegistry) resolveKey(idx *treeIndex, tag string, params map[string]any) (key string, err error) {
+	trees := idx.byTag[tag]
+	if len(params) == 0 || len(trees) == 0 {
+		return tag, nil
+	}
+	template := trees[0].template
+	if template == nil {
+		return "", errors.New(fmt.Sprintf("tree is not a template,cannot accept params,tag=%s", tag))
+	}
+	resolved, err := template.ResolveParams(params)
+	if err != nil {
+		return "", err
+	}
+	paramsKey := config.ParamsKey(resolved)
+	if paramsKey == config.ParamsKey(template.Params) {
+		return tag, nil
+	}
+	return specKey(tag, paramsKey), nil
+}
+
+// validateOnLoad 加载前静态检查,警告打印日志,错误合并返回
+//
+//	@receiver r
+//	@param cfg
+//	@return error
+func (r *TreeRegistry) validateOnLoad(cfg *config.TreeCfg) error {
+	var msgs []string
+	for _, d := range r.Validate(cfg) {
+		if d.Severity == SeverityWarning {
+			logger.Log.Warn("validate tree", zap.String("diagnostic", d.String()))
+			continue
+		}
+		msgs = append(msgs, d.String())
+	}
+	if len(msgs) > 0 {
+		return errors.New(fmt.Sprintf("validate tree failed,tag=%s:\n%s", cfg.Tag, strings.Join(msgs, "\n")))
+	}
 	return nil
 }
 
-// MountAll 遍历所有未挂载子树的子树容器,挂载子树
+// MountAll 遍历所有未挂载子树的子树容器,挂载子树.树须在其子树挂载完成后再运行.
+// 已发布的树可能正在运行,不会就地挂载,而是克隆后挂载并替换,使用旧树的AI按 ReloadNextLoop 切换
 //
+//	线程安全
 //	@receiver r
 //	@return error
 func (r *TreeRegistry) MountAll() error {
-	for _, tree := range r.TreesByID {
-		err := r.mountAllSubtree(tree)
+	return r.update(r.mountAll)
+}
+
+func (r *TreeRegistry) mountAll(idx *treeIndex) error {
+	// 已退役的旧版已从tag索引中移除,不再挂载
+	var trees []*Tree
+	for _, list := range idx.byTag {
+		trees = append(trees, list...)
+	}
+	for _, list := range idx.bySpec {
+		trees = append(trees, list...)
+	}
+	published := r.published()
+	for _, tree := range trees {
+		if published.byID[tree.Root.ID()] == nil {
+			err := r.mountAllSubtree(idx, tree)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// 已发布的树可能正被其他线程读取,不能挂载.缺少的子树已加载时克隆一份挂载,并退役旧树.静态子树随其父树一起克隆
+		if tree.Root.Parent(nil) != nil || !r.mountable(idx, tree) {
+			continue
+		}
+		clone, err := r.cloneAndReg(idx, tree)
 		if err != nil {
 			return err
 		}
+		err = r.mountAllSubtree(idx, clone)
+		if err != nil {
+			return err
+		}
+		r.usersMutex.Lock()
+		idx.retire(tree)
+		r.retireStaticSubtrees(idx, tree)
+		r.usersMutex.Unlock()
 	}
-	for _, tree := range r.TreesByID {
+	for _, tree := range idx.byID {
 		for _, subtree := range tree.StaticSubtrees {
 			if subtree.Decorated(nil) == nil {
 				logger.Log.Error("subtree not found", zap.String("tag", subtree.GetPropChildTag()), zap.String("desc", subtree.String(nil)))
@@ -282,13 +945,50 @@ func (r *TreeRegistry) MountAll() error {
 	return nil
 }
 
+// mountable 树或其静态子树上是否有未挂载且子树已加载的容器
+//
+//	@receiver r
+//	@param idx
+//	@param tree
+//	@return bool
+func (r *TreeRegistry) mountable(idx *treeIndex, tree *Tree) bool {
+	if tree.AllSubtreeMounted {
+		return false
+	}
+	each := func(container task.ISubtree) bool {
+		child := container.Decorated(nil)
+		if child == nil {
+			tag := container.GetPropChildTag()
+			return tag != "" && len(idx.byTag[tag]) > 0
+		}
+		subtree := idx.byID[child.ID()]
+		return subtree != nil && r.mountable(idx, subtree)
+	}
+	for _, container := range tree.StaticSubtrees {
+		if each(container) {
+			return true
+		}
+	}
+	for _, container := range tree.DynamicSubtrees {
+		if each(container) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetNotParentTreeWithoutClone 获取一个还未分配静态父节点的树，多用于获取该tag的主树.
 //
-// @receiver r
-// @param tag
-// @return *Tree
+//	线程安全
+//	@receiver r
+//	@param tag
+//	@return *Tree
 func (r *TreeRegistry) GetNotParentTreeWithoutClone(tag string) *Tree {
-	for _, tree := range r.TreesByTag[tag] {
+	return freeTree(r.TreesByTag(tag))
+}
+
+func freeTree(trees []*Tree) *Tree {
+	for _, tree := range trees {
 		parent := tree.Root.Parent(nil)
 		if parent == nil {
 			return tree
@@ -297,24 +997,71 @@ func (r *TreeRegistry) GetNotParentTreeWithoutClone(tag string) *Tree {
 	return nil
 }
 
-// getNotParentTree 获取一个还未分配的静态父节点的树，若没有未分配的则clone一个
+// getNotParentTree 获取一个还未分配的静态父节点的树作为主树运行，若没有未分配的则clone一个
 //
-// @receiver r
-// @param tag 树的tag
-// @return utree 无父节点的树
-// @return cloned utree 是否是clone出来的
-// @return err
-func (r *TreeRegistry) getNotParentTree(tag string) (utree *Tree, cloned bool, err error) {
-	if len(r.TreesByTag[tag]) == 0 {
+//	线程安全
+//	@receiver r
+//	@param tag 树的tag
+//	@param params 模板参数,可为空,该参数集首次使用时特化模板
+//	@return utree 无父节点的树
+//	@return cloned utree 是否是clone出来的
+//	@return err
+func (r *TreeRegistry) getNotParentTree(tag string, params map[string]any) (utree *Tree, cloned bool, err error) {
+	// 已发布的树不会再被静态挂载,可直接作为主树
+	published := r.published()
+	key, err := r.resolveKey(published, tag, params)
+	if err != nil {
+		return nil, false, err
+	}
+	if tree := freeTree(published.lookup(key)); tree != nil {
+		return tree, false, nil
+	}
+	err = r.update(func(idx *treeIndex) error {
+		var err error
+		utree, cloned, err = r.getNotParentTreeIn(idx, tag, params)
+		if err != nil || !cloned {
+			return err
+		}
+		return r.mountAllSubtree(idx, utree)
+	})
+	return utree, cloned, err
+}
+
+// getNotParentTreeIn 在修改中的索引里获取一个可静态挂载的树,若没有则clone一个.
+// 已发布的树可能正被其他线程读取,不能挂载,只能用本次修改中新加入的
+//
+//	@receiver r
+//	@param idx
+//	@param tag
+//	@param params 模板参数,可为空
+//	@return utree
+//	@return cloned utree 是否是clone或特化出来的,须挂载其子树
+//	@return err
+func (r *TreeRegistry) getNotParentTreeIn(idx *treeIndex, tag string, params map[string]any) (utree *Tree, cloned bool, err error) {
+	if len(idx.byTag[tag]) == 0 {
 		return nil, false, nil
 	}
-	for _, tree := range r.TreesByTag[tag] {
-		parent := tree.Root.Parent(nil)
-		if parent == nil {
+	key, err := r.resolveKey(idx, tag, params)
+	if err != nil {
+		return nil, false, err
+	}
+	trees := idx.lookup(key)
+	// 该参数集首次使用,从模板特化
+	if len(trees) == 0 {
+		tree, err := r.specialize(idx.byTag[tag][0].template, params)
+		if err != nil {
+			return nil, false, err
+		}
+		idx.add(tree)
+		return tree, true, nil
+	}
+	published := r.published()
+	for _, tree := range trees {
+		if tree.Root.Parent(nil) == nil && published.byID[tree.Root.ID()] == nil {
 			return tree, false, nil
 		}
 	}
-	child, err := r.CloneAndReg(r.TreesByTag[tag][0])
+	child, err := r.cloneAndReg(idx, trees[0])
 	if err != nil {
 		return nil, false, err
 	}
@@ -323,50 +1070,94 @@ func (r *TreeRegistry) getNotParentTree(tag string) (utree *Tree, cloned bool, e
 
 // getNotDynamicParentTree 获取一个还未分配的动态父节点的树，若没有未分配的则clone一个
 //
-// @receiver r
-// @param tag
-// @param brain
-// @return utree
-// @return cloned
-// @return err
-func (r *TreeRegistry) getNotDynamicParentTree(tag string, container task.IDynamicSubtree, brain bcore.IBrain) (utree *Tree, cloned bool, err error) {
-	// 若正在挂载的子树就是需要的子树tag,直接返回
+//	线程安全,须在 brain 线程调用
+//	@receiver r
+//	@param tag
+//	@param params 模板参数,可为空
+//	@param container
+//	@param brain
+//	@return utree
+//	@return cloned
+//	@return err
+func (r *TreeRegistry) getNotDynamicParentTree(tag string, params map[string]any, container task.IDynamicSubtree, brain bcore.IBrain) (utree *Tree, cloned bool, err error) {
+	published := r.published()
+	key, err := r.resolveKey(published, tag, params)
+	if err != nil {
+		return nil, false, err
+	}
+	// 若正在挂载的子树就是需要的子树,直接返回
 	root := container.Decorated(brain)
-	if root != nil && r.TreesByID[root.ID()].Tag == tag {
-		return r.TreesByID[root.ID()], false, nil
+	if root != nil && !r.isRetired(root.ID()) {
+		if tree := published.byID[root.ID()]; tree != nil && tree.indexKey() == key {
+			return tree, false, nil
+		}
+	}
+	if tree := r.findDynamicFreeTree(published.lookup(key), container, brain); tree != nil {
+		return tree, false, nil
 	}
-	if len(r.TreesByTag[tag]) == 0 {
+	if len(published.byTag[tag]) == 0 {
 		return nil, false, nil
 	}
+	// 再找不到的话拷贝或特化一个.加锁后可能已有其他线程拷贝过,但挂载数据按brain隔离,其他brain拷贝的也可以用
+	err = r.update(func(idx *treeIndex) error {
+		if len(idx.byTag[tag]) == 0 {
+			return nil
+		}
+		key, err := r.resolveKey(idx, tag, params)
+		if err != nil {
+			return err
+		}
+		if utree = r.findDynamicFreeTree(idx.lookup(key), container, brain); utree != nil {
+			return nil
+		}
+		if trees := idx.lookup(key); len(trees) > 0 {
+			utree, err = r.cloneAndReg(idx, trees[0])
+		} else {
+			utree, err = r.specialize(idx.byTag[tag][0].template, params)
+			if err == nil {
+				idx.add(utree)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		cloned = true
+		// 注意clone出来的树要处理未挂载的节点
+		return r.mountAllSubtree(idx, utree)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return utree, cloned, nil
+}
+
+// findDynamicFreeTree 在 trees 中找一个 brain 可以动态挂载到 container 的树
+//
+//	@receiver r
+//	@param trees
+//	@param container
+//	@param brain
+//	@return *Tree
+func (r *TreeRegistry) findDynamicFreeTree(trees []*Tree, container task.IDynamicSubtree, brain bcore.IBrain) *Tree {
 	// 优先返回父容器id相同的未激活的子树
-	for _, tree := range r.TreesByTag[tag] {
+	for _, tree := range trees {
 		parent := brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory(tree.Root.ID()).MountParent
 		if parent != nil && parent.ID() == container.ID() && parent.IsInactive(brain) {
-			return tree, false, nil
+			return tree
 		}
 	}
 	// 找不到的话返回还未挂载的
-	for _, tree := range r.TreesByTag[tag] {
+	for _, tree := range trees {
 		parent := brain.Blackboard().(bcore.IBlackboardInternal).NodeMemory(tree.Root.ID()).MountParent
 		// 自己未挂载过就可以用 即使其他brain挂载了也没关系,因为挂载数据隔离
 		if parent == nil {
-			return tree, false, nil
+			return tree
 		}
 	}
-	// 再找不到的话拷贝一个
-	child, err := r.CloneAndReg(r.TreesByTag[tag][0])
-	if err != nil {
-		return nil, false, err
-	}
-	// 注意clone出来的树要处理未挂载的节点
-	err = r.mountAllSubtree(child)
-	if err != nil {
-		return nil, false, err
-	}
-	return child, true, nil
+	return nil
 }
 
-func (r *TreeRegistry) mountAllSubtree(tree *Tree) error {
+func (r *TreeRegistry) mountAllSubtree(idx *treeIndex, tree *Tree) error {
 	if tree.AllSubtreeMounted {
 		return nil
 	}
@@ -384,7 +1175,7 @@ func (r *TreeRegistry) mountAllSubtree(tree *Tree) error {
 			err := errors.New(fmt.Sprintf("tag cannot empty,container is %s", container.String(nil)))
 			return err
 		}
-		child, cloned, err := r.getNotParentTree(tag)
+		child, cloned, err := r.getNotParentTreeIn(idx, tag, container.GetPropParams())
 		if err != nil {
 			allMounted = false
 			return err
@@ -392,7 +1183,7 @@ func (r *TreeRegistry) mountAllSubtree(tree *Tree) error {
 		// 找不到子树 可能还没加载
 		if child == nil {
 			allMounted = false
-			err = errors.New(fmt.Sprintf("cannot find subtree,containerTag=%s", tag))
+			err = errors.New(fmt.Sprintf("cannot find subtree,containerTag=%s,tree=%s,container=%s", tag, tree.Tag, container.ID()))
 			return err
 		}
 		// 找到子树,装饰
@@ -401,7 +1192,7 @@ func (r *TreeRegistry) mountAllSubtree(tree *Tree) error {
 		if !cloned {
 			return nil
 		}
-		return r.mountAllSubtree(child)
+		return r.mountAllSubtree(idx, child)
 	}
 	for _, container := range tree.StaticSubtrees {
 		err := each(container)
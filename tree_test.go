@@ -1,10 +1,17 @@
 package behavior
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
 	"github.com/panjf2000/ants/v2"
 	"github.com/samber/lo"
 	"math/rand/v2"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"go.uber.org/zap/zapcore"
@@ -13,6 +20,8 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/script"
 )
 
 func help() {
@@ -43,13 +52,60 @@ func TestTreeRegistry_LoadFromPaths(t *testing.T) {
 				logger.Log.Error("", zap.Error(err))
 				t.Errorf("LoadFromPaths() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			for _, tree := range r.TreesByID {
+			for _, tree := range r.Trees() {
 				bcore.Print(tree.Root, NewBrain(bcore.NewBlackboard(1, nil), nil, nil))
 			}
 		})
 	}
 }
 
+func TestTreeRegistry_LoadFromFS(t *testing.T) {
+	help()
+	mainTree := `{"root":"fs-root","tag":"fs_main","nodes":{
+"fs-root":{"id":"fs-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["fs-sub"]},
+"fs-sub":{"id":"fs-sub","name":"Subtree","category":"task","title":"Subtree","properties":{"childTag":"fs_sub"}}
+}}`
+	subTree := `{"root":"fss-root","tag":"fs_sub","nodes":{
+"fss-root":{"id":"fss-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["fss-wait"]},
+"fss-wait":{"id":"fss-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"1s"}}
+}}`
+	badTree := `{"root":"fsb-root","tag":"fs_bad","nodes":{
+"fsb-root":{"id":"fsb-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["fsb-seq"]},
+"fsb-seq":{"id":"fsb-seq","name":"Sequence","category":"composite","title":"seq","properties":{},"children":["fsb-missing"]}
+}}`
+	fsys := fstest.MapFS{
+		"trees/main.json":   {Data: []byte(mainTree)},
+		"trees/sub/a.json":  {Data: []byte(subTree)},
+		"trees/readme.md":   {Data: []byte("ignored")},
+		"broken/bad.json":   {Data: []byte(badTree)},
+		"broken/other.json": {Data: []byte(`{`)},
+	}
+	r := NewTreeRegistry()
+	if err := r.LoadFromFS(fsys, "trees/*.json", "trees/sub/*.json"); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetNotParentTreeWithoutClone("fs_main") == nil || len(r.TreesByTag("fs_sub")) != 1 {
+		t.Fatal("trees not loaded")
+	}
+	if err := NewTreeRegistry().LoadFromFS(fsys, "nothing/*.json"); err == nil {
+		t.Fatal("want error for unmatched pattern")
+	}
+	err := NewTreeRegistry().LoadFromFS(fsys, "broken/bad.json")
+	if err == nil || !strings.Contains(err.Error(), "broken/bad.json") || !strings.Contains(err.Error(), "fsb-seq") {
+		t.Fatalf("error should name file and node, got %v", err)
+	}
+	if err = NewTreeRegistry().LoadFromFS(fsys); err == nil || !strings.Contains(err.Error(), "broken/") {
+		t.Fatalf("error should name file, got %v", err)
+	}
+	r = NewTreeRegistry()
+	if err = r.LoadFromReader(strings.NewReader(subTree)); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.TreesByTag("fs_sub")) != 1 {
+		t.Fatal("tree not loaded from reader")
+	}
+}
+
 func TestRunTree_LoadFromStr(t *testing.T) {
 	help()
 	content := `
@@ -363,3 +419,361 @@ func goBbInt(brain bcore.IBrain, key string, maxMill int64, max int) {
 		}()
 	}
 }
+
+// TestTreeRegistry_Concurrent 用 go test -race 运行,检查并发 Run,DynamicDecorate,Load,Remove
+func TestTreeRegistry_Concurrent(t *testing.T) {
+	help()
+	logger.SetLevel(zapcore.InfoLevel)
+	defer logger.SetLevel(zapcore.DebugLevel)
+	sub := func(tag string) []byte {
+		return []byte(`{"root":"` + tag + `-root","tag":"` + tag + `","nodes":{
+"` + tag + `-root":{"id":"` + tag + `-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["` + tag + `-wait"]},
+"` + tag + `-wait":{"id":"` + tag + `-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"5ms"}}
+}}`)
+	}
+	main := []byte(`{"root":"cc-root","tag":"cc_main","nodes":{
+"cc-root":{"id":"cc-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["cc-seq"]},
+"cc-seq":{"id":"cc-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["cc-static","cc-dynamic"]},
+"cc-static":{"id":"cc-static","name":"Subtree","category":"decorator","title":"Subtree","properties":{"childTag":"cc_leaf"}},
+"cc-dynamic":{"id":"cc-dynamic","name":"DynamicSubtree","category":"decorator","title":"DynamicSubtree","properties":{"tag":"slot","childTag":"cc_a"}}
+}}`)
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.TreeRegistry()
+	if err = r.LoadFromJsons([][]byte{main, sub("cc_leaf"), sub("cc_a"), sub("cc_b")}); err != nil {
+		t.Fatal(err)
+	}
+	const brains, frames = 8, 200
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < brains; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			brain := rt.NewTickBrain(bcore.NewBlackboard(2000+i, nil), nil, nil)
+			if err := brain.Run("cc_main", false); err != nil {
+				t.Error(err)
+				return
+			}
+			for frame := 0; frame < frames; frame++ {
+				brain.Tick(2 * time.Millisecond)
+				if frame%10 == 0 && brain.Running() {
+					// 其他 brain 并发挂载时可能需要克隆
+					_ = brain.DynamicDecorate("slot", lo.Ternary(frame%20 == 0, "cc_b", "cc_a"))
+				}
+			}
+		}(i)
+	}
+	// 并发加载和移除无关的树,并读取注册器
+	var loaders sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		loaders.Add(1)
+		go func(i int) {
+			defer loaders.Done()
+			tag := fmt.Sprintf("cc_other%d", i)
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := r.LoadFromJson(sub(tag)); err != nil {
+					t.Error(err)
+					return
+				}
+				_ = r.MountAll()
+				_ = r.Validate()
+				_ = r.Trees()
+				r.Remove(tag)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	loaders.Wait()
+	if len(r.TreesByTag("cc_a")) == 0 || len(r.TreesByTag("cc_b")) == 0 || r.GetNotParentTreeWithoutClone("cc_main") == nil {
+		t.Fatal("trees lost after concurrent operations")
+	}
+}
+
+func TestTreeRegistry_UpdateRollback(t *testing.T) {
+	help()
+	r := NewTreeRegistry()
+	if err := r.LoadFromJson([]byte(`{"root":"ur-root","tag":"ur_main","nodes":{
+"ur-root":{"id":"ur-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["ur-wait"]},
+"ur-wait":{"id":"ur-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"5ms"}}
+}}`)); err != nil {
+		t.Fatal(err)
+	}
+	tree := r.TreesByTag("ur_main")[0]
+	// 修改出错时索引和退役记录都不生效
+	err := r.update(func(idx *treeIndex) error {
+		idx.retire(tree)
+		delete(idx.byID, tree.Root.ID())
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("update error lost")
+	}
+	if r.TreeByID(tree.Root.ID()) != tree || len(r.TreesByTag("ur_main")) != 1 || r.isRetired(tree.Root.ID()) {
+		t.Fatal("failed update published")
+	}
+}
+
+func TestTreeRegistry_MountPublished(t *testing.T) {
+	help()
+	r := NewTreeRegistry()
+	if err := r.LoadFromJson([]byte(`{"root":"mp-root","tag":"mp_main","nodes":{
+"mp-root":{"id":"mp-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["mp-sub"]},
+"mp-sub":{"id":"mp-sub","name":"Subtree","category":"task","title":"Subtree","properties":{"childTag":"mp_sub"}}
+}}`)); err != nil {
+		t.Fatal(err)
+	}
+	old := r.TreesByTag("mp_main")[0]
+	if err := r.LoadFromJson([]byte(`{"root":"ms-root","tag":"mp_sub","nodes":{
+"ms-root":{"id":"ms-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["ms-wait"]},
+"ms-wait":{"id":"ms-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"5ms"}}
+}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.MountAll(); err != nil {
+		t.Fatal(err)
+	}
+	// 已发布的树不被修改,由挂载好子树的克隆替换
+	if lo.Values(old.StaticSubtrees)[0].Decorated(nil) != nil {
+		t.Fatal("published tree mounted in place")
+	}
+	trees := r.TreesByTag("mp_main")
+	if len(trees) != 1 || trees[0] == old || lo.Values(trees[0].StaticSubtrees)[0].Decorated(nil) == nil {
+		t.Fatal("published tree not replaced by mounted clone")
+	}
+	if r.TreeByID(old.Root.ID()) != nil {
+		t.Fatal("unused replaced tree not disposed")
+	}
+}
+
+func TestTreeRegistry_ReleaseScripts(t *testing.T) {
+	help()
+	tree := func(ver int) []byte {
+		return []byte(fmt.Sprintf(`{"root":"rs-root","tag":"rs_main","ver":"%d","nodes":{
+"rs-root":{"id":"rs-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["rs-action"]},
+"rs-action":{"id":"rs-action","name":"Action","category":"task","title":"Action","properties":{},"delegator":{"script":"ResultSucceeded"}}
+}}`, ver))
+	}
+	scriptID := func(tree *Tree) string {
+		return tree.Root.Decorated(nil).ID()
+	}
+	r := NewTreeRegistry()
+	if err := r.LoadFromJson(tree(1)); err != nil {
+		t.Fatal(err)
+	}
+	// 替换为复用相同节点id的新版时脚本保留
+	if err := r.LoadFromJson(tree(2)); err != nil {
+		t.Fatal(err)
+	}
+	v2 := r.TreesByTag("rs_main")[0]
+	if !script.ExistsCode(scriptID(v2)) {
+		t.Fatal("script of replacing tree released")
+	}
+	// 热更后无人使用的旧版移除时注销脚本
+	cfg, err := parseTreeJson(tree(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Reload(ReloadNextLoop, cfg); err != nil {
+		t.Fatal(err)
+	}
+	v3 := r.TreesByTag("rs_main")[0]
+	if script.ExistsCode(scriptID(v2)) || !script.ExistsCode(scriptID(v3)) {
+		t.Fatal("script of disposed tree not released")
+	}
+	r.Remove("rs_main")
+	if script.ExistsCode(scriptID(v3)) {
+		t.Fatal("script of removed tree not released")
+	}
+}
+
+func TestTreeRegistry_LoadFromGrootXML(t *testing.T) {
+	help()
+	content := `
+<root BTCPP_format="4" main_tree_to_execute="groot_main">
+  <BehaviorTree ID="groot_main">
+    <Sequence>
+      <Fallback>
+        <RetryUntilSuccessful num_attempts="3">
+          <Inverter>
+            <Sleep msec="10"/>
+          </Inverter>
+        </RetryUntilSuccessful>
+        <Sleep name="fallback" msec="100"/>
+      </Fallback>
+      <SubTree ID="groot_sub"/>
+    </Sequence>
+  </BehaviorTree>
+  <BehaviorTree ID="groot_sub">
+    <Timeout msec="50">
+      <Sleep msec="10"/>
+    </Timeout>
+  </BehaviorTree>
+</root>`
+	if err := GlobalTreeRegistry().LoadFromGrootXML([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	fch := make(chan *bcore.FinishEvent, 1)
+	brain := runTickTree(t, bcore.NewBlackboard(1012, nil), "groot_main", nil, fch)
+	// 重试3次(30ms) + fallback(100ms) + 子树(10ms)
+	var elapsed time.Duration
+	for ; elapsed < time.Second; elapsed += 10 * time.Millisecond {
+		brain.Tick(10 * time.Millisecond)
+		select {
+		case event := <-fch:
+			if !event.Succeeded {
+				t.Fatalf("finish event = %+v, want succeeded", event)
+			}
+			if elapsed < 130*time.Millisecond {
+				t.Fatalf("finished after %v, want at least 140ms", elapsed+10*time.Millisecond)
+			}
+			return
+		default:
+		}
+	}
+	t.Fatal("tree not finished")
+}
+
+type b3Counter struct {
+	n int
+}
+
+func (c *b3Counter) Count() error {
+	c.n++
+	return nil
+}
+
+func TestTreeRegistry_LoadFromB3Project(t *testing.T) {
+	help()
+	content := `
+{"name":"test","data":{"version":"0.3.0","scope":"project","trees":[
+{"version":"0.3.0","scope":"tree","id":"t1","title":"b3_main","root":"n1","nodes":{
+"n1":{"id":"n1","name":"Priority","title":"Priority","properties":{},"children":["n2","n5"]},
+"n2":{"id":"n2","name":"Limiter","title":"Limiter","properties":{"maxLoop":2},"child":"n3"},
+"n3":{"id":"n3","name":"MemSequence","title":"MemSequence","properties":{},"children":["n4","n6"]},
+"n4":{"id":"n4","name":"Count","title":"count","properties":{}},
+"n6":{"id":"n6","name":"Wait","title":"Wait","properties":{"milliseconds":100}},
+"n5":{"id":"n5","name":"t2","category":"tree","title":"idle","properties":{}}
+}},
+{"version":"0.3.0","scope":"tree","id":"t2","title":"b3_idle","root":"m1","nodes":{
+"m1":{"id":"m1","name":"Wait","title":"Wait","properties":{"milliseconds":1000}}
+}}],
+"custom_nodes":[{"version":"0.3.0","scope":"node","name":"Count","category":"action","title":null,"properties":{}}]}}`
+	if err := RegisterDelegatorType("b3Counter", &b3Counter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := GlobalTreeRegistry().LoadFromB3Project([]byte(content), config.WithB3Target("b3Counter")); err != nil {
+		t.Fatal(err)
+	}
+	counter := &b3Counter{}
+	brain := runTickTree(t, bcore.NewBlackboard(1013, nil), "b3_main", map[string]any{"b3Counter": counter}, nil)
+	// 前两次循环各计数一次,之后 Limiter 失败转入子树空闲
+	for i := 0; i < 300; i++ {
+		brain.Tick(10 * time.Millisecond)
+	}
+	if counter.n != 2 {
+		t.Fatalf("counter.n = %d, want 2", counter.n)
+	}
+}
+
+func TestTreeRegistry_Reload(t *testing.T) {
+	help()
+	mainTree := `
+{"root":"rl-root","tag":"rl_main","nodes":{
+"rl-root":{"id":"rl-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["rl-seq"]},
+"rl-seq":{"id":"rl-seq","name":"Sequence","category":"composite","title":"Sequence","properties":{},"children":["rl-wait","rl-sub"]},
+"rl-wait":{"id":"rl-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"100ms"}},
+"rl-sub":{"id":"rl-sub","name":"Subtree","category":"task","title":"Subtree","properties":{"childTag":"rl_action"}}
+}}`
+	actionTree := func(ver int) []byte {
+		return []byte(fmt.Sprintf(`
+{"root":"ra-root","tag":"rl_action","ver":"%d","nodes":{
+"ra-root":{"id":"ra-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["ra-action"]},
+"ra-action":{"id":"ra-action","name":"Action","category":"task","title":"Action","properties":{},"delegator":{"script":"blackboard.Set(\"ver\", %d); ResultSucceeded"}}
+}}`, ver, ver))
+	}
+	reload := func(policy ReloadPolicy, ver int) {
+		var cfg config.TreeCfg
+		if err := json.Unmarshal(actionTree(ver), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if err := GlobalTreeRegistry().Reload(policy, &cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := GlobalTreeRegistry()
+	if err := registry.LoadFromJsons([][]byte{[]byte(mainTree), actionTree(1)}); err != nil {
+		t.Fatal(err)
+	}
+	fch := make(chan *bcore.FinishEvent, 1)
+	ver := func(brain bcore.IBrain) any {
+		v, _ := brain.Blackboard().Get("ver")
+		return v
+	}
+
+	// 下一轮切换:当前轮仍运行旧版,下一轮运行新版,旧版无人使用后移除
+	brain := runTickTree(t, bcore.NewBlackboard(1014, nil), "rl_main", nil, fch)
+	brain.Tick(50 * time.Millisecond)
+	oldRoot := brain.RunningTree().ID()
+	reload(ReloadNextLoop, 2)
+	if registry.TreeByID(oldRoot) == nil {
+		t.Fatal("old version disposed while running")
+	}
+	brain.Tick(50 * time.Millisecond)
+	if v := ver(brain); v != 1 {
+		t.Fatalf("ver = %v, want 1 in current loop", v)
+	}
+	published := registry.published()
+	brain.Tick(0)
+	if brain.RunningTree().ID() == oldRoot {
+		t.Fatal("brain not migrated at next loop")
+	}
+	// 释放时不修改索引,延迟清理
+	if registry.published() != published || registry.TreeByID(oldRoot) == nil {
+		t.Fatal("index updated on release")
+	}
+	registry.sweep()
+	if registry.TreeByID(oldRoot) != nil {
+		t.Fatal("old version not disposed after migrate")
+	}
+	brain.Tick(100 * time.Millisecond)
+	if v := ver(brain); v != 2 {
+		t.Fatalf("ver = %v, want 2 after migrate", v)
+	}
+
+	// 立即切换:中断当前轮并从头运行新版
+	brain.Tick(50 * time.Millisecond)
+	brain.Blackboard().Set("ver", 0)
+	oldRoot = brain.RunningTree().ID()
+	reload(ReloadImmediately, 3)
+	brain.Tick(0)
+	registry.sweep()
+	if brain.RunningTree().ID() == oldRoot || registry.TreeByID(oldRoot) != nil {
+		t.Fatal("brain not migrated immediately")
+	}
+	brain.Tick(50 * time.Millisecond)
+	if v := ver(brain); v != 0 {
+		t.Fatalf("ver = %v, want 0 before new loop finished", v)
+	}
+	brain.Tick(50 * time.Millisecond)
+	if v := ver(brain); v != 3 {
+		t.Fatalf("ver = %v, want 3 after migrate", v)
+	}
+	select {
+	case event := <-fch:
+		t.Fatalf("unexpected finish event %+v while migrating", event)
+	default:
+	}
+	if !brain.Running() {
+		t.Fatal("brain stopped after migrate")
+	}
+}
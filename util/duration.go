@@ -12,6 +12,11 @@ type Duration struct {
 	time.Duration
 }
 
+// MarshalJSON 序列化为 time.Duration.String 的格式,与 UnmarshalJSON 对应
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(duration.String())
+}
+
 func (duration *Duration) UnmarshalJSON(b []byte) error {
 	var unmarshalledJson interface{}
 
@@ -0,0 +1,503 @@
+package behavior
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alkaid/behavior/bcore"
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/script"
+	"github.com/alkaid/behavior/task"
+	"github.com/samber/lo"
+)
+
+// Severity 诊断的严重程度
+type Severity int
+
+const (
+	SeverityError   Severity = iota // 错误,树无法正确加载或运行
+	SeverityWarning                 // 警告,树可以运行但行为可能不符合预期
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic 静态检查发现的问题
+type Diagnostic struct {
+	Severity  Severity
+	Tag       string // 树的tag
+	NodeID    string // 节点ID,为空表示树级别的问题
+	NodeName  string // 节点类名
+	NodeTitle string // 节点描述
+	Message   string
+}
+
+func (d Diagnostic) String() string {
+	if d.NodeID == "" {
+		return fmt.Sprintf("%s: tree %s: %s", d.Severity, d.Tag, d.Message)
+	}
+	return fmt.Sprintf("%s: tree %s: node %s(%s,%s): %s", d.Severity, d.Tag, d.NodeID, d.NodeName, d.NodeTitle, d.Message)
+}
+
+// HasError 诊断中是否包含错误
+//
+//	@param diagnostics
+//	@return bool
+func HasError(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 使用全局树注册器静态检查树配置,参看 TreeRegistry.Validate
+//
+//	@param cfgs
+//	@return []Diagnostic
+func Validate(cfgs ...*config.TreeCfg) []Diagnostic {
+	return GlobalTreeRegistry().Validate(cfgs...)
+}
+
+// Validate 静态检查树配置,返回发现的所有问题,不会修改注册器.
+//
+//	检查项:树结构(根节点,子节点ID,子节点数量,孤立节点),节点类是否注册及类型是否匹配,
+//	属性JSON是否与 bcore.INodeWorker.PropertiesClassProvider 严格匹配及属性取值( bcore.IPropertiesValidator ,绑定黑板键的属性只检查字段是否存在),
+//	委托方法是否注册到 GlobalHandlerPool,脚本是否能编译,子树引用的tag是否存在(在 cfgs 或注册器中)以及子树是否循环引用.
+//	树声明了黑板键( config.TreeCfg .Blackboard )时,检查节点引用的键( bcore.IBlackboardKeysProvider )和属性绑定的键是否已声明及类型是否相符.
+//	模板以默认参数替换占位符后检查.cfgs 中的树会覆盖注册器中同tag的树.
+//	@receiver r
+//	@param cfgs
+//	@return []Diagnostic
+func (r *TreeRegistry) Validate(cfgs ...*config.TreeCfg) []Diagnostic {
+	v := &validator{registry: r, subtrees: map[string][]*config.NodeCfg{}, provided: map[string]bool{}}
+	for tag, trees := range r.published().byTag {
+		if len(trees) > 0 {
+			v.provided[tag] = true
+		}
+	}
+	for _, cfg := range cfgs {
+		if cfg != nil && cfg.Tag != "" {
+			v.provided[cfg.Tag] = true
+		}
+	}
+	for _, cfg := range cfgs {
+		if cfg == nil {
+			continue
+		}
+		v.validateTree(cfg)
+	}
+	v.validateCycles(cfgs)
+	return v.diagnostics
+}
+
+type validator struct {
+	registry    *TreeRegistry
+	diagnostics []Diagnostic
+	provided    map[string]bool              // 可被子树引用的tag
+	subtrees    map[string][]*config.NodeCfg // tag->该树中的静态子树容器
+}
+
+func (v *validator) report(severity Severity, cfg *config.TreeCfg, node *config.NodeCfg, format string, args ...any) {
+	d := Diagnostic{Severity: severity, Tag: cfg.Tag, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		d.NodeID = node.ID
+		d.NodeName = node.Name
+		d.NodeTitle = node.Title
+	}
+	v.diagnostics = append(v.diagnostics, d)
+}
+
+func (v *validator) validateTree(cfg *config.TreeCfg) {
+	if err := cfg.Valid(); err != nil {
+		v.report(SeverityError, cfg, nil, "%s", err.Error())
+	}
+	// 模板以默认参数检查
+	if cfg.IsTemplate() {
+		spec, err := cfg.Specialize(cfg.Params)
+		if err != nil {
+			v.report(SeverityError, cfg, nil, "%s", err.Error())
+			return
+		}
+		cfg = spec
+	} else if names := cfg.TemplateParams(); len(names) > 0 {
+		v.report(SeverityWarning, cfg, nil, "params %s are referenced but not declared", strings.Join(names, ","))
+	}
+	root := cfg.Nodes[cfg.Root]
+	if cfg.Root != "" && root == nil {
+		v.report(SeverityError, cfg, nil, "root node %s not found", cfg.Root)
+	}
+	if root != nil && root.Name != bcore.NodeNameRoot {
+		v.report(SeverityError, cfg, root, "root node must be %s", bcore.NodeNameRoot)
+	}
+	parents := map[string]string{}
+	// 按ID排序保证诊断顺序稳定
+	ids := make([]string, 0, len(cfg.Nodes))
+	for id := range cfg.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := cfg.Nodes[id]
+		if node == nil {
+			v.report(SeverityError, cfg, &config.NodeCfg{ID: id}, "node is nil")
+			continue
+		}
+		if node.ID != id {
+			v.report(SeverityError, cfg, node, "node id mismatches its key %s", id)
+		}
+		v.validateNode(cfg, root, node)
+		for _, childID := range node.Children {
+			if _, ok := cfg.Nodes[childID]; !ok {
+				v.report(SeverityError, cfg, node, "child %s not found", childID)
+				continue
+			}
+			if childID == cfg.Root {
+				v.report(SeverityError, cfg, node, "root node cannot be a child")
+				continue
+			}
+			if parent, ok := parents[childID]; ok && parent != id {
+				v.report(SeverityError, cfg, cfg.Nodes[childID], "node has more than one parent: %s and %s", parent, id)
+				continue
+			}
+			parents[childID] = id
+		}
+	}
+	// 孤立节点
+	if root != nil {
+		reachable := map[string]bool{}
+		var walk func(id string)
+		walk = func(id string) {
+			node, ok := cfg.Nodes[id]
+			if !ok || node == nil || reachable[id] {
+				return
+			}
+			reachable[id] = true
+			for _, childID := range node.Children {
+				walk(childID)
+			}
+		}
+		walk(cfg.Root)
+		for _, id := range ids {
+			if !reachable[id] && cfg.Nodes[id] != nil {
+				v.report(SeverityWarning, cfg, cfg.Nodes[id], "node is unreachable from root")
+			}
+		}
+	}
+}
+
+//nolint:gocyclo
+func (v *validator) validateNode(cfg *config.TreeCfg, root *config.NodeCfg, node *config.NodeCfg) {
+	if err := node.Valid(); err != nil {
+		v.report(SeverityError, cfg, node, "%s", err.Error())
+		return
+	}
+	// 子节点数量
+	isSubtree := node.Name == bcore.NodeNameSubtree || node.Name == bcore.NodeNameDynamicSubtree
+	switch node.Category {
+	case bcore.CategoryComposite:
+		if len(node.Children) == 0 {
+			v.report(SeverityError, cfg, node, "composite must have one child at least")
+		}
+	case bcore.CategoryDecorator:
+		if isSubtree {
+			if len(node.Children) > 0 {
+				v.report(SeverityWarning, cfg, node, "children of subtree container are ignored")
+			}
+		} else if len(node.Children) != 1 {
+			v.report(SeverityError, cfg, node, "decorator must have exactly one child,got %d", len(node.Children))
+		}
+	case bcore.CategoryTask:
+		if len(node.Children) > 0 {
+			v.report(SeverityError, cfg, node, "task cannot have children")
+		}
+	default:
+		v.report(SeverityError, cfg, node, "unsupport this category:%s", node.Category)
+	}
+	// 节点类
+	instance, ok := v.registry.loader().newInstance(node.Name)
+	if !ok {
+		v.report(SeverityError, cfg, node, "node class %s not registered", node.Name)
+		return
+	}
+	switch node.Category {
+	case bcore.CategoryComposite:
+		if _, ok := instance.(bcore.IComposite); !ok {
+			v.report(SeverityError, cfg, node, "node class %s is not a composite", node.Name)
+		}
+	case bcore.CategoryDecorator:
+		if _, ok := instance.(bcore.IDecorator); !ok {
+			v.report(SeverityError, cfg, node, "node class %s is not a decorator", node.Name)
+		}
+	case bcore.CategoryTask:
+		_, isComposite := instance.(bcore.IComposite)
+		_, isDecorator := instance.(bcore.IDecorator)
+		if isComposite || isDecorator {
+			v.report(SeverityError, cfg, node, "node class %s is not a task", node.Name)
+		}
+	}
+	// 属性
+	properties := instance.(bcore.INodeWorker).PropertiesClassProvider()
+	if properties != nil {
+		v.validateProperties(cfg, node, properties)
+	}
+	// 委托
+	if node.Delegator.Script != "" {
+		if _, err := script.Compile(node.Delegator.Script); err != nil {
+			v.report(SeverityError, cfg, node, "compile script failed: %s", err.Error())
+		}
+	} else if node.Delegator.Method != "" {
+		target := node.Delegator.Target
+		if target == "" && root != nil {
+			target = root.Delegator.Target
+		}
+		switch {
+		case target == "":
+			v.report(SeverityWarning, cfg, node, "delegator method %s has no target,neither node nor root specifies one", node.Delegator.Method)
+		case !v.registry.handlers().ContainsTarget(target):
+			v.report(SeverityWarning, cfg, node, "delegator target %s not registered,call RegisterDelegatorType before running", target)
+		case v.registry.handlers().GetHandle(target, node.Delegator.Method) == nil:
+			v.report(SeverityError, cfg, node, "delegator method %s.%s not registered", target, node.Delegator.Method)
+		}
+	}
+	// 子树引用
+	if node.Name == bcore.NodeNameSubtree {
+		var props task.SubtreeProperties
+		_ = json.Unmarshal(node.Properties, &props)
+		switch {
+		case props.ChildTag == "" && props.ChildID == "":
+			v.report(SeverityWarning, cfg, node, "subtree has neither childTag nor childID")
+		case props.ChildTag != "" && !v.provided[props.ChildTag]:
+			v.report(SeverityWarning, cfg, node, "subtree childTag %s is not provided by any tree", props.ChildTag)
+		}
+		if props.ChildTag != "" {
+			v.subtrees[cfg.Tag] = append(v.subtrees[cfg.Tag], node)
+		}
+	}
+}
+
+// validateProperties 严格检查属性JSON:字段类型,未知字段,取值范围
+//
+//	@receiver v
+//	@param cfg
+//	@param node
+//	@param properties PropertiesClassProvider 提供的零值
+func (v *validator) validateProperties(cfg *config.TreeCfg, node *config.NodeCfg, properties any) {
+	raw := bytes.TrimSpace(node.Properties)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		raw = []byte("{}")
+	}
+	// 绑定黑板键的属性在运行时解析,只检查字段是否存在和默认值
+	raw, bindings, err := bcore.SplitPropertyBindings(raw)
+	if err != nil {
+		v.report(SeverityError, cfg, node, "invalid properties: %s", err.Error())
+		return
+	}
+	if err := json.Unmarshal(raw, properties); err != nil {
+		v.report(SeverityError, cfg, node, "invalid properties: %s", err.Error())
+		return
+	}
+	if known := jsonFields(reflect.TypeOf(properties)); known != nil {
+		var unbound []string
+		for name := range bindings {
+			if !known[name] {
+				unbound = append(unbound, name)
+			}
+		}
+		if len(unbound) > 0 {
+			sort.Strings(unbound)
+			v.report(SeverityError, cfg, node, "bound properties %s not found", strings.Join(unbound, ","))
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			v.report(SeverityError, cfg, node, "properties must be a json object: %s", err.Error())
+			return
+		}
+		var unknown []string
+		for k := range fields {
+			if !known[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			v.report(SeverityWarning, cfg, node, "unknown properties %s are ignored", strings.Join(unknown, ","))
+		}
+	}
+	if validator, ok := properties.(bcore.IPropertiesValidator); ok {
+		if err := validator.Valid(); err != nil {
+			v.report(SeverityError, cfg, node, "invalid properties: %s", err.Error())
+		}
+	}
+	v.validateBlackboardKeys(cfg, node, properties, bindings)
+}
+
+// validateBlackboardKeys 树声明了黑板键时,检查属性引用及绑定的键是否已声明,类型是否相符
+//
+//	@receiver v
+//	@param cfg
+//	@param node
+//	@param properties 已解析的属性
+//	@param bindings 属性json名->绑定的黑板键
+func (v *validator) validateBlackboardKeys(cfg *config.TreeCfg, node *config.NodeCfg, properties any, bindings map[string]string) {
+	if len(cfg.Blackboard) == 0 {
+		return
+	}
+	keys := map[string]config.KeyType{}
+	if provider, ok := properties.(bcore.IBlackboardKeysProvider); ok {
+		keys = provider.BlackboardKeys()
+	}
+	for _, key := range bindings {
+		if _, ok := keys[key]; !ok {
+			keys[key] = config.KeyTypeAny
+		}
+	}
+	if provider, ok := properties.(bcore.IObservePatternsProperties); ok {
+		for _, pattern := range provider.GetObservePatterns() {
+			keys[pattern] = config.KeyTypeAny
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		if key == "" {
+			continue
+		}
+		// 模式至少匹配一个声明的键
+		if bcore.IsPatternKey(key) {
+			if !lo.ContainsBy(cfg.BlackboardKeys(), func(declared string) bool { return bcore.MatchPattern(key, declared) }) {
+				v.report(SeverityError, cfg, node, "blackboard key pattern %s matches no declared key", key)
+			}
+			continue
+		}
+		decl, ok := cfg.Blackboard[key]
+		if !ok {
+			v.report(SeverityError, cfg, node, "blackboard key %s not declared", key)
+			continue
+		}
+		if decl != nil && !decl.Type.Accepts(keys[key]) {
+			v.report(SeverityError, cfg, node, "blackboard key %s is declared as %s but used as %s", key, decl.Type, keys[key])
+		}
+	}
+}
+
+// validateCycles 检查静态子树的循环引用
+//
+//	@receiver v
+//	@param cfgs
+func (v *validator) validateCycles(cfgs []*config.TreeCfg) {
+	// tag->引用的子树tag,cfgs 覆盖注册器中同tag的树
+	edges := map[string][]string{}
+	for tag, trees := range v.registry.published().byTag {
+		if len(trees) == 0 {
+			continue
+		}
+		for _, subtree := range trees[0].StaticSubtrees {
+			if childTag := subtree.GetPropChildTag(); childTag != "" {
+				edges[tag] = append(edges[tag], childTag)
+			}
+		}
+	}
+	for _, cfg := range cfgs {
+		if cfg == nil {
+			continue
+		}
+		edges[cfg.Tag] = nil
+		for _, node := range v.subtrees[cfg.Tag] {
+			var props task.SubtreeProperties
+			_ = json.Unmarshal(node.Properties, &props)
+			edges[cfg.Tag] = append(edges[cfg.Tag], props.ChildTag)
+		}
+	}
+	// 从 cfgs 中的每个子树容器出发,能回到自己所在的树即为循环
+	for _, cfg := range cfgs {
+		if cfg == nil {
+			continue
+		}
+		for _, node := range v.subtrees[cfg.Tag] {
+			var props task.SubtreeProperties
+			_ = json.Unmarshal(node.Properties, &props)
+			if path := findCycle(edges, props.ChildTag, cfg.Tag); path != nil {
+				v.report(SeverityError, cfg, node, "subtree cycle: %s", strings.Join(append([]string{cfg.Tag}, path...), " -> "))
+			}
+		}
+	}
+}
+
+// findCycle 查找从 from 到 target 的引用路径
+//
+//	@param edges
+//	@param from
+//	@param target
+//	@return []string 路径,不存在时为nil
+func findCycle(edges map[string][]string, from string, target string) []string {
+	visited := map[string]bool{}
+	var dfs func(tag string) []string
+	dfs = func(tag string) []string {
+		if tag == target {
+			return []string{tag}
+		}
+		if visited[tag] {
+			return nil
+		}
+		visited[tag] = true
+		for _, next := range edges[tag] {
+			if path := dfs(next); path != nil {
+				return append([]string{tag}, path...)
+			}
+		}
+		return nil
+	}
+	return dfs(from)
+}
+
+// jsonFields 属性类可识别的json字段名,非struct类型(如map)返回nil表示不限制
+//
+//	@param t
+//	@return map[string]bool
+func jsonFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			for k := range jsonFields(f.Type) {
+				fields[k] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
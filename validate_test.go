@@ -0,0 +1,74 @@
+package behavior
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alkaid/behavior/config"
+)
+
+func TestValidate(t *testing.T) {
+	help()
+	var bad config.TreeCfg
+	err := json.Unmarshal([]byte(`
+{"root":"v-root","tag":"validate_bad","nodes":{
+"v-root":{"id":"v-root","name":"Root","category":"decorator","title":"Root","properties":{"once":true},"children":["v-sel"]},
+"v-sel":{"id":"v-sel","name":"Selector","category":"composite","title":"Selector","properties":{},"children":["v-cond","v-rand","v-par","v-sub","v-missing"]},
+"v-cond":{"id":"v-cond","name":"BBCondition","category":"decorator","title":"cond","properties":{"operator":99,"key":"k","abortMode":1},"children":["v-act"]},
+"v-act":{"id":"v-act","name":"Action","category":"task","title":"act","properties":{},"delegator":{"script":"1 +"}},
+"v-rand":{"id":"v-rand","name":"Random","category":"decorator","title":"rand","properties":{"probability":1.5,"typo":1},"children":["v-wait"]},
+"v-wait":{"id":"v-wait","name":"Wait","category":"task","title":"wait","properties":{"waitTime":true}},
+"v-par":{"id":"v-par","name":"Parallel","category":"composite","title":"par","properties":{"successPolicy":3},"children":["v-unknown"]},
+"v-unknown":{"id":"v-unknown","name":"NoSuchNode","category":"task","title":"unknown","properties":{}},
+"v-sub":{"id":"v-sub","name":"Subtree","category":"decorator","title":"sub","properties":{"childTag":"validate_nowhere"}},
+"v-orphan":{"id":"v-orphan","name":"Wait","category":"task","title":"orphan","properties":{}}
+}}`), &bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := Validate(&bad)
+	want := []struct {
+		severity Severity
+		nodeID   string
+		message  string
+	}{
+		{SeverityError, "v-sel", "child v-missing not found"},
+		{SeverityError, "v-cond", "operator out of range"},
+		{SeverityError, "v-act", "compile script failed"},
+		{SeverityError, "v-rand", "probability must be in [0,1]"},
+		{SeverityWarning, "v-rand", "unknown properties typo"},
+		{SeverityError, "v-wait", "invalid properties"},
+		{SeverityError, "v-par", "successPolicy out of range"},
+		{SeverityError, "v-unknown", "node class NoSuchNode not registered"},
+		{SeverityWarning, "v-sub", "subtree childTag validate_nowhere is not provided"},
+		{SeverityWarning, "v-orphan", "unreachable"},
+	}
+	for _, w := range want {
+		found := false
+		for _, d := range diagnostics {
+			if d.Severity == w.severity && d.NodeID == w.nodeID && strings.Contains(d.Message, w.message) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing %s %s %q in:\n%v", w.severity, w.nodeID, w.message, diagnostics)
+		}
+	}
+	if !HasError(diagnostics) {
+		t.Fatal("HasError() = false")
+	}
+
+	// 子树循环引用
+	cycle := func(tag, childTag string) *config.TreeCfg {
+		return &config.TreeCfg{Tag: tag, Root: tag + "-root", Nodes: map[string]*config.NodeCfg{
+			tag + "-root": {ID: tag + "-root", Name: "Root", Category: "decorator", Title: "Root", Properties: json.RawMessage(`{}`), Children: []string{tag + "-sub"}},
+			tag + "-sub":  {ID: tag + "-sub", Name: "Subtree", Category: "decorator", Title: "sub", Properties: json.RawMessage(`{"childTag":"` + childTag + `"}`)},
+		}}
+	}
+	diagnostics = Validate(cycle("validate_a", "validate_b"), cycle("validate_b", "validate_a"))
+	if len(diagnostics) != 2 || !strings.Contains(diagnostics[0].Message, "validate_a -> validate_b -> validate_a") {
+		t.Fatalf("cycle diagnostics = %v", diagnostics)
+	}
+}
@@ -0,0 +1,305 @@
+package behavior
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alkaid/behavior/config"
+	"github.com/alkaid/behavior/logger"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const defaultWatchInterval = time.Second
+
+type watchOptions struct {
+	interval time.Duration
+	policy   ReloadPolicy
+	onReload func(tags []string)
+	onError  func(path string, err error)
+}
+
+// WatchOption Watcher 的可选配置
+type WatchOption func(*watchOptions)
+
+// WithWatchInterval 轮询间隔,默认1s
+//
+//	@param interval
+//	@return WatchOption
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.interval = interval
+	}
+}
+
+// WithWatchPolicy 热更时运行中的AI切换策略,默认 ReloadNextLoop
+//
+//	@param policy
+//	@return WatchOption
+func WithWatchPolicy(policy ReloadPolicy) WatchOption {
+	return func(o *watchOptions) {
+		o.policy = policy
+	}
+}
+
+// WithWatchOnReload 每次热更成功后的回调
+//
+//	@param onReload 参数为本次热更的树tag
+//	@return WatchOption
+func WithWatchOnReload(onReload func(tags []string)) WatchOption {
+	return func(o *watchOptions) {
+		o.onReload = onReload
+	}
+}
+
+// WithWatchOnError 文件解析,检查或热更出错时的回调,默认打印错误日志.出错的文件不会影响已加载的树
+//
+//	@param onError
+//	@return WatchOption
+func WithWatchOnError(onError func(path string, err error)) WatchOption {
+	return func(o *watchOptions) {
+		o.onError = onError
+	}
+}
+
+// watchedFile 已扫描文件的状态
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	ver     string // 最后一次成功加载的版本
+	// 最后一次报告失败时的文件信息,失败的文件每次扫描都重试,但同一内容只报告一次
+	failModTime time.Time
+	failSize    int64
+}
+
+// fail 报告文件加载失败,文件未变化时不重复报告
+//
+//	@receiver w
+//	@param path
+//	@param info
+//	@param err
+func (w *Watcher) fail(path string, info fs.FileInfo, err error) {
+	file := w.files[path]
+	if file.failModTime.Equal(info.ModTime()) && file.failSize == info.Size() {
+		return
+	}
+	file.failModTime = info.ModTime()
+	file.failSize = info.Size()
+	w.opts.onError(path, err)
+}
+
+// Watcher 以轮询方式监视目录下的树配置文件(.json),文件变化时热更对应的树
+type Watcher struct {
+	registry *TreeRegistry
+	dir      string
+	opts     *watchOptions
+	files    map[string]*watchedFile
+	mutex    sync.Mutex // 保证同时只有一次扫描
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Watch 监视目录(包括子目录)下的 .json 树配置文件.先同步扫描加载一次,之后在独立协程中轮询,变化的文件按 TreeRegistry.LoadFromJson 的规则计算 Ver 并仅热更变化的树.
+// 文件删除不会移除已加载的树.
+//
+//	@receiver r
+//	@param dir
+//	@param opts
+//	@return *Watcher
+//	@return error 目录不可读时返回错误
+func (r *TreeRegistry) Watch(dir string, opts ...WatchOption) (*Watcher, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !info.IsDir() {
+		return nil, errors.New(fmt.Sprintf("watch path is not a directory,path=%s", dir))
+	}
+	o := &watchOptions{
+		interval: defaultWatchInterval,
+		policy:   ReloadNextLoop,
+		onError: func(path string, err error) {
+			logger.Log.Error("watch tree file failed", zap.String("path", path), zap.Error(err))
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	w := &Watcher{
+		registry: r,
+		dir:      dir,
+		opts:     o,
+		files:    map[string]*watchedFile{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	err = w.Scan()
+	if err != nil {
+		return nil, err
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Stop 停止监视,会等待正在进行的扫描完成
+//
+//	@receiver w
+func (w *Watcher) Stop() {
+	select {
+	case <-w.stop:
+		return
+	default:
+	}
+	close(w.stop)
+	<-w.done
+}
+
+func (w *Watcher) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			err := w.Scan()
+			if err != nil {
+				w.opts.onError(w.dir, err)
+			}
+		}
+	}
+}
+
+// Scan 立即扫描一次,热更变化的树.单个文件的错误通过 WithWatchOnError 回调报告,不会中断扫描
+//
+//	@receiver w
+//	@return error 目录遍历出错时返回
+func (w *Watcher) Scan() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var cfgs []*config.TreeCfg
+	paths := map[string]string{}      // tag->path
+	stats := map[string]fs.FileInfo{} // path->待提交的文件信息,热更成功后才记录,失败的文件下次扫描重试
+	seen := map[string]bool{}
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+		seen[path] = true
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := w.files[path]
+		if file == nil {
+			file = &watchedFile{}
+			w.files[path] = file
+		}
+		if file.modTime.Equal(info.ModTime()) && file.size == info.Size() {
+			return nil
+		}
+		cfg, err := w.parse(path)
+		if err != nil {
+			w.fail(path, info, err)
+			return nil
+		}
+		// 内容未变(如仅touch)
+		if cfg.Ver == file.ver {
+			file.modTime = info.ModTime()
+			file.size = info.Size()
+			return nil
+		}
+		if other, ok := paths[cfg.Tag]; ok {
+			w.fail(path, info, errors.New(fmt.Sprintf("duplicate tree tag %s,also in %s", cfg.Tag, other)))
+			return nil
+		}
+		paths[cfg.Tag] = path
+		stats[path] = info
+		cfgs = append(cfgs, cfg)
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+	if len(cfgs) == 0 {
+		return nil
+	}
+	// 逐个树热更,一个文件出错不影响其他文件;新增的子树可能排在引用它的树之后,有进展时重试失败的树
+	var tags []string
+	failed := map[string]error{}
+	for len(cfgs) > 0 {
+		var rest []*config.TreeCfg
+		for _, cfg := range cfgs {
+			err := w.registry.Reload(w.opts.policy, cfg)
+			if err != nil {
+				failed[cfg.Tag] = err
+				rest = append(rest, cfg)
+				continue
+			}
+			delete(failed, cfg.Tag)
+			path := paths[cfg.Tag]
+			file := w.files[path]
+			file.modTime = stats[path].ModTime()
+			file.size = stats[path].Size()
+			file.ver = cfg.Ver
+			tags = append(tags, cfg.Tag)
+		}
+		if len(rest) == len(cfgs) {
+			break
+		}
+		cfgs = rest
+	}
+	for tag, err := range failed {
+		w.fail(paths[tag], stats[paths[tag]], err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	sort.Strings(tags)
+	logger.Log.Info("tree files reloaded", zap.Strings("tags", tags))
+	if w.opts.onReload != nil {
+		w.opts.onReload(tags)
+	}
+	return nil
+}
+
+// parse 读取并解析树配置,静态检查有错误时返回错误
+//
+//	@receiver w
+//	@param path
+//	@return *config.TreeCfg
+//	@return error
+func (w *Watcher) parse(path string) (*config.TreeCfg, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cfg, err := parseTreeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Valid()
+	if err != nil {
+		return nil, err
+	}
+	err = w.registry.validateOnLoad(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,96 @@
+package behavior
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTreeRegistry_Watch(t *testing.T) {
+	help()
+	dir := t.TempDir()
+	tree := func(waitTime string) []byte {
+		return []byte(`{"root":"wt-root","tag":"watch_main","nodes":{
+"wt-root":{"id":"wt-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["wt-wait"]},
+"wt-wait":{"id":"wt-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"` + waitTime + `"}}
+}}`)
+	}
+	path := filepath.Join(dir, "main.json")
+	write := func(data []byte) {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// 保证修改时间变化
+		now := time.Now().Add(time.Duration(len(data)) * time.Second)
+		if err := os.Chtimes(path, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(tree("1s"))
+	if err := os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("ignored"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewTreeRegistry()
+	var reloaded [][]string
+	var failed []string
+	watcher, err := registry.Watch(dir,
+		WithWatchInterval(time.Hour),
+		WithWatchOnReload(func(tags []string) { reloaded = append(reloaded, tags) }),
+		WithWatchOnError(func(path string, err error) { failed = append(failed, path) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+	if !reflect.DeepEqual(reloaded, [][]string{{"watch_main"}}) {
+		t.Fatalf("reloaded = %v after initial scan", reloaded)
+	}
+	ver := registry.TreesByTag("watch_main")[0].Ver
+
+	// 未变化不热更
+	if err = watcher.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded) != 1 {
+		t.Fatalf("reloaded = %v, want no reload without change", reloaded)
+	}
+
+	// 错误的配置只报告,不影响已加载的树
+	write([]byte(`{"root":"wt-root","tag":"watch_main","nodes":{`))
+	if err = watcher.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	write(tree("oops"))
+	if err = watcher.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	// 失败的文件每次扫描都重试,但同一内容只报告一次;同批其他文件照常热更
+	other := filepath.Join(dir, "other.json")
+	if err = os.WriteFile(other, []byte(`{"root":"wo-root","tag":"watch_other","nodes":{
+"wo-root":{"id":"wo-root","name":"Root","category":"decorator","title":"Root","properties":{},"children":["wo-wait"]},
+"wo-wait":{"id":"wo-wait","name":"Wait","category":"task","title":"Wait","properties":{"waitTime":"1s"}}
+}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = watcher.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []string{path, path}) || !reflect.DeepEqual(reloaded[1:], [][]string{{"watch_other"}}) {
+		t.Fatalf("failed = %v, reloaded = %v", failed, reloaded)
+	}
+	reloaded = reloaded[:1]
+	if registry.TreesByTag("watch_main")[0].Ver != ver {
+		t.Fatal("working tree replaced by broken file")
+	}
+
+	// 修正后热更
+	write(tree("2s"))
+	if err = watcher.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded) != 2 || registry.TreesByTag("watch_main")[0].Ver == ver {
+		t.Fatalf("reloaded = %v, tree not reloaded", reloaded)
+	}
+}